@@ -6,6 +6,7 @@ import cors from 'cors';
 import { createServer } from 'http';
 import { Server } from 'socket.io';
 import { handleConnection, handleDisconnection } from './socket/labSessionMonitor';
+import { recordVerdicts, requeuePending } from './database/judge';
 
 const app = express();
 const port = 3000;
@@ -43,3 +44,8 @@ app.use('/api', routes());
 server.listen(port, () => {
   console.log(`Server is running on http://localhost:${port}`);
 });
+
+// Verdicts are stored here rather than by the request that uploaded the
+// submission, so a restart loses none
+requeuePending().catch((err) => console.error("Error requeueing pending submissions:", err));
+recordVerdicts().catch((err) => console.error("Error recording verdicts:", err));
@@ -1,4 +1,5 @@
 import { Request, Response } from "express";
+import { Prisma } from "@prisma/client";
 import prisma from "../database/prisma";
 
 export async function createInstructor(req: Request, res: Response) {
@@ -63,7 +64,7 @@ export async function createLabSession(req: Request, res: Response) {
 // create question
 export async function createQuestion(req: Request, res: Response) {
     try {
-        const { description, instructorId , testCases , labSessionId } = req.body;
+        const { description, instructorId , testCases , labSessionId , testCaseBased , checker , interactor } = req.body;
         if (!description || !instructorId || !testCases || !labSessionId) {
             return res.status(400).json({ error: "description, instructorId, testCases, and labSessionId are required" });
         }
@@ -73,6 +74,9 @@ export async function createQuestion(req: Request, res: Response) {
                 inputsOutputs : JSON.stringify(testCases),
                 labSessionId , 
                 instructorId ,
+                testCaseBased : Boolean(testCaseBased),
+                checker : checker || undefined, // testlib-style checker source, optional
+                interactor : interactor || undefined, // testlib-style interactor source, optional
             }
         });
         res.status(201).send(question);
@@ -220,7 +224,7 @@ export async function getLabSession(req: Request, res: Response) {
 
 export async function judgeSubmission(req: Request, res: Response) {
     try {
-        const { submissionId, verdict } = req.body;
+        const { submissionId, verdict, comment } = req.body;
         if (!submissionId) {
             return res.status(400).send("submissionId is required");
         }
@@ -235,7 +239,8 @@ export async function judgeSubmission(req: Request, res: Response) {
                 id: Number(submissionId)
             },
             data: {
-                status: verdict
+                status: verdict,
+                comment: comment || undefined, // optional feedback for the student
             }
         })
         res.status(200).json(submission);
@@ -243,4 +248,51 @@ export async function judgeSubmission(req: Request, res: Response) {
         console.log(err);
         res.status(500).send(err);
     }
-}
\ No newline at end of file
+}
+
+// get a question with its test cases
+export async function getQuestion(req: Request, res: Response) {
+    try {
+        const { questionId } = req.query;
+        if (!questionId) {
+            return res.status(400).send("questionId is required");
+        }
+        const question = await prisma.question.findUnique({
+            where: {
+                id: Number(questionId)
+            }
+        })
+        if (!question) {
+            return res.status(404).send("Question not found");
+        }
+        res.status(200).json(question);
+    } catch (err) {
+        console.log(err);
+        res.status(500).send(err);
+    }
+}
+
+// replace the test cases of a question
+export async function updateTestCases(req: Request, res: Response) {
+    try {
+        const { questionId, testCases } = req.body;
+        if (!questionId || !Array.isArray(testCases)) {
+            return res.status(400).json({ error: "questionId and testCases are required" });
+        }
+        const question = await prisma.question.update({
+            where: {
+                id: Number(questionId)
+            },
+            data: {
+                inputsOutputs: JSON.stringify(testCases),
+            }
+        })
+        res.status(200).json(question);
+    } catch (err) {
+        if (err instanceof Prisma.PrismaClientKnownRequestError && err.code === 'P2025') {
+            return res.status(404).send("Question not found");
+        }
+        console.log(err);
+        res.status(500).send(err);
+    }
+}
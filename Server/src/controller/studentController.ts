@@ -1,11 +1,22 @@
 import { Request, Response } from "express";
 import prisma from "../database/prisma";
 import { format } from 'date-fns';
-import path from "path";
 import fs from "fs";
 import { client, publisher, subscriber } from "../database/redis";
+import { queueSubmission } from "../database/judge";
 import { ClientRequest } from "http";
 
+// What a student may see of a question: everything but the checker and
+// interactor, which would show them how their answers are judged.
+const studentQuestionFields = {
+    id: true,
+    instructorId: true,
+    labSessionId: true,
+    description: true,
+    inputsOutputs: true,
+    testCaseBased: true,
+};
+
 export async function createStudent(req: Request, res: Response) {
     try {
         const { name, email, departmentId, enrollmentNumber } = req.body;
@@ -60,7 +71,7 @@ export async function getQuestions(req: Request, res: Response) {
                 },
             },
             include: {
-                questions: true,
+                questions: { select: studentQuestionFields },
             },
         });
 
@@ -105,7 +116,7 @@ export async function getLabSessions(req: Request, res: Response) {
             include: {
                 program: true,
                 instructor: true,
-                questions: true,
+                questions: { select: studentQuestionFields },
             },
         });
         res.status(200).json(labSessions);
@@ -158,7 +169,10 @@ export async function getStatus(req: Request, res: Response) {
         
         
         submissions?.forEach(submission => {
-            statusMap.set(submission.questionId, submission.status)
+            // a cancelled submission leaves the question as it was
+            if (submission.status !== "cancelled") {
+                statusMap.set(submission.questionId, submission.status)
+            }
         })
         
         const respose = {
@@ -240,19 +254,27 @@ export async function uploadSolution(req: Request, res: Response) {
             return res.status(400).send({ error: 'No solution file uploaded' });
         }
 
-        const dirPath = path.join(__dirname, '/../uploads', studentId, questionId);
-        if (!fs.existsSync(dirPath)) {
-            fs.mkdirSync(dirPath, { recursive: true });
-        }
-
-        // rename the file to solution.cpp
-        const solutionFilePath = path.join(dirPath, 'solution.cpp');
-        fs.renameSync(solutionFile.path, solutionFilePath);
+        // The source travels with the submission: a file kept per student and
+        // question would be replaced by their next submission before a
+        // worker got to this one
+        const source = fs.readFileSync(solutionFile.path, 'utf8');
+        fs.unlinkSync(solutionFile.path);
 
 
 
-        // push to redis
-        client.lPush('submissions', JSON.stringify({ studentId, questionId, solutionFilePath, dirPath, testCases: JSON.parse(questions[0].inputsOutputs) }));
+        // Store the submission as pending first, so the verdict has a row to
+        // land in even if the client disconnects, and so the submission has
+        // an ID of its own to key the progress channel on
+        const submission = await prisma.submission.create({
+            data: {
+                studentId: Number(studentId),
+                questionId: Number(questionId),
+                labSessionId: questions[0].labSessionId as number,
+                status: "pending",
+                solution: source,
+            }
+        });
+        const channel = `submission:${submission.id}`;
 
         // Set up SSE
         res.writeHead(200, {
@@ -261,36 +283,23 @@ export async function uploadSolution(req: Request, res: Response) {
             'Connection': 'keep-alive'
         });
 
-        // send response that its pushed to the quque to the client
-        res.write('data: {"pushed": true}\n\n');
-
-        const channel = `${studentId}-${questionId}`;
-
-        const messageHandler = async (message: string) => {
+        let stopReporting = () => {};
+        const messageHandler = (message: string) => {
+            // A worker has started on it
+            stopReporting();
             try {
                 const data = JSON.parse(message);
                 console.log("message received", data);
-                
-                res.write(`data: ${JSON.stringify(data)}\n\n`);
 
+                if (!res.writableEnded) {
+                    res.write(`data: ${JSON.stringify(data)}\n\n`);
+                }
+
+                // recordVerdicts stores the verdict
                 if (data.end) {
                     console.log("Ending connection");
                     res.end();
-                    subscriber.unsubscribe(channel);
-                    const fileContent = solutionFile && fs.readFileSync(solutionFilePath, 'utf8');
-                    const submission = await prisma.submission.create({
-                        data: {
-                            studentId: Number(studentId),
-                            questionId: Number(questionId),
-                            labSessionId: questions[0].labSessionId as number,
-                            resultDetails : JSON.stringify(data),
-                            status: data.status ?? "Failed",
-                            solution: fileContent,
-                        }
-                    })
-
-                    publisher.publish(questions[0].labSessionId.toString(), JSON.stringify(submission));
-                    
+                    subscriber.unsubscribe(channel, messageHandler);
                 }
             } catch (error) {
                 console.error("Error parsing message:", error);
@@ -299,24 +308,200 @@ export async function uploadSolution(req: Request, res: Response) {
             
         };
 
-        // Subscribe to channel
+        // Subscribe before pushing so no event is published unheard
+        await subscriber.subscribe(channel, messageHandler);
+
+        // send response that its pushed to the quque to the client
+        res.write(`data: {"pushed": true, "submissionId": ${submission.id}}\n\n`);
 
-        subscriber.subscribe(channel, messageHandler);
+        await queueSubmission(submission, questions[0]);
+        stopReporting = reportPosition(res, submission.id);
 
         console.log("pushed to excicution queue");
-        
-            // Handle client disconnect
-            req.on('close', () => {
+
+        // The verdict is still stored after the client disconnects; only
+        // the stream ends
+        req.on('close', () => {
             console.log("Client disconnected");
-            subscriber.unsubscribe(channel);
-            });
+            stopReporting();
+            res.end();
+        });
+
+    } catch (err) {
+        console.log(err);
+        res.status(500).send(err);
+    }
+}
+
+// Tell the student where a submission is in the queue, every second until
+// a worker starts on it, as the Go server does. The returned function stops
+// it early.
+function reportPosition(res: Response, submissionId: number) {
+    let last = "";
+    const send = async () => {
+        const ahead = await client.zRank('submissions:stream:waiting', String(submissionId));
+        if (ahead === null) {
+            clearInterval(timer);
+            return;
+        }
+        const data = JSON.stringify({ queued: true, position: ahead + 1 });
+        if (data !== last && !res.writableEnded) {
+            res.write(`data: ${data}\n\n`);
+            last = data;
+        }
+    };
+    const timer = setInterval(() => send().catch(console.error), 1000);
+    send().catch(console.error);
+    return () => clearInterval(timer);
+}
+
+// Find a submission of the student's, answering 400 or 404 itself when
+// there is none.
+async function findSubmission(req: Request, res: Response) {
+    const { submissionId, studentId } = req.query;
+    if (!submissionId || !studentId) {
+        res.status(400).send("submissionId and studentId are required");
+        return null;
+    }
+    const submission = await prisma.submission.findUnique({
+        where: { id: Number(submissionId) },
+    });
+    if (!submission || submission.studentId !== Number(studentId)) {
+        res.status(404).send("Submission not found");
+        return null;
+    }
+    return submission;
+}
+
+export async function getSubmission(req: Request, res: Response) {
+    try {
+        const submission = await findSubmission(req, res);
+        if (submission) {
+            res.status(200).json(submission);
+        }
+    } catch (err) {
+        console.log(err);
+        res.status(500).send(err);
+    }
+}
 
+// Let a student reattach to the judging of a submission after losing the
+// stream uploadSolution answered with.
+export async function streamSubmission(req: Request, res: Response) {
+    try {
+        const submission = await findSubmission(req, res);
+        if (!submission) {
+            return;
+        }
+        const question = await prisma.question.findUnique({
+            where: { id: submission.questionId },
+        });
+        if (!question?.testCaseBased) {
+            return res.status(400).send({ error: "Submission is judged by the instructor" });
+        }
 
+        const channel = `submission:${submission.id}`;
+        res.writeHead(200, {
+            'Content-Type': 'text/event-stream',
+            'Cache-Control': 'no-cache',
+            'Connection': 'keep-alive'
+        });
 
+        let poll: NodeJS.Timeout | undefined;
+        let stopReporting = () => {};
+        const stop = () => {
+            if (res.writableEnded) {
+                return;
+            }
+            clearInterval(poll);
+            stopReporting();
+            subscriber.unsubscribe(channel, messageHandler);
+            res.end();
+        };
+        const messageHandler = (message: string) => {
+            if (res.writableEnded) {
+                return;
+            }
+            stopReporting();
+            try {
+                const data = JSON.parse(message);
+                res.write(`data: ${JSON.stringify(data)}\n\n`);
+                if (data.end) {
+                    stop();
+                }
+            } catch (error) {
+                console.error("Error parsing message:", error);
+                stop();
+            }
+        };
+        // The stored verdict, once there is one, ends the stream
+        const sendStored = async () => {
+            const current = await prisma.submission.findUnique({ where: { id: submission.id } });
+            if (current && current.status !== "pending" && !res.writableEnded) {
+                res.write(`data: ${current.resultDetails}\n\n`);
+                stop();
+            }
+        };
+
+        await subscriber.subscribe(channel, messageHandler);
+        res.on('close', stop);
 
+        // The final event may have been published before the subscription
+        // started, so the stored submission is checked now and then as well
+        await sendStored();
+        if (res.writableEnded) {
+            return;
+        }
+        res.write(`data: {"attached": true, "submissionId": ${submission.id}}\n\n`);
+        stopReporting = reportPosition(res, submission.id);
+        poll = setInterval(() => sendStored().catch(console.error), 1000);
     } catch (err) {
         console.log(err);
+        if (res.headersSent) {
+            return res.end();
+        }
         res.status(500).send(err);
     }
 }
 
+// Withdraw a submission that has no verdict yet. The Go workers skip it if
+// it is still queued and stop judging it if it is running. The cancellation
+// is stored first, only if the submission is still pending, as a worker may
+// finish it at the same time, and only then published.
+export async function cancelSubmission(req: Request, res: Response) {
+    try {
+        const { submissionId, studentId } = req.query;
+        const submission = await prisma.submission.findUnique({
+            where: { id: Number(submissionId) },
+        });
+        if (!submission || submission.studentId !== Number(studentId)) {
+            return res.status(404).send("Submission not found");
+        }
+        if (submission.status !== "pending") {
+            const error = submission.status === "cancelled" ? "Submission has already been cancelled" : "Submission has already been judged";
+            return res.status(409).send({ error });
+        }
+
+        const details = { submissionId: submission.id, output: "Cancelled by the student", end: true, status: "cancelled" };
+        const { count } = await prisma.submission.updateMany({
+            where: { id: submission.id, status: "pending" },
+            data: { status: "cancelled", resultDetails: JSON.stringify(details) },
+        });
+        if (count === 0) {
+            return res.status(409).send({ error: "Submission has already been judged" });
+        }
+
+        await client.zRem('submissions:stream:waiting', String(submission.id));
+        await client.set(`submissions:stream:cancelled:${submission.id}`, 1, { EX: 24 * 60 * 60 });
+        await publisher.publish('submissions:stream:cancel', String(submission.id));
+
+        await publisher.publish(`submission:${submission.id}`, JSON.stringify(details));
+        const cancelled = await prisma.submission.findUnique({ where: { id: submission.id } });
+        publisher.publish(submission.labSessionId.toString(), JSON.stringify(cancelled));
+
+        res.status(200).json(cancelled);
+    } catch (err) {
+        console.log(err);
+        res.status(500).send(err);
+    }
+}
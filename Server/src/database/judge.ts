@@ -0,0 +1,137 @@
+import os from "os";
+import { Question, Submission } from "@prisma/client";
+import prisma from "./prisma";
+import { client, publisher } from "./redis";
+
+// The Redis keys of the Go workers' queue, as worker.NewQueue names them
+const STREAM = 'submissions:stream';
+const WAITING = `${STREAM}:waiting`;
+const VERDICTS = `${STREAM}:verdicts`;
+const VERDICT_GROUP = 'recorders';
+
+// As the queue's visibility timeout: a verdict unacknowledged for this long
+// was taken by a server that died before storing it
+const VISIBILITY_TIMEOUT = 2 * 60 * 1000;
+
+// Add a submission to the stream the Go workers read through their consumer
+// group, and to the waiting line queue positions are read from, as
+// Queue.Push does
+export async function queueSubmission(
+    submission: Pick<Submission, "id" | "studentId" | "questionId" | "solution">,
+    question: Pick<Question, "inputsOutputs" | "checker" | "interactor">,
+) {
+    const payload = {
+        submissionId: submission.id,
+        studentId: String(submission.studentId),
+        questionId: String(submission.questionId),
+        source: submission.solution ?? "",
+        testCases: JSON.parse(question.inputsOutputs),
+        checker: question.checker ?? undefined,
+        interactor: question.interactor ?? undefined,
+    };
+    await client.multi()
+        .xAdd(STREAM, '*', { submission: JSON.stringify(payload) })
+        .zAdd(WAITING, { score: Date.now(), value: String(submission.id) })
+        .exec();
+}
+
+// Store the verdicts the workers add to the verdicts stream, for as long as
+// the server runs, as Server.RecordVerdicts does. A verdict stays in the
+// stream until a server has stored it, so none is lost while no server is
+// running, whichever server the submission was uploaded to.
+export async function recordVerdicts() {
+    // Blocking reads would hold up every other command on a shared client
+    const reader = client.duplicate();
+    await reader.connect();
+    try {
+        await reader.xGroupCreate(VERDICTS, VERDICT_GROUP, '0', { MKSTREAM: true });
+    } catch (err) {
+        if (!String(err).includes('BUSYGROUP')) {
+            throw err;
+        }
+    }
+
+    const consumer = `${os.hostname()}-${process.pid}`;
+    for (;;) {
+        try {
+            const claimed = await reader.xAutoClaim(VERDICTS, VERDICT_GROUP, consumer, VISIBILITY_TIMEOUT, '0-0', { COUNT: 1 });
+            let entry = claimed.messages.find((m) => m !== null);
+            if (!entry) {
+                const read = await reader.xReadGroup(VERDICT_GROUP, consumer, { key: VERDICTS, id: '>' }, { COUNT: 1, BLOCK: 5000 });
+                entry = read?.[0]?.messages[0];
+            }
+            if (!entry) {
+                continue;
+            }
+            await recordVerdict(Number(entry.message.submissionId), entry.message.event);
+            await reader.multi()
+                .xAck(VERDICTS, VERDICT_GROUP, entry.id)
+                .xDel(VERDICTS, entry.id)
+                .exec();
+        } catch (err) {
+            // Left in the stream, to be tried again
+            console.error("Error recording verdict:", err);
+            await new Promise((resolve) => setTimeout(resolve, 1000));
+        }
+    }
+}
+
+// Store the final event of a submission's judging and tell the instructor's
+// monitor
+async function recordVerdict(submissionId: number, event: string) {
+    let data;
+    try {
+        data = JSON.parse(event);
+    } catch (error) {
+        console.error(`Error parsing verdict of submission ${submissionId}:`, error);
+        return;
+    }
+    // Only a pending submission: one that was cancelled, or judged already
+    // by an earlier delivery, keeps what it has
+    const { count } = await prisma.submission.updateMany({
+        where: { id: submissionId, status: "pending" },
+        data: {
+            resultDetails: event,
+            status: data.status === "passed" || data.status === "cancelled" ? data.status : "failed",
+        }
+    });
+    if (count === 0) {
+        console.log(`Ignoring verdict of submission ${submissionId}, which is gone or has one already`);
+        return;
+    }
+    const judged = await prisma.submission.findUnique({ where: { id: submissionId } });
+    if (judged) {
+        publisher.publish(judged.labSessionId.toString(), JSON.stringify(judged));
+    }
+}
+
+// Queue again the pending submissions the workers no longer have, such as
+// those whose verdict was lost before verdicts were kept until stored, as
+// Server.Requeue does. Meant to run when the server starts.
+export async function requeuePending() {
+    // The instructor judges the rest
+    const pending = await prisma.submission.findMany({
+        where: { status: "pending", question: { testCaseBased: true } },
+        include: { question: true },
+        orderBy: { id: 'asc' },
+    });
+    const known = new Set<number>();
+    for (const { message } of await client.xRange(STREAM, '-', '+')) {
+        try {
+            known.add(JSON.parse(message.submission).submissionId);
+        } catch {
+            // Buried by the workers when they get to it
+        }
+    }
+    for (const { message } of await client.xRange(VERDICTS, '-', '+')) {
+        known.add(Number(message.submissionId));
+    }
+
+    for (const submission of pending) {
+        if (known.has(submission.id)) {
+            continue;
+        }
+        await queueSubmission(submission, submission.question);
+        console.log(`Requeued submission ${submission.id}`);
+    }
+}
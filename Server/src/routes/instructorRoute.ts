@@ -1,5 +1,5 @@
 import { Request, Response, Router } from 'express';
-import { addQuestionToLabSession, createInstructor, createLabSession, createQuestion, getInstructorDetail, getLabAttendance, getLabSession, getSubmissionsForLabSession, judgeSubmission } from '../controller/InstructorController';
+import { addQuestionToLabSession, createInstructor, createLabSession, createQuestion, getInstructorDetail, getLabAttendance, getLabSession, getQuestion, getSubmissionsForLabSession, judgeSubmission, updateTestCases } from '../controller/InstructorController';
 
 
 const instructorRoute = Router();
@@ -13,6 +13,12 @@ instructorRoute.post('/labsession', createLabSession);
 //create question
 instructorRoute.post('/question', createQuestion);
 
+//get question with its test cases
+instructorRoute.get('/question', getQuestion);
+
+//replace the test cases of a question
+instructorRoute.post('/question/testcases', updateTestCases);
+
 //add question to lab session
 instructorRoute.post('/question/add', addQuestionToLabSession);
 
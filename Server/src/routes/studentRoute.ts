@@ -1,5 +1,5 @@
 import { Request, Response, Router } from 'express';
-import { createStudent, getLabSessions, getQuestions, getStatus, getStudent, uploadSolution } from '../controller/studentController';
+import { cancelSubmission, createStudent, getLabSessions, getQuestions, getStatus, getStudent, getSubmission, streamSubmission, uploadSolution } from '../controller/studentController';
 import { upload } from '.';
 
 const studentRouter = Router();
@@ -18,6 +18,15 @@ studentRouter.get('/status', getStatus);
 //upload solution
 studentRouter.post('/submit', upload.single('solution'), uploadSolution);
 
+//get a submission, with its verdict once judged
+studentRouter.get('/submission', getSubmission);
+
+//follow the judging of a submission, e.g. after losing the submit stream
+studentRouter.get('/submission/events', streamSubmission);
+
+//cancel a submission that has no verdict yet
+studentRouter.post('/submission/cancel', cancelSubmission);
+
 studentRouter.get('/' , getStudent)
 
 export default studentRouter;
\ No newline at end of file
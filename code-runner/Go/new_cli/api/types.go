@@ -0,0 +1,83 @@
+package api
+
+// These types mirror the JSON the Lab API returns, so the CLI, the Go server
+// and the worker all agree on one shape.
+
+type Question struct {
+	ID            int    `json:"id"`
+	InstructorID  int    `json:"instructorId"`
+	LabSessionID  int    `json:"labSessionId"`
+	Description   string `json:"description"`
+	InputsOutputs string `json:"inputsOutputs"`
+	TestCaseBased bool   `json:"testCaseBased"`
+	Checker       string `json:"checker,omitempty"`
+	Interactor    string `json:"interactor,omitempty"`
+}
+
+type Status struct {
+	StudentID    string            `json:"studentId"`
+	LabSessionID string            `json:"labSessionId"`
+	Status       map[string]string `json:"status"`
+}
+
+type Student struct {
+	ID               int    `json:"id"`
+	Name             string `json:"name"`
+	Email            string `json:"email"`
+	EnrollmentNumber string `json:"enrollmentNumber"`
+	DepartmentID     int    `json:"departmentId"`
+}
+
+type LabSession struct {
+	ID           int        `json:"id"`
+	ProgramID    int        `json:"programId"`
+	InstructorID int        `json:"instructorId"`
+	SessionDate  string     `json:"sessionDate"`
+	Description  string     `json:"description"`
+	Program      Program    `json:"program"`
+	Instructor   Instructor `json:"instructor"`
+	Questions    []Question `json:"questions"`
+}
+
+type Program struct {
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	ProgramCode  string `json:"programCode"`
+	DepartmentID int    `json:"departmentId"`
+	CreatedAt    string `json:"createdAt"`
+}
+
+type Instructor struct {
+	ID           int          `json:"id"`
+	Name         string       `json:"name"`
+	Email        string       `json:"email"`
+	DepartmentID int          `json:"departmentId"`
+	LabSessions  []LabSession `json:"labSessions,omitempty"`
+}
+
+// Submission statuses stored in the SubmissionStatus enum.
+const (
+	StatusPassed    = "passed"
+	StatusFailed    = "failed"
+	StatusPending   = "pending"
+	StatusCancelled = "cancelled"
+)
+
+type Submission struct {
+	ID             int    `json:"id"`
+	StudentID      int    `json:"studentId"`
+	QuestionID     int    `json:"questionId"`
+	LabSessionID   int    `json:"labSessionId"`
+	SubmissionTime string `json:"submissionTime"`
+	Status         string `json:"status"`
+	ResultDetails  string `json:"resultDetails"`
+	Solution       string `json:"solution"`
+	// Comment is the instructor's feedback when judging by hand.
+	Comment string `json:"comment"`
+	// Student is only filled in on the instructor endpoints.
+	Student *Student `json:"student,omitempty"`
+}
+
+// TimeFormat is how the API (Prisma) serialises dates.
+const TimeFormat = "2006-01-02T15:04:05.000Z07:00"
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// lastSubmissionID is the most recent submission sent in this session,
+// which cancel withdraws when given no ID.
+var lastSubmissionID int
+
+// cancelSubmission withdraws a submission that has no verdict yet, whether
+// it is still queued or being judged.
+func cancelSubmission(submissionID string) {
+	if studentID == "" {
+		red.Println("Student ID is not set. Use 'set studentid <ID>' first.")
+		return
+	}
+	if submissionID == "" {
+		if lastSubmissionID == 0 {
+			red.Println("Nothing submitted in this session. Usage: cancel [submission_id]")
+			return
+		}
+		submissionID = strconv.Itoa(lastSubmissionID)
+	}
+	if _, err := strconv.Atoi(submissionID); err != nil {
+		red.Println("Submission ID must be a number.")
+		return
+	}
+
+	query := url.Values{"submissionId": {submissionID}, "studentId": {studentID}}.Encode()
+	resp, err := http.Post(apiBase+"/api/stu/submission/cancel?"+query, "", nil)
+	if err != nil {
+		red.Println("Error sending request:", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		yellow.Printf("Submission %s cancelled.\n", submissionID)
+	case http.StatusNotFound:
+		red.Printf("Submission %s not found.\n", submissionID)
+	case http.StatusConflict:
+		var body struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		yellow.Println(body.Error + ".")
+	default:
+		red.Println("Error cancelling submission:", resp.Status)
+	}
+}
+
+// confirm asks a yes or no question; anything but yes is no.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := stdin.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"new_cli/api"
+	"new_cli/fakeapi"
+)
+
+func TestCancelSubmission(t *testing.T) {
+	fake := newFake(t)
+	login(t, fake)
+	fake.SetScenario(fakeapi.Scenario{Events: fakeapi.Accepted.Events, Delay: time.Hour})
+
+	resp := postSubmission(t, fake)
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	if line, _ := reader.ReadString('\n'); !strings.Contains(line, `"pushed"`) {
+		t.Fatalf("first event = %q", line)
+	}
+
+	out := capture(t, func() {
+		handleCommand("cancel")
+		handleCommand("cancel 1")
+		handleCommand("cancel 1")
+		handleCommand("cancel 99")
+		handleCommand("cancel x")
+		handleCommand("cancel 1 2")
+	})
+	checkGolden(t, "cancel", out)
+
+	// Whoever follows the submission hears of it
+	rest, _ := io.ReadAll(reader)
+	if !strings.Contains(string(rest), `"end":true`) || !strings.Contains(string(rest), `"status":"cancelled"`) {
+		t.Errorf("stream after cancelling:\n%s", rest)
+	}
+	sub, err := fake.Store.Submission(context.Background(), 1)
+	if err != nil || sub.Status != api.StatusCancelled {
+		t.Errorf("stored submission = %+v, %v; want cancelled", sub, err)
+	}
+}
+
+func TestCancelWithCtrlC(t *testing.T) {
+	fake := newFake(t)
+	login(t, fake)
+	fake.SetScenario(fakeapi.Scenario{Events: fakeapi.Accepted.Events, Delay: time.Hour})
+	oldStdin := stdin
+	stdin = bufio.NewReader(strings.NewReader("y\n"))
+	t.Cleanup(func() { stdin = oldStdin })
+
+	// Press Ctrl-C once the stream has told which submission it is
+	go func() {
+		for len(fake.Enqueued()) == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		time.Sleep(100 * time.Millisecond)
+		sessionMu.Lock()
+		defer sessionMu.Unlock()
+		if interrupts != nil {
+			interrupts <- os.Interrupt
+		}
+	}()
+
+	src := writeSolution(t)
+	out := capture(t, func() {
+		handleCommand("submit " + src + " 1")
+		handleCommand("cancel")
+	})
+	checkGolden(t, "cancel_ctrl_c", out)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	_ "github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+
+	"new_cli/server"
+	"new_cli/worker"
+)
+
+func main() {
+	addr := flag.String("addr", ":3000", "address to listen on")
+	databaseURL := flag.String("database-url", os.Getenv("DATABASE_URL"), "PostgreSQL connection string")
+	redisAddr := flag.String("redis", "localhost:6379", "Redis address")
+	stream := flag.String("stream", worker.DefaultStream, "Redis stream the workers read submissions from")
+	flag.Parse()
+
+	if *databaseURL == "" {
+		log.Fatal("DATABASE_URL or -database-url is required")
+	}
+	db, err := sql.Open("postgres", *databaseURL)
+	if err != nil {
+		log.Fatalf("Error opening database: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		log.Fatalf("Error connecting to database: %v", err)
+	}
+
+	ctx := context.Background()
+	rdb := redis.NewClient(&redis.Options{Addr: *redisAddr})
+	queue := worker.NewQueue(rdb, *stream)
+	if err := queue.Setup(ctx); err != nil {
+		log.Fatalf("Error setting up the queue: %v", err)
+	}
+
+	host, _ := os.Hostname()
+	srv := &server.Server{
+		Store:  &server.PostgresStore{DB: db},
+		Broker: &server.RedisBroker{Redis: rdb, Queue: queue, Consumer: fmt.Sprintf("%s-%d", host, os.Getpid())},
+	}
+	if err := srv.Requeue(ctx); err != nil {
+		log.Printf("Error requeueing pending submissions: %v", err)
+	}
+	go srv.RecordVerdicts(ctx)
+
+	log.Printf("Server is running on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, srv.Handler()))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"new_cli/runner"
+	"new_cli/worker"
+)
+
+func main() {
+	addr := flag.String("redis", "localhost:6379", "Redis address")
+	stream := flag.String("stream", worker.DefaultStream, "Redis stream the server adds submissions to")
+	group := flag.String("group", "judges", "consumer group shared by the workers")
+	concurrency := flag.Int("concurrency", 1, "submissions to judge at the same time")
+	timeout := flag.Duration("visibility-timeout", 2*time.Minute, "how long a submission may go without a sign of life before another worker takes it over")
+	attempts := flag.Int64("max-attempts", 3, "deliveries of a submission before it is moved to the dead letter stream")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	rdb := redis.NewClient(&redis.Options{Addr: *addr})
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		log.Fatalf("Error connecting to Redis: %v", err)
+	}
+	log.Println("Redis Client Ready")
+
+	cache, err := runner.DefaultCache()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	queue := worker.NewQueue(rdb, *stream)
+	queue.Group, queue.VisibilityTimeout, queue.MaxAttempts = *group, *timeout, *attempts
+
+	// Consumer names only need to be unique within the group
+	host, _ := os.Hostname()
+	var wg sync.WaitGroup
+	for i := 0; i < *concurrency; i++ {
+		w := &worker.Worker{
+			Redis:    rdb,
+			Queue:    queue,
+			Consumer: fmt.Sprintf("%s-%d-%d", host, os.Getpid(), i),
+			Compiler: runner.DefaultCompiler,
+			Limits:   runner.DefaultLimits,
+			Cache:    cache,
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := w.Run(ctx); err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
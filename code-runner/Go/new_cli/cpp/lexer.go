@@ -0,0 +1,232 @@
+// Package cpp splits C++ source into tokens, for highlighting solutions in
+// the terminal and comparing them with each other.
+package cpp
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Kind int
+
+const (
+	Space        Kind = iota // whitespace, including newlines
+	Comment                  // line and block comments
+	Preprocessor             // a whole directive, e.g. #include <vector>
+	Keyword
+	Ident
+	Number
+	String // string literals, including raw strings
+	Char
+	Punct // operators and punctuation
+)
+
+type Token struct {
+	Kind Kind
+	Text string
+	Line int // 1-based line the token starts on
+}
+
+var keywords = map[string]bool{}
+
+func init() {
+	for _, k := range strings.Fields(`
+		alignas alignof and and_eq asm auto bitand bitor bool break case catch char
+		char8_t char16_t char32_t class compl concept const consteval constexpr constinit
+		const_cast continue co_await co_return co_yield decltype default delete do double
+		dynamic_cast else enum explicit export extern false float for friend goto if inline
+		int long mutable namespace new noexcept not not_eq nullptr operator or or_eq private
+		protected public register reinterpret_cast requires return short signed sizeof
+		static static_assert static_cast struct switch template this thread_local throw
+		true try typedef typeid typename union unsigned using virtual void volatile wchar_t
+		while xor xor_eq`) {
+		keywords[k] = true
+	}
+}
+
+func IsKeyword(word string) bool {
+	return keywords[word]
+}
+
+// Longest first, so the scanner can take the first match.
+var operators = []string{
+	"<<=", ">>=", "->*", "...", "<=>",
+	"::", "->", "++", "--", "<<", ">>", "<=", ">=", "==", "!=", "&&", "||",
+	"+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", ".*", "##",
+}
+
+// Tokenize never fails: anything it doesn't recognise becomes a one
+// character Punct token, and unterminated literals run to the end of the
+// line (or of the source, for block comments).
+func Tokenize(src string) []Token {
+	var tokens []Token
+	line := 1
+	atLineStart := true
+	for i := 0; i < len(src); {
+		start := i
+		kind := Punct
+		c := src[i]
+		switch {
+		case c == '\n' || c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			kind = Space
+			for i < len(src) && strings.IndexByte(" \t\r\n\f\v", src[i]) >= 0 {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "//"):
+			kind = Comment
+			i = lineEnd(src, i, true)
+		case strings.HasPrefix(src[i:], "/*"):
+			kind = Comment
+			if end := strings.Index(src[i+2:], "*/"); end >= 0 {
+				i += end + 4
+			} else {
+				i = len(src)
+			}
+		case c == '#' && atLineStart:
+			kind = Preprocessor
+			i = lineEnd(src, i, true)
+		case rawStringPrefix(src[i:]) > 0:
+			kind = String
+			i = rawStringEnd(src, i+rawStringPrefix(src[i:]))
+		case c == '"' || c == '\'' || stringPrefix(src[i:]) > 0:
+			i += stringPrefix(src[i:])
+			kind = String
+			if src[i] == '\'' {
+				kind = Char
+			}
+			i = quotedEnd(src, i)
+		case isDigit(c) || c == '.' && i+1 < len(src) && isDigit(src[i+1]):
+			kind = Number
+			i = numberEnd(src, i)
+		case isIdentStart(src[i:]):
+			for i < len(src) && isIdentPart(src[i:]) {
+				_, size := utf8.DecodeRuneInString(src[i:])
+				i += size
+			}
+			kind = Ident
+			if keywords[src[start:i]] {
+				kind = Keyword
+			}
+		default:
+			i++
+			for _, op := range operators {
+				if strings.HasPrefix(src[start:], op) {
+					i = start + len(op)
+					break
+				}
+			}
+		}
+
+		text := src[start:i]
+		tokens = append(tokens, Token{Kind: kind, Text: text, Line: line})
+		line += strings.Count(text, "\n")
+		if kind == Space {
+			atLineStart = atLineStart || strings.Contains(text, "\n")
+		} else if kind != Comment {
+			atLineStart = false
+		}
+	}
+	return tokens
+}
+
+// lineEnd returns the index of the newline ending the line at i, following
+// backslash continuations if continued is set.
+func lineEnd(src string, i int, continued bool) int {
+	for {
+		nl := strings.IndexByte(src[i:], '\n')
+		if nl < 0 {
+			return len(src)
+		}
+		end := i + nl
+		if !continued || !strings.HasSuffix(strings.TrimRight(src[i:end], "\r"), "\\") {
+			return end
+		}
+		i = end + 1
+	}
+}
+
+// stringPrefix is the length of an encoding prefix (u8, u, U, L) directly
+// followed by a quote, or 0.
+func stringPrefix(s string) int {
+	for _, p := range []string{"u8", "u", "U", "L"} {
+		if strings.HasPrefix(s, p) && len(s) > len(p) && (s[len(p)] == '"' || s[len(p)] == '\'') {
+			return len(p)
+		}
+	}
+	return 0
+}
+
+// rawStringPrefix is the length of a raw string opening up to its quote,
+// e.g. 1 for R"( and 3 for u8R"(, or 0.
+func rawStringPrefix(s string) int {
+	for _, p := range []string{`R"`, `u8R"`, `uR"`, `UR"`, `LR"`} {
+		if strings.HasPrefix(s, p) {
+			return len(p) - 1
+		}
+	}
+	return 0
+}
+
+// rawStringEnd finds the end of R"delim(...)delim" given the index of its
+// opening quote.
+func rawStringEnd(src string, quote int) int {
+	open := strings.IndexByte(src[quote:], '(')
+	if open < 0 {
+		return lineEnd(src, quote, false)
+	}
+	closing := ")" + src[quote+1:quote+open] + `"`
+	end := strings.Index(src[quote+open:], closing)
+	if end < 0 {
+		return len(src)
+	}
+	return quote + open + end + len(closing)
+}
+
+// quotedEnd returns the index after the literal starting at the quote at i.
+func quotedEnd(src string, i int) int {
+	quote := src[i]
+	for i++; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		case '\n':
+			return i
+		}
+	}
+	return len(src)
+}
+
+func numberEnd(src string, i int) int {
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case isDigit(c) || c == '.' || c == '_' || unicode.IsLetter(rune(c)):
+			i++
+			if strings.IndexByte("eEpP", c) >= 0 && i < len(src) && (src[i] == '+' || src[i] == '-') {
+				i++
+			}
+		case c == '\'' && i+1 < len(src) && isDigit(src[i+1]):
+			i++ // digit separator
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isIdentPart(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
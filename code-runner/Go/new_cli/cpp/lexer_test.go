@@ -0,0 +1,61 @@
+package cpp
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	src := `#include <bits/stdc++.h>
+#define SQ(x) \
+	((x) * (x))
+using namespace std; // pull everything in
+/* block
+   comment */ int main() {
+	long long n = 1'000'000LL, f = .5e-3;
+	auto s = R"raw(a "quoted" )" string)raw";
+	char c = '\'';
+	cout << u8"héllo\n" << n >>= 2;
+}
+`
+	var got []string
+	for _, tok := range Tokenize(src) {
+		if tok.Kind != Space {
+			got = append(got, fmt.Sprintf("%d:%s:%s", tok.Line, kindNames[tok.Kind], tok.Text))
+		}
+	}
+	want := []string{
+		"1:pre:#include <bits/stdc++.h>",
+		"2:pre:#define SQ(x) \\\n\t((x) * (x))",
+		"4:kw:using", "4:kw:namespace", "4:id:std", "4:op:;", "4:com:// pull everything in",
+		"5:com:/* block\n   comment */", "6:kw:int", "6:id:main", "6:op:(", "6:op:)", "6:op:{",
+		"7:kw:long", "7:kw:long", "7:id:n", "7:op:=", "7:num:1'000'000LL", "7:op:,", "7:id:f", "7:op:=", "7:num:.5e-3", "7:op:;",
+		"8:kw:auto", "8:id:s", "8:op:=", `8:str:R"raw(a "quoted" )" string)raw"`, "8:op:;",
+		"9:kw:char", "9:id:c", "9:op:=", `9:chr:'\''`, "9:op:;",
+		"10:id:cout", "10:op:<<", `10:str:u8"héllo\n"`, "10:op:<<", "10:id:n", "10:op:>>=", "10:num:2", "10:op:;",
+		"11:op:}",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Tokenize:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestTokenizeKeepsText(t *testing.T) {
+	// Highlighting relies on the tokens adding back up to the source, even
+	// when it doesn't compile
+	for _, src := range []string{"", "int x = \"unterminated\n;", "/* open", "R\"x(open", "#", "a'b", "é = 1;"} {
+		var b strings.Builder
+		for _, tok := range Tokenize(src) {
+			b.WriteString(tok.Text)
+		}
+		if b.String() != src {
+			t.Errorf("tokens of %q join to %q", src, b.String())
+		}
+	}
+}
+
+var kindNames = map[Kind]string{
+	Space: "sp", Comment: "com", Preprocessor: "pre", Keyword: "kw", Ident: "id",
+	Number: "num", String: "str", Char: "chr", Punct: "op",
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"new_cli/runner"
+)
+
+// debugSessionLimit ends a gdb session left running; stepping through a
+// program takes a while, so it is far longer than a run's.
+const debugSessionLimit = 2 * time.Hour
+
+func printDebugUsage() {
+	red.Println("Usage: debug <file> <question_id> [--case N]")
+}
+
+// handleDebugCommand starts gdb on a test case the last verdict of a
+// question failed, with the program's stdin redirected from its input.
+func handleDebugCommand(args []string) {
+	if len(args) < 2 || strings.HasPrefix(args[0], "-") || strings.HasPrefix(args[1], "-") {
+		printDebugUsage()
+		return
+	}
+	filePath, questionID := args[0], args[1]
+
+	fs := flag.NewFlagSet("debug", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	caseNum := fs.Int("case", 1, "failing test case of the last verdict")
+	if err := fs.Parse(args[2:]); err != nil || fs.NArg() != 0 {
+		printDebugUsage()
+		return
+	}
+	if !strings.HasSuffix(filePath, ".cpp") {
+		red.Println("Error: input file must have a .cpp extension")
+		return
+	}
+
+	failed, ok := failedByQuestion[questionID]
+	if !ok {
+		red.Printf("No verdict for question %s in this session. Submit it, or 'wait' for a submission to it, first.\n", questionID)
+		return
+	}
+	if *caseNum < 1 || *caseNum > len(failed) {
+		red.Printf("No failing test case %d in the last verdict of question %s (%d available).\n", *caseNum, questionID, len(failed))
+		return
+	}
+	tc := failed[*caseNum-1]
+
+	gdb, err := exec.LookPath("gdb")
+	if err != nil {
+		red.Println("gdb is not installed. Install it, e.g. with 'sudo apt install gdb', and try again.")
+		return
+	}
+
+	execPath, err := buildWith(runner.DefaultCompiler.WithDebugInfo(), filePath)
+	if err != nil {
+		red.Println("Error compiling program:", err)
+		return
+	}
+
+	ws, err := runner.NewWorkspace("debug")
+	if err != nil {
+		red.Println("Error creating workspace:", err)
+		return
+	}
+	defer ws.Close()
+
+	// gdb names the program after its file, so give it a better name than
+	// the cache does, next to the test case it reads
+	baseName := filepath.Base(filePath)
+	program := filepath.Join(ws.Dir, strings.TrimSuffix(baseName, filepath.Ext(baseName)))
+	if err := runner.CopyFile(execPath, program, 0o755); err != nil {
+		red.Println("Error preparing program:", err)
+		return
+	}
+	files := map[string]string{"input.txt": tc.Input, "expected.txt": tc.Expected}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(ws.Dir, name), []byte(content), 0o644); err != nil {
+			red.Println("Error writing test case:", err)
+			return
+		}
+	}
+
+	fmt.Printf("Test case %d failed: %s\n", *caseNum, tc.Reason)
+	fmt.Println("The program reads input.txt, the test case's input. Set breakpoints with 'break <line>', then 'run';")
+	fmt.Println("'shell cat expected.txt' shows the expected output, and 'quit' returns here.")
+
+	cmd := exec.Command(gdb, "-q", "-ex", "set args < input.txt", filepath.Base(program))
+	cmd.Dir = ws.Dir
+	yellow.Printf("Starting gdb (press %s to abort)\n", abortKeyName)
+	if err := runInPty(cmd, os.Stdout, debugSessionLimit); err != nil {
+		red.Println("\nError running gdb:", err)
+	}
+}
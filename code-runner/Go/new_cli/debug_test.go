@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	"new_cli/fakeapi"
+)
+
+func TestDebugNeedsAFailedVerdict(t *testing.T) {
+	fake := newFake(t)
+	login(t, fake)
+	fake.SetScenario(fakeapi.PartialPass)
+	solution := writeSolution(t)
+
+	out := capture(t, func() {
+		handleCommand("debug")
+		handleCommand("debug " + solution + " 1 --case")
+		handleCommand("debug " + solution + " 1")
+		handleCommand("submit " + solution + " 1")
+		handleCommand("debug " + solution + " 1 --case 2")
+		handleCommand("debug " + solution + " 2")
+		handleCommand("debug solution.txt 1")
+	})
+	checkGolden(t, "debug", out)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"new_cli/runner"
+)
+
+var (
+	errorLabel   = color.New(color.FgRed, color.Bold)
+	warningLabel = color.New(color.FgMagenta, color.Bold)
+	noteLabel    = color.New(color.FgCyan, color.Bold)
+)
+
+// errCompile is returned once the diagnostics of a failed build have been
+// shown, so callers don't print the compiler output again.
+var errCompile = errors.New("compilation failed")
+
+// compileFailure shows the diagnostics of a failed build. It returns
+// errCompile if there were any to show, and err as it is otherwise, e.g.
+// for linker errors.
+func compileFailure(err error) error {
+	var compileErr *runner.CompileError
+	if !errors.As(err, &compileErr) || len(compileErr.Diagnostics) == 0 {
+		return err
+	}
+	printDiagnostics(compileErr.Diagnostics)
+	return errCompile
+}
+
+// printDiagnostics shows compiler diagnostics the way g++ does: the
+// location and message, then the line it is about with a caret under the
+// column.
+func printDiagnostics(diags []runner.Diagnostic) {
+	for _, d := range diags {
+		location := fmt.Sprintf("%s:%d:", d.File, d.Line)
+		if d.Column > 0 {
+			location += fmt.Sprintf("%d:", d.Column)
+		}
+		label := errorLabel
+		switch d.Severity {
+		case "warning":
+			label = warningLabel
+		case "note":
+			label = noteLabel
+		}
+		fmt.Printf("%s %s %s\n", bold.Sprint(location), label.Sprint(d.Severity+":"), d.Message)
+
+		if d.Source == "" {
+			continue
+		}
+		fmt.Printf("%5d | %s\n", d.Line, expandTabs(d.Source))
+		if d.Column > 0 {
+			fmt.Printf("%5s | %s%s\n", "", strings.Repeat(" ", d.Column-1), green.Sprint("^"))
+		}
+	}
+}
+
+// expandTabs replaces tabs with spaces up to the next multiple of 8, which
+// is how g++ counts columns.
+func expandTabs(line string) string {
+	var b strings.Builder
+	column := 0
+	for _, r := range line {
+		if r == '\t' {
+			n := 8 - column%8
+			b.WriteString(strings.Repeat(" ", n))
+			column += n
+			continue
+		}
+		b.WriteRune(r)
+		column++
+	}
+	return b.String()
+}
+
+// maxCrashFrames is how much of a backtrace is shown; the calls further out
+// rarely matter.
+const maxCrashFrames = 8
+
+// printCrash shows where a sanitizer build crashed, innermost call first,
+// with the source line of each call.
+func printCrash(crash *runner.Crash) {
+	errorLabel.Print("Crashed: ")
+	fmt.Print(crash.Error)
+	if explanation := crash.Explanation(); explanation != "" {
+		fmt.Print(" - the program " + explanation)
+	}
+	fmt.Println()
+	for i, f := range crash.Frames {
+		if i == maxCrashFrames {
+			fmt.Printf("  ... %d more\n", len(crash.Frames)-i)
+			break
+		}
+		fmt.Printf("  in %s at %s\n", f.Function, bold.Sprintf("%s:%d", f.File, f.Line))
+		if f.Source != "" {
+			fmt.Printf("%5d | %s\n", f.Line, expandTabs(f.Source))
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"new_cli/runner"
+)
+
+func TestCompileErrorDiagnostics(t *testing.T) {
+	if _, err := exec.LookPath("g++"); err != nil {
+		t.Skip("g++ not installed")
+	}
+	newFake(t)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "bad.cpp")
+	code := "#include <iostream>\nint main() {\n\tint x = \"a\";\n  return y\n}\n"
+	if err := os.WriteFile(src, []byte(code), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := capture(t, func() { handleCommand("run " + src + " --input " + src) })
+	out = strings.ReplaceAll(out, dir+"/", "")
+
+	// The wording of the messages is up to the g++ version
+	for _, want := range []string{
+		"bad.cpp:3:17: error: ",
+		"    3 |         int x = \"a\";\n      |                 ^\n",
+		"bad.cpp:4:10: error: 'y' was not declared in this scope\n    4 |   return y\n      |          ^\n",
+		"Error running program: compilation failed\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestPrintDiagnostics(t *testing.T) {
+	out := capture(t, func() {
+		printDiagnostics([]runner.Diagnostic{
+			{File: "a.cpp", Line: 2, Column: 10, Severity: "warning", Message: "unused variable 'n'", Source: "\tint n, n2 = 0;"},
+			{File: "a.cpp", Line: 7, Severity: "note", Message: "declared here", Source: "int n;"},
+			{File: "a.h", Line: 1, Column: 1, Severity: "error", Message: "unknown type name"},
+		})
+	})
+	want := "a.cpp:2:10: warning: unused variable 'n'\n" +
+		"    2 |         int n, n2 = 0;\n" +
+		"      |          ^\n" +
+		"a.cpp:7: note: declared here\n" +
+		"    7 | int n;\n" +
+		"a.h:1:1: error: unknown type name\n"
+	if out != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestRunCrash(t *testing.T) {
+	if _, err := exec.LookPath("g++"); err != nil {
+		t.Skip("g++ not installed")
+	}
+	newFake(t)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "crash.cpp")
+	code := "#include <cstdio>\nint at(int *p, int i) {\n  return p[i];\n}\nint main() {\n  int n;\n  scanf(\"%d\", &n);\n  int *p = n ? new int[n] : nullptr;\n  printf(\"%d\\n\", at(p, n));\n}\n"
+	input := filepath.Join(dir, "in.txt")
+	for path, content := range map[string]string{src: code, input: "0\n"} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := capture(t, func() {
+		handleCommand("run " + src + " --input " + input)
+		handleCommand("run " + src + " --input " + input + " --debug")
+	})
+	out = strings.ReplaceAll(out, dir+"/", "")
+	for _, want := range []string{
+		"Error running program: Segmentation fault: the program used memory it doesn't own",
+		"(SIGSEGV)\nRun it again with --debug to see where it crashed.\n",
+		// UBSan or ASan, whichever catches it first
+		"Crashed: ",
+		"  in at(int*, int) at crash.cpp:3\n" +
+			"    3 |   return p[i];\n" +
+			"  in main at crash.cpp:9\n" +
+			"    9 |   printf(\"%d\\n\", at(p, n));\n",
+		"Error running program: program crashed\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestPrintCrashOfVerdict(t *testing.T) {
+	newFake(t)
+	verdict := `{"passed":[],"failed":[{"passed":false,"input":"3","verdict":"RE","reason":"Aborted","crash":` +
+		`{"error":"heap-buffer-overflow","frames":[{"function":"main","file":"solution.cpp","line":4,"source":"\treturn v[3];"}]}}],"end":true}`
+	out := capture(t, func() { rememberVerdict(verdict) })
+	want := "Test case 1:\n" +
+		"Crashed: heap-buffer-overflow - the program read or wrote past the end of an array allocated with new or a vector\n" +
+		"  in main at solution.cpp:4\n" +
+		"    4 |         return v[3];\n" +
+		"Replay a failing test case locally with: run <file> --case <1-1>\n"
+	if out != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+}
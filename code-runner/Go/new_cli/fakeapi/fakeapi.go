@@ -0,0 +1,268 @@
+// Package fakeapi runs the Lab API in-process for CLI tests. It serves the
+// real student handlers from the server package over an in-memory store,
+// with a scripted judge in place of Redis and the worker.
+package fakeapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"new_cli/api"
+	"new_cli/server"
+	"new_cli/worker"
+)
+
+// Scenario is what the fake judge publishes for every submission.
+type Scenario struct {
+	Events []string
+	Delay  time.Duration // before each event
+
+	// Queue is where the submission stands each time the server asks; the
+	// judge starts once the server has seen them all.
+	Queue []worker.Position
+}
+
+var (
+	Accepted = Scenario{Events: []string{
+		`{"start":true}`,
+		`{"status":"success","output":"Compiled successfully"}`,
+		`{"test":1,"total":2,"verdict":"OK","time":1}`,
+		`{"test":2,"total":2,"verdict":"OK","time":1}`,
+		`{"passed":[{"passed":true,"input":"1 2","output":"3\n","expected":"3","verdict":"OK","time":1},{"passed":true,"input":"5 7","output":"12\n","expected":"12","verdict":"OK","time":1}],"failed":[],"time":4,"studentId":"1","questionId":"1","end":true,"status":"passed"}`,
+	}}
+
+	CompileError = Scenario{Events: []string{
+		`{"start":true}`,
+		`{"status":"failed","output":"compilation error:\nsolution.cpp:3:11: error: expected ';' before '}' token\n","end":true,` +
+			`"diagnostics":[{"file":"solution.cpp","line":3,"column":11,"severity":"error","message":"expected ';' before '}' token","source":"  return 0"}]}`,
+	}}
+
+	PartialPass = Scenario{Events: []string{
+		`{"start":true}`,
+		`{"status":"success","output":"Compiled successfully"}`,
+		`{"test":1,"total":2,"verdict":"OK","time":1}`,
+		`{"test":2,"total":2,"verdict":"WA","time":1}`,
+		`{"passed":[{"passed":true,"input":"1 2","output":"3\n","expected":"3","verdict":"OK","time":1}],"failed":[{"passed":false,"input":"5 7","output":"13\n","expected":"12","reason":"output differs from expected answer","verdict":"WA","time":1}],"time":4,"studentId":"1","questionId":"1","end":true,"status":"failed"}`,
+	}}
+
+	TimeLimit = Scenario{Events: []string{
+		`{"start":true}`,
+		`{"status":"success","output":"Compiled successfully"}`,
+		`{"test":1,"total":2,"verdict":"TLE","time":2000}`,
+		`{"test":2,"total":2,"verdict":"TLE","time":2000}`,
+		`{"passed":[],"failed":[{"passed":false,"input":"1 2","output":"","expected":"3","reason":"Time Limit Exceeded","verdict":"TLE","time":2000},{"passed":false,"input":"5 7","output":"","expected":"12","reason":"Time Limit Exceeded","verdict":"TLE","time":2000}],"time":4001,"studentId":"1","questionId":"1","end":true,"status":"failed"}`,
+	}}
+
+	SlowStream = Scenario{Events: Accepted.Events, Delay: 200 * time.Millisecond}
+	Queued     = Scenario{Events: Accepted.Events, Queue: []worker.Position{
+		{Ahead: 2, ETA: 95 * time.Second},
+		{Ahead: 1, ETA: 40 * time.Second},
+		{Ahead: 0},
+	}}
+)
+
+// Now is the fixed clock of the fake server; the seeded lab session is on
+// this day.
+var Now = time.Date(2024, 10, 15, 9, 0, 0, 0, time.UTC)
+
+type Server struct {
+	*httptest.Server
+	Store  *server.MemoryStore
+	broker *broker
+	stop   context.CancelFunc // stops recording verdicts
+}
+
+// New starts a server seeded with student 1 enrolled in today's lab session
+// 1, which has a test case based question 1 and an output based question 2.
+// Student 2 exists but isn't enrolled anywhere.
+func New() *Server {
+	store := server.NewMemoryStore()
+	store.Now = func() time.Time { return Now }
+	store.AddStudent(api.Student{ID: 1, Name: "Test Student", Email: "test@example.com", EnrollmentNumber: "EN001", DepartmentID: 1})
+	store.AddStudent(api.Student{ID: 2, Name: "Other Student", Email: "other@example.com", EnrollmentNumber: "EN002", DepartmentID: 1})
+	store.AddProgram(api.Program{ID: 1, Name: "Programming Lab", ProgramCode: "CS101", DepartmentID: 1, CreatedAt: Now.Format(api.TimeFormat)}, 1)
+	store.AddInstructor(api.Instructor{ID: 1, Name: "Test Instructor", Email: "instructor@example.com", DepartmentID: 1})
+	store.AddLabSession(api.LabSession{ID: 1, ProgramID: 1, InstructorID: 1, SessionDate: Now.Format(api.TimeFormat), Description: "Week 1"})
+	store.AddQuestion(api.Question{
+		ID: 1, InstructorID: 1, LabSessionID: 1, TestCaseBased: true,
+		Description:   "output the sum of two numbers",
+		InputsOutputs: `[{"input":"1 2","output":"3"},{"input":"5 7","output":"12"}]`,
+	})
+	store.AddQuestion(api.Question{
+		ID: 2, InstructorID: 1, LabSessionID: 1,
+		Description:   "print a greeting",
+		InputsOutputs: `[]`,
+	})
+
+	b := &broker{subs: map[string][]chan string{}, scenario: Accepted, jobs: map[int]*job{}, verdicts: make(chan *worker.Verdict, 16)}
+	srv := &server.Server{
+		Store:  store,
+		Broker: b,
+		Now:    func() time.Time { return Now },
+
+		PollInterval: 20 * time.Millisecond,
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	go srv.RecordVerdicts(ctx)
+	return &Server{Server: httptest.NewServer(srv.Handler()), Store: store, broker: b, stop: stop}
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.Server.Close()
+	s.stop()
+}
+
+// SetScenario changes what the judge reports for the next submissions.
+func (s *Server) SetScenario(sc Scenario) {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.scenario = sc
+}
+
+// Enqueued returns the submissions sent to the judge so far.
+func (s *Server) Enqueued() []worker.Submission {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	return append([]worker.Submission(nil), s.broker.enqueued...)
+}
+
+// broker plays the part of Redis and the worker.
+type broker struct {
+	mu       sync.Mutex
+	subs     map[string][]chan string
+	scenario Scenario
+	enqueued []worker.Submission
+	jobs     map[int]*job // by submission ID
+	verdicts chan *worker.Verdict
+}
+
+// job is a submission handed to the judge.
+type job struct {
+	positions []worker.Position // still to be reported
+	start     chan struct{}     // closed once the last position was reported
+	cancel    chan struct{}     // closed when the submission is cancelled
+}
+
+func (b *broker) Enqueue(ctx context.Context, sub worker.Submission) error {
+	b.mu.Lock()
+	b.enqueued = append(b.enqueued, sub)
+	sc := b.scenario
+	j := &job{positions: sc.Queue, start: make(chan struct{}), cancel: make(chan struct{})}
+	if len(sc.Queue) == 0 {
+		close(j.start)
+	}
+	b.jobs[sub.SubmissionID] = j
+	b.mu.Unlock()
+
+	go func() {
+		select {
+		case <-j.start:
+		case <-j.cancel:
+			return
+		}
+		for _, event := range sc.Events {
+			select {
+			case <-time.After(sc.Delay):
+			case <-j.cancel:
+				return
+			}
+			var final struct {
+				End bool `json:"end"`
+			}
+			if json.Unmarshal([]byte(event), &final) == nil && final.End {
+				b.verdicts <- &worker.Verdict{SubmissionID: sub.SubmissionID, Event: event}
+			}
+			b.Publish(context.Background(), sub.Channel(), []byte(event))
+		}
+	}()
+	return nil
+}
+
+func (b *broker) Subscribe(ctx context.Context, channel string) (<-chan string, error) {
+	ch := make(chan string, 16)
+	b.mu.Lock()
+	b.subs[channel] = append(b.subs[channel], ch)
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		subs := b.subs[channel]
+		for i, c := range subs {
+			if c == ch {
+				b.subs[channel] = append(subs[:i], subs[i+1:]...)
+				break
+			}
+		}
+		close(ch)
+	}()
+	return ch, nil
+}
+
+func (b *broker) Position(ctx context.Context, submissionID int) (*worker.Position, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	j := b.jobs[submissionID]
+	if j == nil || len(j.positions) == 0 {
+		return nil, nil
+	}
+	pos := j.positions[0]
+	j.positions = j.positions[1:]
+	if len(j.positions) == 0 {
+		close(j.start)
+	}
+	return &pos, nil
+}
+
+func (b *broker) Cancel(ctx context.Context, submissionID int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if j := b.jobs[submissionID]; j != nil {
+		close(j.cancel)
+		j.positions = nil
+		delete(b.jobs, submissionID)
+	}
+	return nil
+}
+
+func (b *broker) Publish(ctx context.Context, channel string, message []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, ch := range b.subs[channel] {
+		select {
+		case ch <- string(message):
+		default:
+		}
+	}
+	return nil
+}
+
+func (b *broker) NextVerdict(ctx context.Context, block time.Duration) (*worker.Verdict, error) {
+	select {
+	case v := <-b.verdicts:
+		return v, nil
+	case <-time.After(block):
+		return nil, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (b *broker) AckVerdict(ctx context.Context, v *worker.Verdict) error {
+	return nil
+}
+
+func (b *broker) Known(ctx context.Context) (map[int]bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	known := map[int]bool{}
+	for id := range b.jobs {
+		known[id] = true
+	}
+	return known, nil
+}
@@ -3,15 +3,21 @@ module new_cli
 go 1.22.6
 
 require (
+	github.com/alicebob/miniredis/v2 v2.35.0
+	github.com/creack/pty v1.1.23
+	github.com/fatih/color v1.17.0
+	github.com/lib/pq v1.10.9
+	github.com/manifoldco/promptui v0.9.0
+	github.com/redis/go-redis/v9 v9.6.1
+	golang.org/x/sys v0.25.0
+	golang.org/x/term v0.24.0
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
 	github.com/chzyer/readline v0.0.0-20180603132655-2972be24d48e // indirect
-	github.com/creack/pty v1.1.23 // indirect
-	github.com/fatih/color v1.17.0 // indirect
-	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/manifoldco/promptui v0.9.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
-	github.com/spf13/cobra v1.8.1 // indirect
-	github.com/spf13/pflag v1.0.5 // indirect
-	golang.org/x/sys v0.25.0 // indirect
-	golang.org/x/term v0.24.0 // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
 )
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"new_cli/api"
+	"new_cli/grades"
+)
+
+func handleExportCommand(args []string) {
+	if len(args) < 2 || args[0] != "grades" {
+		red.Println("Usage: instructor export grades <labSessionID> [--format csv|xlsx|json] [--out file]")
+		red.Println("       [--attempt best|latest] [--points n] [--partial] [--deadline HH:MM] [--late-penalty percent]")
+		return
+	}
+	partial, flags := takeFlag(args[2:], "partial")
+	values, err := parseFlags(flags, "format", "out", "attempt", "points", "deadline", "late-penalty")
+	if err != nil {
+		red.Println("Error:", err)
+		return
+	}
+
+	rules := grades.DefaultRules
+	rules.Partial = partial
+	switch values["attempt"] {
+	case "", grades.Best:
+	case grades.Latest:
+		rules.Attempt = grades.Latest
+	default:
+		red.Println("--attempt must be best or latest.")
+		return
+	}
+	if values["points"] != "" {
+		if rules.Points, err = strconv.ParseFloat(values["points"], 64); err != nil || rules.Points <= 0 {
+			red.Println("--points must be a positive number.")
+			return
+		}
+	}
+	if values["late-penalty"] != "" {
+		penalty, err := strconv.ParseFloat(strings.TrimSuffix(values["late-penalty"], "%"), 64)
+		if err != nil || penalty < 0 || penalty > 100 {
+			red.Println("--late-penalty must be a percentage between 0 and 100.")
+			return
+		}
+		rules.LatePenalty = penalty / 100
+		if values["deadline"] == "" {
+			red.Println("--late-penalty needs a --deadline to be late after.")
+			return
+		}
+	}
+
+	format := values["format"]
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(values["out"]), ".")
+	}
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "xlsx" && format != "json" {
+		red.Println("--format must be csv, xlsx or json.")
+		return
+	}
+	out := values["out"]
+	if out == "" {
+		out = fmt.Sprintf("labsession-%s-grades.%s", args[1], format)
+	}
+
+	exportGrades(args[1], rules, values["deadline"], format, out)
+}
+
+// parseDeadline reads a time on the day of the session ("17:30") or a full
+// local date and time ("2024-10-15T17:30").
+func parseDeadline(value, sessionDate string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02T15:04", value, time.Local); err == nil {
+		return t, nil
+	}
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid deadline %q, use HH:MM or YYYY-MM-DDTHH:MM", value)
+	}
+	day, err := time.Parse(api.TimeFormat, sessionDate)
+	if err != nil {
+		return time.Time{}, err
+	}
+	day = day.Local()
+	return time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, time.Local), nil
+}
+
+func exportGrades(labSessionID string, rules grades.Rules, deadline, format, out string) {
+	var session api.LabSession
+	if err := instructorRequest("GET", "/labsession?labSessionId="+url.QueryEscape(labSessionID), nil, &session); err != nil {
+		red.Println("Error fetching lab session:", err)
+		return
+	}
+	if deadline != "" {
+		var err error
+		if rules.Deadline, err = parseDeadline(deadline, session.SessionDate); err != nil {
+			red.Println("Error:", err)
+			return
+		}
+	}
+
+	// The attendance list is the roster: everyone enrolled, by enrollment
+	// number
+	var attendance struct {
+		Attendance map[string]bool `json:"attendance"`
+	}
+	if err := instructorRequest("GET", "/attendance?labSessionId="+url.QueryEscape(labSessionID), nil, &attendance); err != nil {
+		red.Println("Error fetching attendance:", err)
+		return
+	}
+	var students []api.Student
+	for enrollment := range attendance.Attendance {
+		students = append(students, api.Student{EnrollmentNumber: enrollment})
+	}
+	sort.Slice(students, func(i, j int) bool { return students[i].EnrollmentNumber < students[j].EnrollmentNumber })
+
+	var submissions []api.Submission
+	if err := instructorRequest("GET", "/submissions?labSessionId="+url.QueryEscape(labSessionID), nil, &submissions); err != nil {
+		red.Println("Error fetching submissions:", err)
+		return
+	}
+
+	sheet := grades.Build(session, students, submissions, rules)
+	if err := writeSheet(sheet, format, out); err != nil {
+		red.Println("Error writing grades:", err)
+		return
+	}
+
+	if out != "-" {
+		green.Printf("Wrote grades of %d students for %d questions to %s.\n", len(sheet.Rows), len(sheet.Questions), out)
+	}
+	if len(sheet.Rows) > 0 && out != "-" {
+		total := 0.0
+		for _, row := range sheet.Rows {
+			total += row.Total
+		}
+		fmt.Printf("Average %.1f of %g points.\n", total/float64(len(sheet.Rows)), sheet.MaxTotal())
+	}
+}
+
+// writeSheet writes to out, or to stdout if out is "-". A file is only
+// replaced once the whole sheet has been written.
+func writeSheet(sheet *grades.Sheet, format, out string) error {
+	write := map[string]func(io.Writer) error{
+		"csv":  sheet.WriteCSV,
+		"xlsx": sheet.WriteXLSX,
+		"json": sheet.WriteJSON,
+	}[format]
+	if out == "-" {
+		return write(os.Stdout)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(out), ".grades-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	tmp.Chmod(0o644)
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), out)
+}
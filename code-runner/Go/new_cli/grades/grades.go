@@ -0,0 +1,159 @@
+// Package grades turns the submissions of a lab session into marks: one row
+// per student, one cell per question, scored by configurable rules.
+package grades
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+
+	"new_cli/api"
+)
+
+// Which attempt of a question counts.
+const (
+	Best   = "best"
+	Latest = "latest"
+)
+
+type Rules struct {
+	Points  float64 // for a passed question
+	Attempt string  // Best or Latest
+	// Partial gives test case questions a share of the points for the
+	// share of test cases passed.
+	Partial bool
+	// Attempts after Deadline lose LatePenalty (0-1) of their score. A zero
+	// Deadline means nothing is late.
+	Deadline    time.Time
+	LatePenalty float64
+}
+
+var DefaultRules = Rules{Points: 10, Attempt: Best}
+
+type Cell struct {
+	Status    string // of the attempt that counts, "" if none
+	Score     float64
+	Attempts  int
+	FirstPass string // submission time of the first passed attempt
+	Late      bool   // the attempt that counts was late
+}
+
+type Row struct {
+	Student api.Student
+	Cells   []Cell // in the order of Sheet.Questions
+	Total   float64
+}
+
+type Sheet struct {
+	LabSession api.LabSession
+	Questions  []api.Question
+	Rules      Rules
+	Rows       []Row
+}
+
+func (s *Sheet) MaxTotal() float64 {
+	return s.Rules.Points * float64(len(s.Questions))
+}
+
+// Build scores every student's submissions to the session's questions.
+// Students who never submitted get empty rows; rows are sorted by
+// enrollment number.
+func Build(session api.LabSession, students []api.Student, subs []api.Submission, rules Rules) *Sheet {
+	sheet := &Sheet{LabSession: session, Questions: session.Questions, Rules: rules}
+	column := map[int]int{}
+	for i, q := range session.Questions {
+		column[q.ID] = i
+	}
+
+	// Students are matched by enrollment number, as the roster may only
+	// have that
+	rows := map[string]*Row{}
+	for _, st := range students {
+		rows[studentKey(st)] = &Row{Student: st, Cells: make([]Cell, len(session.Questions))}
+	}
+
+	subs = append([]api.Submission(nil), subs...)
+	sort.SliceStable(subs, func(i, j int) bool { return subs[i].ID < subs[j].ID })
+	for _, sub := range subs {
+		col, ok := column[sub.QuestionID]
+		if !ok {
+			continue
+		}
+		st := api.Student{ID: sub.StudentID}
+		if sub.Student != nil {
+			st = *sub.Student
+		}
+		row := rows[studentKey(st)]
+		if row == nil {
+			// Submitted without being enrolled; still worth a row
+			row = &Row{Cells: make([]Cell, len(session.Questions))}
+			rows[studentKey(st)] = row
+		}
+		if row.Student.ID == 0 {
+			row.Student = st
+		}
+		rules.apply(&row.Cells[col], sub)
+	}
+
+	for _, row := range rows {
+		for _, c := range row.Cells {
+			row.Total += c.Score
+		}
+		sheet.Rows = append(sheet.Rows, *row)
+	}
+	sort.Slice(sheet.Rows, func(i, j int) bool {
+		a, b := sheet.Rows[i].Student, sheet.Rows[j].Student
+		if a.EnrollmentNumber != b.EnrollmentNumber {
+			return a.EnrollmentNumber < b.EnrollmentNumber
+		}
+		return a.ID < b.ID
+	})
+	return sheet
+}
+
+func studentKey(st api.Student) string {
+	if st.EnrollmentNumber != "" {
+		return st.EnrollmentNumber
+	}
+	return "#" + strconv.Itoa(st.ID)
+}
+
+// apply adds an attempt, in submission order, to a cell. Submissions
+// without a verdict, pending or cancelled, are not attempts.
+func (r Rules) apply(c *Cell, sub api.Submission) {
+	if sub.Status != api.StatusPassed && sub.Status != api.StatusFailed {
+		return
+	}
+	at, _ := time.Parse(api.TimeFormat, sub.SubmissionTime)
+	c.Attempts++
+	if sub.Status == api.StatusPassed && c.FirstPass == "" {
+		c.FirstPass = sub.SubmissionTime
+	}
+
+	late := !r.Deadline.IsZero() && at.After(r.Deadline)
+	score := r.score(sub)
+	if late {
+		score *= 1 - r.LatePenalty
+	}
+	if r.Attempt == Latest || c.Attempts == 1 || score >= c.Score {
+		c.Status, c.Score, c.Late = sub.Status, score, late
+	}
+}
+
+func (r Rules) score(sub api.Submission) float64 {
+	if sub.Status == api.StatusPassed {
+		return r.Points
+	}
+	if !r.Partial || sub.Status != api.StatusFailed {
+		return 0
+	}
+	var report struct {
+		Passed []json.RawMessage `json:"passed"`
+		Failed []json.RawMessage `json:"failed"`
+	}
+	if json.Unmarshal([]byte(sub.ResultDetails), &report) != nil || len(report.Passed)+len(report.Failed) == 0 {
+		return 0
+	}
+	return r.Points * float64(len(report.Passed)) / float64(len(report.Passed)+len(report.Failed))
+}
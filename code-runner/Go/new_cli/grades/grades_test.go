@@ -0,0 +1,148 @@
+package grades
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"new_cli/api"
+)
+
+var session = api.LabSession{
+	ID: 1, Description: "Week 1", SessionDate: "2024-10-15T00:00:00.000Z",
+	Questions: []api.Question{{ID: 1, TestCaseBased: true}, {ID: 2}},
+}
+
+func submission(id, student, question int, status, at, details string) api.Submission {
+	return api.Submission{
+		ID: id, StudentID: student, QuestionID: question, Status: status, ResultDetails: details,
+		SubmissionTime: "2024-10-15T" + at + ":00.000Z",
+		Student:        &api.Student{ID: student, EnrollmentNumber: fmt.Sprintf("EN%03d", student)},
+	}
+}
+
+var subs = []api.Submission{
+	submission(1, 1, 1, api.StatusFailed, "09:10", `{"passed":[{}],"failed":[{},{},{}]}`),
+	submission(2, 1, 1, api.StatusPassed, "09:20", ""),
+	submission(3, 1, 1, api.StatusFailed, "09:30", `{"passed":[],"failed":[{}]}`),
+	submission(4, 1, 2, api.StatusPending, "09:40", ""),
+	submission(5, 2, 1, api.StatusFailed, "09:15", `{"passed":[{},{},{}],"failed":[{}]}`),
+	submission(6, 2, 2, api.StatusPassed, "11:00", ""),
+	submission(7, 9, 2, api.StatusPassed, "09:00", ""),
+	submission(8, 2, 7, api.StatusPassed, "09:00", ""), // question not in the session
+	submission(9, 1, 1, api.StatusCancelled, "09:35", ""),
+	submission(10, 2, 2, api.StatusPending, "11:10", ""),
+}
+
+// summary renders rows as "EN001 10+0=10 ..." with the status and attempts
+// of every cell.
+func summary(s *Sheet) string {
+	var lines []string
+	for _, row := range s.Rows {
+		line := row.Student.EnrollmentNumber
+		for _, c := range row.Cells {
+			line += fmt.Sprintf(" %s/%g/%d", c.Status, c.Score, c.Attempts)
+			if c.Late {
+				line += "/late"
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%s =%g", line, row.Total))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestBuild(t *testing.T) {
+	roster := []api.Student{{EnrollmentNumber: "EN003"}, {EnrollmentNumber: "EN002"}, {EnrollmentNumber: "EN001"}}
+	deadline := time.Date(2024, 10, 15, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		rules Rules
+		want  string
+	}{
+		{"best", DefaultRules, `
+EN001 passed/10/3 /0/0 =10
+EN002 failed/0/1 passed/10/1 =10
+EN003 /0/0 /0/0 =0
+EN009 /0/0 passed/10/1 =10`},
+		{"latest", Rules{Points: 10, Attempt: Latest}, `
+EN001 failed/0/3 /0/0 =0
+EN002 failed/0/1 passed/10/1 =10
+EN003 /0/0 /0/0 =0
+EN009 /0/0 passed/10/1 =10`},
+		{"partial and late", Rules{Points: 4, Attempt: Best, Partial: true, Deadline: deadline, LatePenalty: 0.25}, `
+EN001 passed/4/3 /0/0 =4
+EN002 failed/3/1 passed/3/1/late =6
+EN003 /0/0 /0/0 =0
+EN009 /0/0 passed/4/1 =4`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := summary(Build(session, roster, subs, tt.rules))
+			if want := strings.TrimPrefix(tt.want, "\n"); got != want {
+				t.Errorf("got\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	sheet := Build(session, nil, subs[:3], Rules{Points: 10, Attempt: Best, Partial: true})
+	var b bytes.Buffer
+	if err := sheet.WriteCSV(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `Enrollment,Name,Q1 score,Q1 status,Q1 attempts,Q1 first pass,Q2 score,Q2 status,Q2 attempts,Q2 first pass,Total
+EN001,,10,passed,3,2024-10-15T09:20:00.000Z,0,,0,,10
+`
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestWriteXLSX(t *testing.T) {
+	sheet := Build(session, nil, subs, DefaultRules)
+	sheet.Rows[0].Student.Name = `Ann <"&">`
+	var b bytes.Buffer
+	if err := sheet.WriteXLSX(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	var worksheet string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			r, _ := f.Open()
+			data, _ := io.ReadAll(r)
+			worksheet = string(data)
+		}
+	}
+	if got := strings.Join(names, " "); got != "[Content_Types].xml _rels/.rels xl/workbook.xml xl/_rels/workbook.xml.rels xl/worksheets/sheet1.xml" {
+		t.Errorf("parts: %s", got)
+	}
+	for _, want := range []string{
+		`<c r="K1" t="inlineStr"><is><t>Total</t></is></c>`,
+		`<c r="B2" t="inlineStr"><is><t>Ann &lt;&#34;&amp;&#34;&gt;</t></is></c>`,
+		`<c r="C2"><v>10</v></c>`,
+	} {
+		if !strings.Contains(worksheet, want) {
+			t.Errorf("worksheet lacks %s", want)
+		}
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(i); got != want {
+			t.Errorf("columnName(%d) = %s, want %s", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package grades
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"new_cli/api"
+)
+
+// Table lays the sheet out as rows of strings and float64s, headers first:
+// each question gets a score, status, attempts and first pass column.
+func (s *Sheet) Table() [][]any {
+	header := []any{"Enrollment", "Name"}
+	for _, q := range s.Questions {
+		id := fmt.Sprintf("Q%d", q.ID)
+		header = append(header, id+" score", id+" status", id+" attempts", id+" first pass")
+	}
+	header = append(header, "Total")
+
+	table := [][]any{header}
+	for _, row := range s.Rows {
+		line := []any{row.Student.EnrollmentNumber, row.Student.Name}
+		for _, c := range row.Cells {
+			status := c.Status
+			if c.Late {
+				status += " (late)"
+			}
+			line = append(line, round(c.Score), status, float64(c.Attempts), c.FirstPass)
+		}
+		table = append(table, append(line, round(row.Total)))
+	}
+	return table
+}
+
+func round(score float64) float64 {
+	v, _ := strconv.ParseFloat(strconv.FormatFloat(score, 'f', 2, 64), 64)
+	return v
+}
+
+func (s *Sheet) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	for _, row := range s.Table() {
+		record := make([]string, len(row))
+		for i, v := range row {
+			if f, ok := v.(float64); ok {
+				record[i] = strconv.FormatFloat(f, 'f', -1, 64)
+			} else {
+				record[i] = v.(string)
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (s *Sheet) WriteJSON(w io.Writer) error {
+	type cell struct {
+		QuestionID int     `json:"questionId"`
+		Status     string  `json:"status"`
+		Score      float64 `json:"score"`
+		Attempts   int     `json:"attempts"`
+		FirstPass  string  `json:"firstPass,omitempty"`
+		Late       bool    `json:"late,omitempty"`
+	}
+	type student struct {
+		EnrollmentNumber string  `json:"enrollmentNumber"`
+		Name             string  `json:"name"`
+		Total            float64 `json:"total"`
+		Questions        []cell  `json:"questions"`
+	}
+	type question struct {
+		ID          int     `json:"id"`
+		Description string  `json:"description"`
+		Points      float64 `json:"points"`
+	}
+	out := struct {
+		LabSessionID int    `json:"labSessionId"`
+		Description  string `json:"description"`
+		SessionDate  string `json:"sessionDate"`
+		Rules        struct {
+			Attempt     string  `json:"attempt"`
+			Partial     bool    `json:"partial"`
+			Deadline    string  `json:"deadline,omitempty"`
+			LatePenalty float64 `json:"latePenalty"`
+		} `json:"rules"`
+		MaxTotal  float64    `json:"maxTotal"`
+		Questions []question `json:"questions"`
+		Students  []student  `json:"students"`
+	}{
+		LabSessionID: s.LabSession.ID,
+		Description:  s.LabSession.Description,
+		SessionDate:  s.LabSession.SessionDate,
+		MaxTotal:     s.MaxTotal(),
+		Questions:    []question{},
+		Students:     []student{},
+	}
+	out.Rules.Attempt, out.Rules.Partial, out.Rules.LatePenalty = s.Rules.Attempt, s.Rules.Partial, s.Rules.LatePenalty
+	if !s.Rules.Deadline.IsZero() {
+		out.Rules.Deadline = s.Rules.Deadline.UTC().Format(api.TimeFormat)
+	}
+	for _, q := range s.Questions {
+		out.Questions = append(out.Questions, question{q.ID, q.Description, s.Rules.Points})
+	}
+	for _, row := range s.Rows {
+		st := student{row.Student.EnrollmentNumber, row.Student.Name, round(row.Total), []cell{}}
+		for i, c := range row.Cells {
+			st.Questions = append(st.Questions, cell{s.Questions[i].ID, c.Status, round(c.Score), c.Attempts, c.FirstPass, c.Late})
+		}
+		out.Students = append(out.Students, st)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// WriteXLSX writes a single sheet workbook. The format is a zip of XML
+// parts; only the few a spreadsheet needs to open the file are written.
+func (s *Sheet) WriteXLSX(w io.Writer) error {
+	const header = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+	var sheet strings.Builder
+	sheet.WriteString(header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for r, row := range s.Table() {
+		fmt.Fprintf(&sheet, `<row r="%d">`, r+1)
+		for c, v := range row {
+			ref := columnName(c) + strconv.Itoa(r+1)
+			if f, ok := v.(float64); ok {
+				fmt.Fprintf(&sheet, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(f, 'f', -1, 64))
+				continue
+			}
+			if v.(string) == "" {
+				continue
+			}
+			fmt.Fprintf(&sheet, `<c r="%s" t="inlineStr"><is><t>`, ref)
+			xml.EscapeText(&sheet, []byte(v.(string)))
+			sheet.WriteString(`</t></is></c>`)
+		}
+		sheet.WriteString(`</row>`)
+	}
+	sheet.WriteString(`</sheetData></worksheet>`)
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Grades" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`},
+		{"xl/worksheets/sheet1.xml", sheet.String()},
+	}
+
+	zw := zip.NewWriter(w)
+	for _, part := range parts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// columnName turns a 0-based index into a spreadsheet column: A, B, ...,
+// Z, AA, AB, ...
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"new_cli/api"
+)
+
+func TestExportGrades(t *testing.T) {
+	fake := instructorFake(t)
+	at := time.Date(2024, 10, 15, 9, 0, 0, 0, time.UTC)
+	fake.Store.Now = func() time.Time { return at }
+	for _, sub := range []api.Submission{
+		{StudentID: 1, QuestionID: 1, Status: api.StatusFailed, ResultDetails: `{"passed":[{}],"failed":[{}]}`},
+		{StudentID: 1, QuestionID: 2, Status: api.StatusPassed},
+		{StudentID: 2, QuestionID: 1, Status: api.StatusPassed},
+		{StudentID: 1, QuestionID: 1, Status: api.StatusPassed},
+	} {
+		sub.LabSessionID, sub.Solution = 1, "int main() {}"
+		if err := fake.Store.CreateSubmission(context.Background(), &sub); err != nil {
+			t.Fatal(err)
+		}
+		at = at.Add(30 * time.Minute)
+	}
+
+	dir := t.TempDir()
+	out := capture(t, func() {
+		instructor("export grades 1 --out -")
+		instructor("export grades 1 --out " + dir + "/late.csv --partial --deadline 10:00 --late-penalty 50%")
+		instructor("export grades 1 --out " + dir + "/grades.json --attempt latest --points 5")
+		instructor("export grades 1 --format xlsx --out " + dir + "/grades.bin")
+		instructor("export grades 1 --format pdf")
+		instructor("export grades 1 --attempt worst")
+		instructor("export grades 1 --deadline noon")
+		instructor("export grades 1 --late-penalty 10")
+		instructor("export grades 9")
+	})
+	for _, name := range []string{"late.csv", "grades.json"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out += "--- " + name + "\n" + string(data)
+	}
+	checkGolden(t, "export_grades", strings.ReplaceAll(out, dir, "DIR"))
+
+	if data, _ := os.ReadFile(filepath.Join(dir, "grades.json")); !json.Valid(data) {
+		t.Errorf("grades.json is not valid JSON")
+	}
+	if info, err := os.Stat(filepath.Join(dir, "grades.bin")); err != nil || info.Size() == 0 {
+		t.Errorf("xlsx not written: %v", err)
+	}
+}
@@ -0,0 +1,505 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"new_cli/api"
+	"new_cli/runner"
+)
+
+// instructorID is who lab sessions and questions are created as. It comes
+// from BISKUT_INSTRUCTOR or 'instructor login <ID>'.
+var instructorID = envOr("BISKUT_INSTRUCTOR", "")
+
+// runInstructorShell is the REPL started by 'biskut instructor' with no
+// further arguments; every line is an instructor command.
+func runInstructorShell() {
+	fmt.Println("Welcome to the Biskut CLI (instructor mode)!")
+	for instructorID == "" {
+		fmt.Print("Enter InstructorID : ")
+		input, err := stdin.ReadString('\n')
+		if err == io.EOF {
+			return
+		}
+		instructorLogin(strings.TrimSpace(input))
+	}
+	fmt.Println("Type 'help' for a list of commands.")
+
+	for {
+		bold.Print("instructor> ")
+		input, err := stdin.ReadString('\n')
+		input = strings.TrimSpace(input)
+		if input == "exit" || input == "quit" || err == io.EOF {
+			fmt.Println("Goodbye!")
+			return
+		}
+		handleInstructorCommand(strings.Fields(input))
+	}
+}
+
+func handleInstructorCommand(args []string) {
+	if len(args) == 0 {
+		printInstructorHelp()
+		return
+	}
+
+	command, args := args[0], args[1:]
+	switch command {
+	case "help":
+		printInstructorHelp()
+	case "login":
+		if len(args) != 1 {
+			red.Println("Usage: instructor login <instructorID>")
+			return
+		}
+		instructorLogin(args[0])
+	case "sessions":
+		listInstructorSessions()
+	case "labsession":
+		handleLabSessionCommand(args)
+	case "question":
+		handleQuestionCommand(args)
+	case "testcases":
+		handleTestCasesCommand(args)
+	case "attendance":
+		if len(args) != 1 {
+			red.Println("Usage: instructor attendance <labSessionID>")
+			return
+		}
+		showAttendance(args[0])
+	case "submissions":
+		pendingOnly := len(args) == 2 && args[1] == "--pending"
+		if len(args) != 1 && !pendingOnly {
+			red.Println("Usage: instructor submissions <labSessionID> [--pending]")
+			return
+		}
+		listSubmissions(args[0], pendingOnly)
+	case "similarity":
+		handleSimilarityCommand(args)
+	case "export":
+		handleExportCommand(args)
+	case "monitor":
+		handleMonitorCommand(args)
+	case "judge":
+		switch {
+		case len(args) == 1:
+			handleJudgeCommand(args)
+		case len(args) >= 2:
+			judgeSubmission(args[0], args[1], strings.Join(args[2:], " "))
+		default:
+			red.Println("Usage: instructor judge <labSessionID> | <submissionID> <passed|failed> [comment]")
+		}
+	default:
+		red.Println("Unknown instructor command. Type 'instructor help' for a list of commands.")
+	}
+}
+
+func printInstructorHelp() {
+	fmt.Println("Instructor commands:")
+	fmt.Println("  login <instructorID>                        - Log in and list your lab sessions")
+	fmt.Println("  sessions                                    - List your lab sessions")
+	fmt.Println("  labsession create <programID> <date> [desc] - Create a lab session (date as YYYY-MM-DD)")
+	fmt.Println("  labsession show <labSessionID>              - Show a lab session and its questions")
+	fmt.Println("  question add <labSessionID> <desc file> [--tests dir|zip] [--time-limit ms]")
+	fmt.Println("               [--solution ref.cpp] [--checker file.cpp] [--interactor file.cpp]")
+	fmt.Println("                                              - Add a question, with test cases from N.in/N.out files,")
+	fmt.Println("                                                a zip, or a Polygon or CMS package")
+	fmt.Println("  question move <questionID> <labSessionID>   - Move a question to another lab session")
+	fmt.Println("  testcases check <dir|zip> [--solution ref.cpp] [--checker file.cpp]")
+	fmt.Println("                                              - Read test cases, checking them against a reference solution")
+	fmt.Println("  testcases import <questionID> <dir|zip> [--solution ref.cpp] [--time-limit ms]")
+	fmt.Println("                                              - Replace a question's test cases")
+	fmt.Println("  testcases export <questionID> <dir|file.zip> - Save a question's test cases as N.in/N.out files")
+	fmt.Println("  testcases generate <dir|zip> --solution ref.cpp (--generator gen.cpp [--seeds 1-10] | --inputs dir)")
+	fmt.Println("                                              - Write test cases with outputs from a reference solution")
+	fmt.Println("  testcases verify <questionID> --solution ref.cpp [--fix]")
+	fmt.Println("                                              - Check a question's expected outputs, optionally fixing them")
+	fmt.Println("  attendance <labSessionID>                   - Show who has submitted in a lab session")
+	fmt.Println("  submissions <labSessionID> [--pending]      - List the submissions of a lab session")
+	fmt.Println("  judge <labSessionID>                        - Judge pending submissions one by one")
+	fmt.Println("  judge <submissionID> <passed|failed> [comment]")
+	fmt.Println("                                              - Judge a single submission by hand")
+	fmt.Println("  monitor <labSessionID> [--redis host:port]  - Watch submissions come in live")
+	fmt.Println("  export grades <labSessionID> [--format csv|xlsx|json] [--out file] [--attempt best|latest]")
+	fmt.Println("               [--points n] [--partial] [--deadline HH:MM] [--late-penalty percent]")
+	fmt.Println("                                              - Export marks for every student and question")
+	fmt.Println("  similarity <labSessionID> <questionID> [--min percent] [--show pairs] [--base template.cpp]")
+	fmt.Println("                                              - Rank pairs of solutions by how much code they share")
+}
+
+// instructorRequest sends a request to /api/ins and decodes the JSON reply
+// into out. Error replies are returned with the server's message.
+func instructorRequest(method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, apiBase+"/api/ins"+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		var reply struct {
+			Error string `json:"error"`
+		}
+		message := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &reply) == nil && reply.Error != "" {
+			message = reply.Error
+		}
+		return fmt.Errorf("%s (%s)", message, http.StatusText(resp.StatusCode))
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// sessionDay formats an API date as a local YYYY-MM-DD.
+func sessionDay(date string) string {
+	t, err := time.Parse(api.TimeFormat, date)
+	if err != nil {
+		return date
+	}
+	return t.Local().Format("2006-01-02")
+}
+
+func instructorLogin(id string) {
+	var instructor api.Instructor
+	err := instructorRequest("GET", "/details?instructorId="+url.QueryEscape(id), nil, &instructor)
+	if err != nil {
+		red.Println("Error logging in:", err)
+		return
+	}
+
+	instructorID = strconv.Itoa(instructor.ID)
+	fmt.Println("Welcome, ", instructor.Name)
+	displayInstructorSessions(instructor.LabSessions)
+}
+
+func listInstructorSessions() {
+	if instructorID == "" {
+		red.Println("Instructor ID is not set. Use 'instructor login <ID>' first.")
+		return
+	}
+
+	var instructor api.Instructor
+	if err := instructorRequest("GET", "/details?instructorId="+url.QueryEscape(instructorID), nil, &instructor); err != nil {
+		red.Println("Error fetching lab sessions:", err)
+		return
+	}
+	displayInstructorSessions(instructor.LabSessions)
+}
+
+func displayInstructorSessions(sessions []api.LabSession) {
+	if len(sessions) == 0 {
+		fmt.Println("You have no lab sessions yet. Create one with 'instructor labsession create'.")
+		return
+	}
+
+	bold.Println("Your lab sessions:")
+	for _, ls := range sessions {
+		fmt.Printf("  %4d  %s  %-20s %s\n", ls.ID, sessionDay(ls.SessionDate), ls.Program.Name, ls.Description)
+	}
+}
+
+func handleLabSessionCommand(args []string) {
+	switch {
+	case len(args) >= 3 && args[0] == "create":
+		createLabSession(args[1], args[2], strings.Join(args[3:], " "))
+	case len(args) == 2 && args[0] == "show":
+		showLabSession(args[1])
+	default:
+		red.Println("Usage: instructor labsession create <programID> <date> [description] | show <labSessionID>")
+	}
+}
+
+func createLabSession(programID, date, description string) {
+	if instructorID == "" {
+		red.Println("Instructor ID is not set. Use 'instructor login <ID>' first.")
+		return
+	}
+	program, err := strconv.Atoi(programID)
+	if err != nil {
+		red.Println("Program ID must be a number.")
+		return
+	}
+	instructor, _ := strconv.Atoi(instructorID)
+
+	var ls api.LabSession
+	err = instructorRequest("POST", "/labsession", map[string]any{
+		"programId":    program,
+		"instructorId": instructor,
+		"sessionDate":  date,
+		"description":  description,
+	}, &ls)
+	if err != nil {
+		red.Println("Error creating lab session:", err)
+		return
+	}
+	green.Printf("Lab session %d created for %s on %s.\n", ls.ID, ls.Program.Name, sessionDay(ls.SessionDate))
+}
+
+func showLabSession(id string) {
+	var ls api.LabSession
+	if err := instructorRequest("GET", "/labsession?labSessionId="+url.QueryEscape(id), nil, &ls); err != nil {
+		red.Println("Error fetching lab session:", err)
+		return
+	}
+
+	bold.Printf("Lab session %d: %s\n", ls.ID, ls.Description)
+	fmt.Println("--------------------")
+	fmt.Printf("Program: %s\n", ls.Program.Name)
+	fmt.Printf("Date: %s\n", sessionDay(ls.SessionDate))
+	fmt.Printf("Instructor: %s\n", ls.Instructor.Name)
+	if len(ls.Questions) == 0 {
+		fmt.Println("No questions yet.")
+	} else {
+		fmt.Println("Questions:")
+	}
+	for _, q := range ls.Questions {
+		kind := "judged by hand"
+		if q.TestCaseBased {
+			var cases []runner.TestCase
+			json.Unmarshal([]byte(q.InputsOutputs), &cases)
+			kind = fmt.Sprintf("%d test cases", len(cases))
+		}
+		description, _, _ := strings.Cut(q.Description, "\n")
+		fmt.Printf("  %4d  %-16s %s\n", q.ID, kind, description)
+	}
+	fmt.Println("--------------------")
+}
+
+func handleQuestionCommand(args []string) {
+	switch {
+	case len(args) >= 3 && args[0] == "add":
+		addQuestion(args[1], args[2], args[3:])
+	case len(args) == 3 && args[0] == "move":
+		moveQuestion(args[1], args[2])
+	default:
+		red.Println("Usage: instructor question add <labSessionID> <desc file> [--tests dir|zip] [--time-limit ms] [--solution ref.cpp] [--checker file.cpp] [--interactor file.cpp]")
+		red.Println("       instructor question move <questionID> <labSessionID>")
+	}
+}
+
+func addQuestion(labSessionID, descriptionFile string, flags []string) {
+	if instructorID == "" {
+		red.Println("Instructor ID is not set. Use 'instructor login <ID>' first.")
+		return
+	}
+	session, err := strconv.Atoi(labSessionID)
+	if err != nil {
+		red.Println("Lab session ID must be a number.")
+		return
+	}
+	instructor, _ := strconv.Atoi(instructorID)
+
+	description, err := os.ReadFile(descriptionFile)
+	if err != nil {
+		red.Println("Error reading description:", err)
+		return
+	}
+
+	values, err := parseFlags(flags, "tests", "time-limit", "solution", "checker", "interactor")
+	if err != nil {
+		red.Println("Error:", err)
+		return
+	}
+	timeLimit, err := parseTimeLimit(values)
+	if err != nil {
+		red.Println("Error:", err)
+		return
+	}
+
+	testCases := []runner.TestCase{}
+	if values["tests"] != "" {
+		pkg, ok := loadTestCases(values["tests"], timeLimit)
+		if !ok {
+			return
+		}
+		if values["solution"] != "" {
+			if _, ok := validateTestCases(pkg, values["solution"], values["checker"]); !ok {
+				red.Println("Question not added.")
+				return
+			}
+		}
+		testCases = pkg.TestCases()
+	}
+
+	body := map[string]any{
+		"description":   strings.TrimSpace(string(description)),
+		"instructorId":  instructor,
+		"labSessionId":  session,
+		"testCases":     testCases,
+		"testCaseBased": len(testCases) > 0,
+	}
+	for _, key := range []string{"checker", "interactor"} {
+		file := values[key]
+		if file == "" {
+			continue
+		}
+		source, err := os.ReadFile(file)
+		if err != nil {
+			red.Printf("Error reading %s: %v\n", key, err)
+			return
+		}
+		body[key] = string(source)
+	}
+
+	var q api.Question
+	if err := instructorRequest("POST", "/question", body, &q); err != nil {
+		red.Println("Error adding question:", err)
+		return
+	}
+	if len(testCases) > 0 {
+		green.Printf("Question %d added to lab session %d with %d test cases.\n", q.ID, q.LabSessionID, len(testCases))
+	} else {
+		green.Printf("Question %d added to lab session %d, to be judged by hand.\n", q.ID, q.LabSessionID)
+	}
+}
+
+func moveQuestion(questionID, labSessionID string) {
+	question, err := strconv.Atoi(questionID)
+	if err != nil {
+		red.Println("Question ID must be a number.")
+		return
+	}
+	session, err := strconv.Atoi(labSessionID)
+	if err != nil {
+		red.Println("Lab session ID must be a number.")
+		return
+	}
+
+	err = instructorRequest("POST", "/question/add", map[string]int{"labSessionId": session, "questionId": question}, nil)
+	if err != nil {
+		red.Println("Error moving question:", err)
+		return
+	}
+	green.Printf("Question %d moved to lab session %d.\n", question, session)
+}
+
+func showAttendance(labSessionID string) {
+	var reply struct {
+		Attendance map[string]bool `json:"attendance"`
+	}
+	if err := instructorRequest("GET", "/attendance?labSessionId="+url.QueryEscape(labSessionID), nil, &reply); err != nil {
+		red.Println("Error fetching attendance:", err)
+		return
+	}
+
+	enrollments := make([]string, 0, len(reply.Attendance))
+	present := 0
+	for enrollment, ok := range reply.Attendance {
+		enrollments = append(enrollments, enrollment)
+		if ok {
+			present++
+		}
+	}
+	sort.Strings(enrollments)
+
+	bold.Printf("Attendance for lab session %s:\n", labSessionID)
+	fmt.Println("--------------------")
+	for _, enrollment := range enrollments {
+		if reply.Attendance[enrollment] {
+			green.Printf("  %-16s present\n", enrollment)
+		} else {
+			red.Printf("  %-16s absent\n", enrollment)
+		}
+	}
+	fmt.Println("--------------------")
+	fmt.Printf("%d of %d students present\n", present, len(enrollments))
+}
+
+func listSubmissions(labSessionID string, pendingOnly bool) {
+	var submissions []api.Submission
+	if err := instructorRequest("GET", "/submissions?labSessionId="+url.QueryEscape(labSessionID), nil, &submissions); err != nil {
+		red.Println("Error fetching submissions:", err)
+		return
+	}
+	if pendingOnly {
+		pending := submissions[:0]
+		for _, sub := range submissions {
+			if sub.Status == api.StatusPending {
+				pending = append(pending, sub)
+			}
+		}
+		submissions = pending
+	}
+	if len(submissions) == 0 && pendingOnly {
+		fmt.Println("No pending submissions.")
+		return
+	}
+	if len(submissions) == 0 {
+		fmt.Println("No submissions yet.")
+		return
+	}
+
+	bold.Printf("%6s  %-8s  %-12s  %-20s  %8s  %s\n", "ID", "Time", "Enrollment", "Student", "Question", "Status")
+	for _, sub := range submissions {
+		at := sub.SubmissionTime
+		if t, err := time.Parse(api.TimeFormat, at); err == nil {
+			at = t.Local().Format("15:04:05")
+		}
+		var enrollment, name string
+		if sub.Student != nil {
+			enrollment, name = sub.Student.EnrollmentNumber, sub.Student.Name
+		}
+		line := fmt.Sprintf("%6d  %-8s  %-12s  %-20s  %8d  %s\n", sub.ID, at, enrollment, name, sub.QuestionID, sub.Status)
+		switch sub.Status {
+		case api.StatusPassed:
+			green.Print(line)
+		case api.StatusFailed:
+			red.Print(line)
+		default:
+			yellow.Print(line)
+		}
+	}
+}
+
+func judgeSubmission(submissionID, verdict, comment string) {
+	id, err := strconv.Atoi(submissionID)
+	if err != nil {
+		red.Println("Submission ID must be a number.")
+		return
+	}
+	if verdict != api.StatusPassed && verdict != api.StatusFailed {
+		red.Println("Verdict must be 'passed' or 'failed'.")
+		return
+	}
+
+	err = instructorRequest("POST", "/judge", map[string]any{"submissionId": id, "verdict": verdict, "comment": comment}, nil)
+	if err != nil {
+		red.Println("Error judging submission:", err)
+		return
+	}
+	green.Printf("Submission %d marked %s.\n", id, verdict)
+}
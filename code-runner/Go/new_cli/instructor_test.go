@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"new_cli/api"
+	"new_cli/fakeapi"
+	"new_cli/testcases"
+)
+
+// instructorFake is newFake with instructor 1 logged in and session times
+// shown in UTC.
+func instructorFake(t *testing.T) *fakeapi.Server {
+	t.Helper()
+	fake := newFake(t)
+
+	oldID, oldLocal := instructorID, time.Local
+	instructorID, time.Local = "1", time.UTC
+	t.Cleanup(func() { instructorID, time.Local = oldID, oldLocal })
+	return fake
+}
+
+func instructor(command string) {
+	handleInstructorCommand(strings.Fields(command))
+}
+
+func TestInstructorLogin(t *testing.T) {
+	instructorFake(t)
+	instructorID = ""
+	out := capture(t, func() {
+		instructor("login 99")
+		instructor("login 1")
+	})
+	checkGolden(t, "instructor_login", out)
+	if instructorID != "1" {
+		t.Errorf("instructorID = %q after login, want 1", instructorID)
+	}
+}
+
+func TestInstructorLabSession(t *testing.T) {
+	instructorFake(t)
+	out := capture(t, func() {
+		instructor("labsession create 1 2024-10-22 Week 2")
+		instructor("labsession create 1 next-tuesday")
+		instructor("labsession create 7 2024-10-22")
+		instructor("labsession show 1")
+		instructor("sessions")
+	})
+	checkGolden(t, "instructor_labsession", out)
+}
+
+func TestInstructorQuestionAdd(t *testing.T) {
+	fake := instructorFake(t)
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"question.txt": "Print the product of two numbers\n",
+		"tests/1.in":   "2 3\n",
+		"tests/1.out":  "6\n",
+		"tests/2.in":   "4 5\n",
+		"tests/2.ans":  "20\n",
+		"tests/10.in":  "10 10\n",
+		"tests/10.out": "100\n",
+		"broken/1.in":  "1 1\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := capture(t, func() {
+		instructor("question add 1 " + filepath.Join(dir, "question.txt") + " --tests " + filepath.Join(dir, "tests") + " --time-limit 500")
+		instructor("question add 1 " + filepath.Join(dir, "question.txt"))
+		instructor("question add 1 " + filepath.Join(dir, "question.txt") + " --time-limit")
+		instructor("question add 1 " + filepath.Join(dir, "question.txt") + " --tests " + filepath.Join(dir, "broken"))
+		instructor("question move 4 1")
+		instructor("question move 2 99")
+	})
+	out = strings.ReplaceAll(out, dir, "DIR")
+	checkGolden(t, "instructor_question", out)
+
+	q, err := fake.Store.Question(context.Background(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"input":"2 3\n","output":"6\n","timeLimit":500},{"input":"4 5\n","output":"20\n","timeLimit":500},{"input":"10 10\n","output":"100\n","timeLimit":500}]`
+	if !q.TestCaseBased || q.InputsOutputs != want {
+		t.Errorf("question 3 stored as testCaseBased=%v %s, want %s", q.TestCaseBased, q.InputsOutputs, want)
+	}
+}
+
+func TestInstructorSubmissions(t *testing.T) {
+	fake := instructorFake(t)
+	for _, status := range []string{api.StatusFailed, api.StatusPending} {
+		err := fake.Store.CreateSubmission(context.Background(), &api.Submission{
+			StudentID: 1, QuestionID: 1, LabSessionID: 1, Status: status,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := capture(t, func() {
+		instructor("attendance 1")
+		instructor("submissions 1")
+		instructor("submissions 1 --pending")
+		instructor("judge 2 accepted")
+		instructor("judge 2 passed")
+		instructor("judge 9 failed")
+		instructor("submissions 1 --pending")
+	})
+	checkGolden(t, "instructor_submissions", out)
+}
+
+func TestInstructorTestCases(t *testing.T) {
+	if _, err := exec.LookPath("g++"); err != nil {
+		t.Skip("g++ not installed")
+	}
+	fake := instructorFake(t)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"good/1.in":  "2 3\n",
+		"good/1.out": "5\n",
+		"good/2.in":  "4 5\n",
+		"good/2.out": "9\n",
+		"bad/1.in":   "2 3\n",
+		"bad/1.out":  "6\n",
+		"bad/2.in":   "4 5\n",
+		"bad/2.out":  "9\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	solution := writeSolution(t)
+
+	out := capture(t, func() {
+		instructor("testcases check " + filepath.Join(dir, "good"))
+		instructor("testcases check " + filepath.Join(dir, "bad") + " --solution " + solution)
+		instructor("testcases import 1 " + filepath.Join(dir, "bad") + " --solution " + solution)
+		instructor("testcases import 1 " + filepath.Join(dir, "good") + " --solution " + solution + " --time-limit 2000")
+		instructor("testcases import 99 " + filepath.Join(dir, "good"))
+		instructor("testcases export 1 " + filepath.Join(dir, "export.zip"))
+		instructor("testcases export 1 " + filepath.Join(dir, "export.zip"))
+		instructor("testcases export 2 " + filepath.Join(dir, "none"))
+	})
+	out = strings.ReplaceAll(out, dir, "DIR")
+	out = regexp.MustCompile(`(✓ \S+\s+)\d+ ms`).ReplaceAllString(out, "${1}N ms")
+	checkGolden(t, "instructor_testcases", out)
+
+	q, err := fake.Store.Question(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"input":"2 3\n","output":"5\n","timeLimit":2000},{"input":"4 5\n","output":"9\n","timeLimit":2000}]`
+	if q.InputsOutputs != want {
+		t.Errorf("question 1 stored as %s, want %s", q.InputsOutputs, want)
+	}
+
+	pkg, err := testcases.Load(filepath.Join(dir, "export.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// N.in/N.out files have nowhere to keep the time limit
+	want = strings.ReplaceAll(want, `,"timeLimit":2000`, "")
+	if got, _ := json.Marshal(pkg.TestCases()); string(got) != want {
+		t.Errorf("exported zip holds %s, want %s", got, want)
+	}
+}
+
+func TestInstructorTestCasesGenerate(t *testing.T) {
+	if _, err := exec.LookPath("g++"); err != nil {
+		t.Skip("g++ not installed")
+	}
+	fake := instructorFake(t)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"gen.cpp":     "#include <cstdio>\n#include <cstdlib>\nint main(int argc, char **argv) { int s = atoi(argv[1]); printf(\"%d %d\\n\", s, s * s); }\n",
+		"crash.cpp":   "int main() { return 3; }\n",
+		"inputs/1.in": "1 1\n",
+		"inputs/2.in": "20 22\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	solution := writeSolution(t)
+	_, err := fake.Store.UpdateTestCases(context.Background(), 1,
+		`[{"input":"1 2","output":"3"},{"input":"1000 1","output":"0001"},{"input":"2 2","output":"5"}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := capture(t, func() {
+		instructor("testcases generate " + filepath.Join(dir, "gen") + " --solution " + solution + " --generator " + filepath.Join(dir, "gen.cpp") + " --seeds 1-2,7")
+		instructor("testcases generate " + filepath.Join(dir, "fromfiles.zip") + " --solution " + solution + " --inputs " + filepath.Join(dir, "inputs"))
+		instructor("testcases generate " + filepath.Join(dir, "crash") + " --solution " + filepath.Join(dir, "crash.cpp") + " --inputs " + filepath.Join(dir, "inputs"))
+		instructor("testcases generate " + filepath.Join(dir, "x") + " --solution " + solution)
+		instructor("testcases generate " + filepath.Join(dir, "x") + " --solution " + solution + " --generator g.cpp --seeds 5-1")
+		instructor("testcases verify 1 --solution " + solution)
+		instructor("testcases verify 1 --fix --solution " + solution)
+		instructor("testcases verify 1 --solution " + solution)
+	})
+	out = strings.ReplaceAll(out, dir, "DIR")
+	out = regexp.MustCompile(`(✓ \S+\s+)\d+ ms`).ReplaceAllString(out, "${1}N ms")
+	checkGolden(t, "instructor_testcases_generate", out)
+
+	pkg, err := testcases.Load(filepath.Join(dir, "gen"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := json.Marshal(pkg.TestCases())
+	want := `[{"input":"1 1\n","output":"2\n"},{"input":"2 4\n","output":"6\n"},{"input":"7 49\n","output":"56\n"}]`
+	if string(got) != want {
+		t.Errorf("generated %s, want %s", got, want)
+	}
+}
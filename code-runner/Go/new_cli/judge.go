@@ -0,0 +1,409 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	"golang.org/x/term"
+
+	"new_cli/api"
+	"new_cli/cpp"
+)
+
+var syntaxColors = map[cpp.Kind]*color.Color{
+	cpp.Comment:      color.New(color.Faint),
+	cpp.Preprocessor: color.New(color.FgCyan),
+	cpp.Keyword:      color.New(color.FgBlue, color.Bold),
+	cpp.Number:       color.New(color.FgMagenta),
+	cpp.String:       color.New(color.FgGreen),
+	cpp.Char:         color.New(color.FgGreen),
+}
+
+type segment struct {
+	kind cpp.Kind
+	text string
+}
+
+// highlightLines splits source into lines of highlighted segments, cutting
+// tokens that span lines such as block comments.
+func highlightLines(source string) [][]segment {
+	lines := [][]segment{nil}
+	source = strings.TrimSuffix(strings.ReplaceAll(source, "\r\n", "\n"), "\n")
+	for _, tok := range cpp.Tokenize(source) {
+		parts := strings.Split(tok.Text, "\n")
+		for i, part := range parts {
+			if i > 0 {
+				lines = append(lines, nil)
+			}
+			if part != "" {
+				lines[len(lines)-1] = append(lines[len(lines)-1], segment{tok.Kind, part})
+			}
+		}
+	}
+	return lines
+}
+
+// renderSegments draws a line exactly width columns wide, expanding tabs and
+// cutting what doesn't fit.
+func renderSegments(segments []segment, width int) string {
+	var b strings.Builder
+	col := 0
+	for _, seg := range segments {
+		var text strings.Builder
+		for _, r := range seg.text {
+			if col >= width {
+				break
+			}
+			if r == '\t' {
+				n := min(4-col%4, width-col)
+				text.WriteString(strings.Repeat(" ", n))
+				col += n
+				continue
+			}
+			if r < ' ' {
+				r = '?'
+			}
+			text.WriteRune(r)
+			col++
+		}
+		if c := syntaxColors[seg.kind]; c != nil {
+			b.WriteString(c.Sprint(text.String()))
+		} else {
+			b.WriteString(text.String())
+		}
+	}
+	return b.String() + strings.Repeat(" ", max(0, width-col))
+}
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b[@-_]`)
+
+// submissionOutput is what the student saw when they ran their program, or
+// the judge's report for test case questions.
+func submissionOutput(sub api.Submission) string {
+	var details struct {
+		Output *string `json:"output"`
+	}
+	text := sub.ResultDetails
+	if json.Unmarshal([]byte(sub.ResultDetails), &details) == nil && details.Output != nil {
+		text = *details.Output
+	}
+	text = ansiEscape.ReplaceAllString(text, "")
+	return strings.ReplaceAll(text, "\r", "")
+}
+
+// judgeView walks an instructor through the pending submissions of a lab
+// session. It only draws and reacts to keys; runJudge owns the terminal.
+type judgeView struct {
+	labSessionID int
+	questions    map[int]api.Question
+	subs         []api.Submission
+	judged       map[int]string // verdicts given in this session, by submission ID
+	index        int
+
+	source    [][]segment
+	output    []string
+	sourceTop int
+	outputTop int
+	page      int // rows in the panes at the last render
+
+	comment string
+	editing bool
+	saved   string // the comment before editing, restored on Esc
+	message string
+}
+
+func loadJudgeView(labSessionID string) (*judgeView, error) {
+	var session api.LabSession
+	if err := instructorRequest("GET", "/labsession?labSessionId="+url.QueryEscape(labSessionID), nil, &session); err != nil {
+		return nil, fmt.Errorf("fetching lab session: %v", err)
+	}
+	var submissions []api.Submission
+	if err := instructorRequest("GET", "/submissions?labSessionId="+url.QueryEscape(labSessionID), nil, &submissions); err != nil {
+		return nil, fmt.Errorf("fetching submissions: %v", err)
+	}
+
+	v := &judgeView{labSessionID: session.ID, questions: map[int]api.Question{}, judged: map[int]string{}}
+	for _, q := range session.Questions {
+		v.questions[q.ID] = q
+	}
+	for _, sub := range submissions {
+		// Pending test case submissions are still with the judge
+		if sub.Status == api.StatusPending && !v.questions[sub.QuestionID].TestCaseBased {
+			v.subs = append(v.subs, sub)
+		}
+	}
+	v.show(0)
+	return v, nil
+}
+
+func (v *judgeView) show(index int) {
+	v.index = index
+	v.sourceTop, v.outputTop = 0, 0
+	v.source, v.output = nil, nil
+	if index < len(v.subs) {
+		v.source = highlightLines(v.subs[index].Solution)
+		v.output = strings.Split(strings.TrimRight(submissionOutput(v.subs[index]), "\n"), "\n")
+	}
+}
+
+func (v *judgeView) render(width, height int) []string {
+	var lines []string
+	if len(v.subs) == 0 {
+		lines = append(lines, bold.Sprintf("Lab session %d has no pending submissions.", v.labSessionID))
+		return append(lines, "Press q to quit.")
+	}
+
+	sub := v.subs[v.index]
+	var who string
+	if sub.Student != nil {
+		who = sub.Student.EnrollmentNumber + " " + sub.Student.Name
+	}
+	at := sub.SubmissionTime
+	if t, err := time.Parse(api.TimeFormat, at); err == nil {
+		at = t.Local().Format("15:04:05")
+	}
+	header := fmt.Sprintf("%d/%d  Submission #%d  %s  Q%d  %s", v.index+1, len(v.subs), sub.ID, who, sub.QuestionID, at)
+	if verdict := v.judged[sub.ID]; verdict != "" {
+		header += "  [" + verdict + "]"
+	}
+	lines = append(lines, bold.Sprint(truncate(header, width)))
+	description, _, _ := strings.Cut(v.questions[sub.QuestionID].Description, "\n")
+	lines = append(lines, emptyCell.Sprint(truncate(description, width)))
+
+	left := max(20, (width-3)/2)
+	right := max(10, width-left-3)
+	lines = append(lines, fmt.Sprintf("%-*s │ %s", left, truncate("Source", left), truncate("Output", right)))
+
+	v.page = max(1, height-len(lines)-3)
+	for row := 0; row < v.page; row++ {
+		var code string
+		if n := v.sourceTop + row; n < len(v.source) {
+			code = emptyCell.Sprintf("%4d ", n+1) + renderSegments(v.source[n], left-5)
+		} else {
+			code = strings.Repeat(" ", left)
+		}
+		var out string
+		if n := v.outputTop + row; n < len(v.output) {
+			out = renderSegments([]segment{{cpp.Space, v.output[n]}}, right)
+		}
+		lines = append(lines, strings.TrimRight(code+" │ "+out, " "))
+	}
+
+	lines = append(lines, strings.Repeat("─", left+1)+"┴"+strings.Repeat("─", right+1))
+	if v.editing {
+		lines = append(lines, "Comment: "+v.comment+"█")
+		lines = append(lines, emptyCell.Sprint("Enter to keep, Esc to cancel"))
+		return lines
+	}
+	if v.comment != "" {
+		lines = append(lines, "Comment: "+v.comment)
+	} else {
+		lines = append(lines, emptyCell.Sprint("No comment"))
+	}
+	help := "p pass  f fail  c comment  n/b next/back  j/k source  J/K output  q quit"
+	if v.message != "" {
+		help = v.message
+	}
+	lines = append(lines, truncate(help, width))
+	return lines
+}
+
+// key handles one keypress and reports whether the instructor is done.
+func (v *judgeView) key(k string) bool {
+	if v.editing {
+		switch k {
+		case "enter":
+			v.editing = false
+		case "esc":
+			v.editing, v.comment = false, v.saved
+		case "backspace":
+			if _, size := utf8.DecodeLastRuneInString(v.comment); size > 0 {
+				v.comment = v.comment[:len(v.comment)-size]
+			}
+		case "ctrl-u":
+			v.comment = ""
+		default:
+			if utf8.RuneCountInString(k) == 1 {
+				v.comment += k
+			}
+		}
+		return false
+	}
+
+	v.message = ""
+	switch k {
+	case "q", "ctrl-c":
+		return true
+	}
+	if len(v.subs) == 0 {
+		return false
+	}
+	switch k {
+	case "p":
+		v.judge(api.StatusPassed)
+	case "f":
+		v.judge(api.StatusFailed)
+	case "c":
+		v.editing, v.saved = true, v.comment
+	case "n", "right", " ":
+		v.show((v.index + 1) % len(v.subs))
+	case "b", "left":
+		v.show((v.index + len(v.subs) - 1) % len(v.subs))
+	case "j", "down":
+		v.sourceTop = min(v.sourceTop+1, max(0, len(v.source)-1))
+	case "k", "up":
+		v.sourceTop = max(v.sourceTop-1, 0)
+	case "pgdn":
+		v.sourceTop = min(v.sourceTop+v.page, max(0, len(v.source)-1))
+	case "pgup":
+		v.sourceTop = max(v.sourceTop-v.page, 0)
+	case "J":
+		v.outputTop = min(v.outputTop+1, max(0, len(v.output)-1))
+	case "K":
+		v.outputTop = max(v.outputTop-1, 0)
+	}
+	return false
+}
+
+// judge records the verdict and moves on to the next submission that hasn't
+// been judged yet.
+func (v *judgeView) judge(verdict string) {
+	sub := v.subs[v.index]
+	err := instructorRequest("POST", "/judge", map[string]any{
+		"submissionId": sub.ID,
+		"verdict":      verdict,
+		"comment":      v.comment,
+	}, nil)
+	if err != nil {
+		v.message = "Error judging submission: " + err.Error()
+		return
+	}
+
+	v.judged[sub.ID] = verdict
+	v.comment = ""
+	v.message = fmt.Sprintf("Submission #%d marked %s.", sub.ID, verdict)
+	for i := 1; i <= len(v.subs); i++ {
+		next := (v.index + i) % len(v.subs)
+		if v.judged[v.subs[next].ID] == "" {
+			v.show(next)
+			return
+		}
+	}
+	v.message += " All pending submissions are judged; press q to quit."
+}
+
+// keyNames splits what one read from the terminal returned into keys.
+func keyNames(buf []byte) []string {
+	sequences := map[string]string{
+		"\x1b[A": "up", "\x1b[B": "down", "\x1b[C": "right", "\x1b[D": "left",
+		"\x1bOA": "up", "\x1bOB": "down", "\x1bOC": "right", "\x1bOD": "left",
+		"\x1b[5~": "pgup", "\x1b[6~": "pgdn",
+	}
+	var keys []string
+	s := string(buf)
+	for len(s) > 0 {
+		if s[0] == 0x1b {
+			name, rest := "esc", s[1:]
+			for seq, n := range sequences {
+				if strings.HasPrefix(s, seq) {
+					name, rest = n, s[len(seq):]
+					break
+				}
+			}
+			// Drop other escape sequences whole rather than as Esc + letters
+			if name == "esc" && len(rest) > 0 && (rest[0] == '[' || rest[0] == 'O') {
+				loc := ansiEscape.FindStringIndex(s)
+				if loc != nil && loc[0] == 0 {
+					rest = s[loc[1]:]
+					name = ""
+				}
+			}
+			if name != "" {
+				keys = append(keys, name)
+			}
+			s = rest
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s)
+		s = s[size:]
+		switch r {
+		case '\r', '\n':
+			keys = append(keys, "enter")
+		case 0x7f, 0x08:
+			keys = append(keys, "backspace")
+		case 0x03:
+			keys = append(keys, "ctrl-c")
+		case 0x15:
+			keys = append(keys, "ctrl-u")
+		default:
+			if r >= ' ' {
+				keys = append(keys, string(r))
+			}
+		}
+	}
+	return keys
+}
+
+func handleJudgeCommand(args []string) {
+	if len(args) != 1 {
+		red.Println("Usage: judge <labSessionID>")
+		return
+	}
+	if err := runJudge(args[0]); err != nil {
+		red.Println("Error judging submissions:", err)
+	}
+}
+
+func runJudge(labSessionID string) error {
+	fd := int(os.Stdout.Fd())
+	if !term.IsTerminal(fd) {
+		return fmt.Errorf("judge needs a terminal")
+	}
+	view, err := loadJudgeView(labSessionID)
+	if err != nil {
+		return err
+	}
+
+	stdin, restore, err := enterRawMode(func() {
+		fmt.Print("\x1b[?25h\x1b[?1049l")
+	})
+	if err != nil {
+		return err
+	}
+	defer restore()
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+
+	buf := make([]byte, 64)
+	for {
+		width, height, err := term.GetSize(fd)
+		if err != nil {
+			width, height = 80, 24
+		}
+		fmt.Print("\x1b[H\x1b[2J" + strings.Join(view.render(width, height), "\r\n"))
+
+		n, err := stdin.Read(buf)
+		if err != nil {
+			break
+		}
+		done := false
+		for _, k := range keyNames(buf[:n]) {
+			done = done || view.key(k)
+		}
+		if done {
+			break
+		}
+	}
+
+	judged := len(view.judged)
+	restore()
+	fmt.Printf("Judged %d of %d pending submissions.\n", judged, len(view.subs))
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"new_cli/api"
+)
+
+func TestJudgeView(t *testing.T) {
+	fake := instructorFake(t)
+	ctx := context.Background()
+	greeting := "#include <iostream>\n\nint main() {\n\t// say hi\n\tstd::cout << \"Hello, World!\" << std::endl;\n\treturn 0;\n}\n"
+	seed := []api.Submission{
+		{StudentID: 1, QuestionID: 2, LabSessionID: 1, Status: api.StatusPending, Solution: greeting,
+			ResultDetails: `{"output":"\u001b[32mHello, World!\r\n\u001b[0m"}`},
+		{StudentID: 1, QuestionID: 1, LabSessionID: 1, Status: api.StatusPassed},
+		{StudentID: 1, QuestionID: 2, LabSessionID: 1, Status: api.StatusPending, Solution: "int main() {}\n",
+			ResultDetails: `{"output":""}`},
+	}
+	for i := range seed {
+		if err := fake.Store.CreateSubmission(ctx, &seed[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var view *judgeView
+	var frames []string
+	frame := func(title string) {
+		frames = append(frames, "== "+title, strings.Join(view.render(72, 16), "\n"))
+	}
+	press := func(keys string) {
+		for _, k := range keyNames([]byte(keys)) {
+			if view.key(k) {
+				t.Fatalf("key %q quit the judge view", k)
+			}
+		}
+	}
+
+	out := capture(t, func() {
+		var err error
+		if view, err = loadJudgeView("1"); err != nil {
+			t.Fatal(err)
+		}
+		frame("first pending submission")
+		press("cNice, but no\x7f\x7f\x7f\x7f\x7f\x7f\x7f clean output")
+		frame("typing a comment")
+		press("\rp")
+		frame("after passing #1")
+		press("f")
+		frame("after failing #3")
+		press("\x1b[Dj")
+		frame("back to #1, scrolled")
+	})
+	if out != "" {
+		t.Errorf("judge view printed %q outside its frames", out)
+	}
+	checkGolden(t, "judge", strings.Join(frames, "\n")+"\n")
+
+	subs, err := fake.Store.LabSessionSubmissions(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{subs[0].Status + ":" + subs[0].Comment, subs[2].Status + ":" + subs[2].Comment}
+	if got[0] != "passed:Nice, clean output" || got[1] != "failed:" {
+		t.Errorf("stored verdicts %q", got)
+	}
+}
+
+func TestKeyNames(t *testing.T) {
+	got := strings.Join(keyNames([]byte("a\x1b[A\x1b\x1b[1;5Cé\r\x7f\x03")), ",")
+	want := "a,up,esc,é,enter,backspace,ctrl-c"
+	if got != want {
+		t.Errorf("keyNames = %s, want %s", got, want)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,90 +12,73 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
-    "sync"
-    "time"
+	"time"
 
-	"github.com/creack/pty"
 	"github.com/fatih/color"
 	"github.com/manifoldco/promptui"
-	"golang.org/x/term"
-)
-
-type Question struct {
-	ID            int    `json:"id"`
-	InstructorID  int    `json:"instructorId"`
-	LabSessionID  int    `json:"labSessionId"`
-	Description   string `json:"description"`
-	InputsOutputs string `json:"inputsOutputs"`
-	TestCaseBased bool   `json:"testCaseBased"`
-}
-
-type Status struct {
-	StudentID    string            `json:"studentId"`
-	LabSessionID string            `json:"labSessionId"`
-	Status       map[string]string `json:"status"`
-}
-
-type Student struct {
-	ID              int    `json:"id"`
-	Name            string `json:"name"`
-	Email           string `json:"email"`
-	EnrollmentNumber string `json:"enrollmentNumber"`
-	DepartmentID    int    `json:"departmentId"`
-
-}
-
-type LabSession struct {
-	ID          int         `json:"id"`
-	ProgramID   int         `json:"programId"`
-	InstructorID int         `json:"instructorId"`
-	SessionDate string      `json:"sessionDate"`
-	Description string      `json:"description"`
-	Program     Program     `json:"program"`
-	Instructor  Instructor  `json:"instructor"`
-	Questions   []Question   `json:"questions"`
-}
-
-type Program struct {
-	ID          int         `json:"id"`
-	Name        string      `json:"name"`
-	Description string      `json:"description"`
-	ProgramCode string      `json:"programCode"`
-	DepartmentID int         `json:"departmentId"`
-	CreatedAt   string      `json:"createdAt"`
-}
 
-type Instructor struct {
-	ID          int         `json:"id"`
-	Name        string      `json:"name"`
-	Email       string      `json:"email"`
-	DepartmentID int         `json:"departmentId"`
-}
+	"new_cli/api"
+	"new_cli/runner"
+)
 
 var (
-	questions []Question
-	studentID string
-	studentInfo Student
-	labSessions []LabSession
+	// apiBase is the Lab API server, overridable with BISKUT_API
+	apiBase = envOr("BISKUT_API", "http://localhost:3000")
+
+	questions    []api.Question
+	studentID    string
+	studentInfo  api.Student
+	labSessions  []api.LabSession
 	labSessionID string
 
+	// stdin is shared by the prompt and questions asked mid-command, so
+	// neither loses input the other has buffered
+	stdin = bufio.NewReader(os.Stdin)
+
 	// Colors
-	bold      = color.New(color.Bold)
-	red       = color.New(color.FgRed)
-	green     = color.New(color.FgGreen)
-	blue      = color.New(color.FgBlue)
-	yellow    = color.New(color.FgYellow)
+	bold   = color.New(color.Bold)
+	red    = color.New(color.FgRed)
+	green  = color.New(color.FgGreen)
+	blue   = color.New(color.FgBlue)
+	yellow = color.New(color.FgYellow)
 )
 
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
 func main() {
-	reader := bufio.NewReader(os.Stdin)
+	installSignalHandler()
+
+	// Instructor commands skip the student login
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "instructor":
+			if len(os.Args) == 2 {
+				runInstructorShell()
+			} else {
+				handleInstructorCommand(os.Args[2:])
+			}
+			return
+		case "monitor":
+			handleMonitorCommand(os.Args[2:])
+			return
+		case "judge":
+			handleJudgeCommand(os.Args[2:])
+			return
+		}
+	}
 
 	fmt.Println("Welcome to the Biskut CLI!")
 	for {
 		fmt.Print("Enter StudentID : ")
-		input, _ := reader.ReadString('\n')
+		input, _ := stdin.ReadString('\n')
 		studentID = strings.TrimSpace(input)
 
 		if verifyStudentID() {
@@ -102,13 +86,19 @@ func main() {
 		}
 	}
 
+	// `biskut wait <submissionID>` only follows one submission
+	if len(os.Args) == 3 && os.Args[1] == "wait" {
+		waitForSubmission(os.Args[2])
+		return
+	}
+
 	fetchLabSessions()
 
 	fmt.Println("Type 'help' for a list of commands.")
 
 	for {
 		bold.Printf("%s> ", studentInfo.Name)
-		input, _ := reader.ReadString('\n')
+		input, _ := stdin.ReadString('\n')
 		input = strings.TrimSpace(input)
 
 		if input == "exit" || input == "quit" {
@@ -149,6 +139,28 @@ func handleCommand(input string) {
 			return
 		}
 		submitSolution(args[0], args[1])
+	case "wait":
+		if len(args) != 1 {
+			red.Println("Usage: wait <submission_id>")
+			return
+		}
+		waitForSubmission(args[0])
+	case "cancel":
+		if len(args) > 1 {
+			red.Println("Usage: cancel [submission_id]")
+			return
+		}
+		cancelSubmission(strings.Join(args, ""))
+	case "run":
+		handleRunCommand(args)
+	case "debug":
+		handleDebugCommand(args)
+	case "stress":
+		handleStressCommand(args)
+	case "cache":
+		handleCacheCommand(args)
+	case "instructor":
+		handleInstructorCommand(args)
 	default:
 		red.Println("Unknown command. Type 'help' for a list of commands.")
 	}
@@ -162,6 +174,21 @@ func printHelp() {
 	// fmt.Println("  set studentid <ID>  - Set the student ID")
 	fmt.Println("  status              - Fetch and display question status")
 	fmt.Println("  submit <file> <qID> - Submit a solution file for a specific question")
+	fmt.Println("  wait <submissionID> - Follow the judging of a submission, e.g. after a lost connection")
+	fmt.Println("  cancel [submissionID]")
+	fmt.Println("                      - Withdraw a submission that has no verdict yet, by default the last one")
+	fmt.Println("  run <file> [--input in.txt | --case N] [--expect out.txt] [--debug] [--args ...]")
+	fmt.Println("                      - Run a program locally, optionally with saved input;")
+	fmt.Println("                        --debug builds with sanitizers to show where it crashes;")
+	fmt.Println("                        it runs in a temporary directory, so arguments naming files")
+	fmt.Println("                        are made absolute, but files it opens by name are not found")
+	fmt.Println("  debug <file> <qID> [--case N]")
+	fmt.Println("                      - Step through a failing test case of the last verdict in gdb")
+	fmt.Println("  stress <solution.cpp> <brute.cpp> <gen.cpp> [--iterations N] [--seed S]")
+	fmt.Println("                      - Compare a solution with a brute force on generated inputs")
+	fmt.Println("                        until they disagree, and show the smallest such input")
+	fmt.Println("  cache [clean]       - Show or clear the local build cache")
+	fmt.Println("  instructor <cmd>    - Instructor commands, see 'instructor help'")
 	fmt.Println("  exit, quit          - Exit the CLI")
 }
 
@@ -175,12 +202,72 @@ func handleSetCommand(args []string) {
 	green.Println("Student ID set to:", studentID)
 }
 
+func handleCacheCommand(args []string) {
+	cache, err := runner.DefaultCache()
+	if err != nil {
+		red.Println("Error opening build cache:", err)
+		return
+	}
+
+	if len(args) == 1 && args[0] == "clean" {
+		if err := cache.Clean(); err != nil {
+			red.Println("Error cleaning build cache:", err)
+			return
+		}
+		green.Println("Build cache cleared.")
+		return
+	}
+	if len(args) != 0 {
+		red.Println("Usage: cache [clean]")
+		return
+	}
+
+	count, size, err := cache.Stats()
+	if err != nil {
+		red.Println("Error reading build cache:", err)
+		return
+	}
+	fmt.Printf("Build cache: %s\n", cache.Dir)
+	fmt.Printf("  %d builds, %.1f MB of %.0f MB\n", count, float64(size)/(1<<20), float64(cache.MaxBytes)/(1<<20))
+}
+
+// buildSource compiles filePath through the build cache and returns the
+// executable to run.
+func buildSource(filePath string) (string, error) {
+	return buildWith(runner.DefaultCompiler, filePath)
+}
+
+// buildDebug is buildSource for a sanitizer build, which reports where the
+// program crashes.
+func buildDebug(filePath string) (string, error) {
+	return buildWith(runner.DefaultCompiler.WithSanitizers(), filePath)
+}
+
+func buildWith(compiler runner.Compiler, filePath string) (string, error) {
+	cache, err := runner.DefaultCache()
+	if err != nil {
+		return "", err
+	}
+
+	execPath, cached, err := cache.Build(context.Background(), compiler, filePath)
+	if err != nil {
+		return "", compileFailure(err)
+	}
+
+	if cached {
+		fmt.Println("Source unchanged, using cached build.")
+	} else {
+		fmt.Println("Compilation successful.")
+	}
+	return execPath, nil
+}
+
 func verifyStudentID() bool {
 	if studentID == "" {
 		red.Println("Student ID can't be empty. ")
 		return false
 	}
-	url := fmt.Sprintf("http://localhost:3000/api/stu?studentId=%s", studentID)
+	url := fmt.Sprintf("%s/api/stu?studentId=%s", apiBase, studentID)
 
 	resp, err := http.Get(url)
 	if err != nil {
@@ -205,7 +292,6 @@ func verifyStudentID() bool {
 		return false
 	}
 
-	
 	err = json.Unmarshal(body, &studentInfo)
 	if err != nil {
 		red.Println("Error parsing JSON:", err)
@@ -222,7 +308,7 @@ func fetchLabSessions() {
 		return
 	}
 
-	url := fmt.Sprintf("http://localhost:3000/api/stu/labsessions?studentId=%s", studentID)
+	url := fmt.Sprintf("%s/api/stu/labsessions?studentId=%s", apiBase, studentID)
 	resp, err := http.Get(url)
 	if err != nil {
 		red.Println("Error sending request:", err)
@@ -305,7 +391,7 @@ func fetchQuestions() {
 		return
 	}
 
-	url := fmt.Sprintf("http://localhost:3000/api/stu/questions?studentId=%s", studentID)
+	url := fmt.Sprintf("%s/api/stu/questions?studentId=%s", apiBase, studentID)
 	resp, err := http.Get(url)
 	if err != nil {
 		red.Println("Error sending request:", err)
@@ -377,7 +463,6 @@ func displayQuestions() {
 	// Here you can add logic to perform actions on the selected question, like submitting a solution
 }
 
-
 func fetchStatus() {
 	if studentID == "" {
 		red.Println("Student ID is not set. Use 'set studentid <ID>' first.")
@@ -391,7 +476,7 @@ func fetchStatus() {
 
 	labSessionID := questions[0].LabSessionID
 
-	url := fmt.Sprintf("http://localhost:3000/api/stu/status?studentId=%s&labSessionId=%d", studentID, labSessionID)
+	url := fmt.Sprintf("%s/api/stu/status?studentId=%s&labSessionId=%d", apiBase, studentID, labSessionID)
 	resp, err := http.Get(url)
 	if err != nil {
 		red.Println("Error sending request:", err)
@@ -405,7 +490,7 @@ func fetchStatus() {
 		return
 	}
 
-	var status Status
+	var status api.Status
 	err = json.Unmarshal(body, &status)
 	if err != nil {
 		red.Println("Error parsing JSON:", err)
@@ -415,13 +500,23 @@ func fetchStatus() {
 	displayStatus(status)
 }
 
-func displayStatus(status Status) {
+func displayStatus(status api.Status) {
 	bold.Println("Question Status:")
 	fmt.Println("--------------------")
 	fmt.Printf("Student ID: %s\n", status.StudentID)
 	fmt.Printf("Lab Session ID: %s\n", status.LabSessionID)
 	fmt.Println("Status:")
-	for questionID, questionStatus := range status.Status {
+	questionIDs := make([]string, 0, len(status.Status))
+	for questionID := range status.Status {
+		questionIDs = append(questionIDs, questionID)
+	}
+	sort.Slice(questionIDs, func(i, j int) bool {
+		a, _ := strconv.Atoi(questionIDs[i])
+		b, _ := strconv.Atoi(questionIDs[j])
+		return a < b
+	})
+	for _, questionID := range questionIDs {
+		questionStatus := status.Status[questionID]
 		switch questionStatus {
 		case "failed":
 			red.Printf("  Question %s: %s\n", questionID, questionStatus)
@@ -446,7 +541,7 @@ func submitSolution(filePath, questionId string) {
 		return
 	}
 
-	url := "http://localhost:3000/api/stu/submit"
+	url := apiBase + "/api/stu/submit"
 
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -473,7 +568,7 @@ func submitSolution(filePath, questionId string) {
 	writer.WriteField("questionId", questionId)
 
 	if !question.TestCaseBased {
-		output, err := compileAndRun(filePath)
+		output, err := compileAndRun(filePath, false)
 		if err != nil {
 			red.Println("Error compiling and running program:", err)
 			return
@@ -505,139 +600,90 @@ func submitSolution(filePath, questionId string) {
 
 	if question.TestCaseBased {
 		fmt.Println("Submission sent. Waiting for response...")
-		handleStreamedResponse(resp.Body)
+		// The verdict is stored either way; the student can pick it up again
+		id, done := handleStreamedResponse(resp.Body)
+		if id != 0 {
+			lastSubmissionID = id
+		}
+		if !done && id != 0 {
+			red.Println("Connection lost before the verdict arrived.")
+			yellow.Printf("Submission %d is still being judged; follow it with: wait %d\n", id, id)
+		}
 	} else {
 		green.Println("\nSubmitted successfully.")
 	}
 }
 
-func getQuestionById(questionId string) (Question, error) {
+func getQuestionById(questionId string) (api.Question, error) {
 	for _, q := range questions {
 		if fmt.Sprintf("%d", q.ID) == questionId {
 			return q, nil
 		}
 	}
-	return Question{}, fmt.Errorf("question not found")
+	return api.Question{}, fmt.Errorf("question not found")
 }
 
-func compileAndRun(filePath string) (string, error) {
-    if !strings.HasSuffix(filePath, ".cpp") {
-        return "", fmt.Errorf("input file must have a .cpp extension")
-    }
-
-    baseName := filepath.Base(filePath)
-    execName := strings.TrimSuffix(baseName, filepath.Ext(baseName))
-
-    // Compile the C++ file
-    compileCmd := exec.Command("g++", filePath, "-o", execName)
-    compileOutput, err := compileCmd.CombinedOutput()
-    if err != nil {
-        return "", fmt.Errorf("compilation error: %v\n%s", err, compileOutput)
-    }
-
-    fmt.Println("Compilation successful.")
-
-    // Run the compiled executable
-    cmd := exec.Command("./" + execName)
-
-    // Start the command with a pty
-    ptmx, err := pty.Start(cmd)
-    if err != nil {
-        return "", fmt.Errorf("error starting pty: %v", err)
-    }
-    defer ptmx.Close()
-
-    // Create a buffer to store the output
-    var outputBuffer bytes.Buffer
-
-    // Create a multi-writer to write to both the buffer and stdout
-    multiWriter := io.MultiWriter(&outputBuffer, os.Stdout)
-
-    // Use a WaitGroup to manage goroutines
-    var wg sync.WaitGroup
-    wg.Add(2)
-
-    // Properly manage raw mode
-    oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
-    if err != nil {
-        return "", fmt.Errorf("error setting raw mode: %v", err)
-    }
-    defer func() {
-        if err := term.Restore(int(os.Stdin.Fd()), oldState); err != nil {
-            fmt.Printf("Warning: Failed to restore terminal state: %v\n", err)
-        }
-    }()
-
-    // Copy the pty output to multiWriter
-    go func() {
-        defer wg.Done()
-        io.Copy(multiWriter, ptmx)
-    }()
-
-    // Handle input in a separate goroutine
-    go func() {
-        defer wg.Done()
-        io.Copy(ptmx, os.Stdin)
-    }()
-
-    // Use a channel to signal when the command is done
-    done := make(chan error, 1)
-    go func() {
-        done <- cmd.Wait()
-    }()
-
-    // Wait for the command to finish or timeout
-    var runErr error
-    select {
-    case err := <-done:
-        if err != nil {
-            if exitErr, ok := err.(*exec.ExitError); ok {
-                runErr = fmt.Errorf("program exited with code %d", exitErr.ExitCode())
-            } else {
-                runErr = fmt.Errorf("error waiting for program: %v", err)
-            }
-        }
-    case <-time.After(30 * time.Second):
-        runErr = fmt.Errorf("program execution timed out")
-        cmd.Process.Kill()
-    }
-
-    // Wait for goroutines to finish
-    wg.Wait()
-
-    // Clean up the executable
-    if err := os.Remove(execName); err != nil {
-        fmt.Printf("Warning: Failed to remove executable: %v\n", err)
-    }
-
-    return outputBuffer.String(), runErr
-}
+// compileAndRun builds filePath and runs it on the terminal. With debug it
+// is a sanitizer build, and where it crashed is shown afterwards.
+func compileAndRun(filePath string, debug bool, args ...string) (string, error) {
+	if !strings.HasSuffix(filePath, ".cpp") {
+		return "", fmt.Errorf("input file must have a .cpp extension")
+	}
 
-func handleStreamedResponse(body io.Reader) {
-	reader := bufio.NewReader(body)
-	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			red.Println("Error reading response:", err)
-			return
+	// Compile the C++ file, reusing a cached build when the source is unchanged
+	build := buildSource
+	if debug {
+		build = buildDebug
+	}
+	execPath, err := build(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	// Create a buffer to store the output
+	var outputBuffer bytes.Buffer
+
+	// Run inside a private workspace so nothing the program writes lands in
+	// the current directory
+	ws, err := runner.NewWorkspace("run")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if ws.Keep {
+			os.WriteFile(filepath.Join(ws.Dir, "output.log"), outputBuffer.Bytes(), 0o644)
+			yellow.Printf("Run artifacts kept in %s\n", ws.Dir)
+		}
+		ws.Close()
+	}()
+
+	if ws.Keep {
+		baseName := filepath.Base(filePath)
+		keptPath := filepath.Join(ws.Dir, strings.TrimSuffix(baseName, filepath.Ext(baseName)))
+		if err := runner.CopyFile(execPath, keptPath, 0o755); err == nil {
+			execPath = keptPath
 		}
+	}
 
-		if strings.HasPrefix(line, "data: ") {
-			data := strings.TrimPrefix(line, "data: ")
-			if strings.Contains(data, "pushed") {
-				fmt.Println("Request sent for execution")
-			} else if strings.Contains(data, "start") {
-				fmt.Println("Worker has picked up the request")
-			} else if strings.Contains(data, "status") {
-				fmt.Println("Compilation result:")
-				fmt.Println(data)
-			} else if strings.Contains(data, "passed") {
-				fmt.Println("Execution completed:")
-				fmt.Println(data)
-			}
+	// Run the compiled executable
+	cmd := exec.Command(execPath, args...)
+	cmd.Dir = ws.Dir
+	if debug {
+		cmd.Env = runner.SanitizerEnv(0)
+	}
+
+	// Create a multi-writer to write to both the buffer and stdout
+	multiWriter := io.MultiWriter(&outputBuffer, os.Stdout)
+
+	yellow.Printf("Running %s (press %s to abort)\n", filepath.Base(filePath), abortKeyName)
+	runErr := runInPty(cmd, multiWriter, 30*time.Second)
+
+	if debug {
+		// The report went by on the terminal; sum it up
+		if crash, _ := runner.ParseSanitizerReport(strings.ReplaceAll(outputBuffer.String(), "\r\n", "\n")); crash != nil {
+			fmt.Println()
+			printCrash(crash)
 		}
 	}
-}
\ No newline at end of file
+	return outputBuffer.String(), runErr
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+
+	"new_cli/api"
+	"new_cli/fakeapi"
+	"new_cli/runner"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// newFake starts a fake Lab API and points the CLI at it with a clean
+// session state.
+func newFake(t *testing.T) *fakeapi.Server {
+	t.Helper()
+	fake := fakeapi.New()
+	t.Cleanup(fake.Close)
+
+	oldBase := apiBase
+	apiBase = fake.URL
+	t.Cleanup(func() { apiBase = oldBase })
+
+	studentID, studentInfo, labSessionID = "", api.Student{}, ""
+	questions, labSessions, lastFailed, lastSubmissionID = nil, nil, nil, 0
+	failedByQuestion = map[string][]runner.TestResult{}
+	return fake
+}
+
+// capture runs fn with stdout redirected and returns what it printed, with
+// colors disabled so the output is stable.
+func capture(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, colorOutput, noColor := os.Stdout, color.Output, color.NoColor
+	os.Stdout, color.Output, color.NoColor = w, w, true
+	defer func() {
+		os.Stdout, color.Output, color.NoColor = stdout, colorOutput, noColor
+	}()
+
+	done := make(chan string)
+	go func() {
+		out, _ := io.ReadAll(r)
+		done <- string(out)
+	}()
+	fn()
+	w.Close()
+	return <-done
+}
+
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file (run with -update to create it): %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("output of %s differs from %s\n--- got ---\n%s\n--- want ---\n%s", name, path, got, want)
+	}
+}
+
+// login verifies student 1 and loads today's questions, as main does before
+// starting the REPL.
+func login(t *testing.T, fake *fakeapi.Server) {
+	t.Helper()
+	studentID = "1"
+	capture(t, func() {
+		if !verifyStudentID() {
+			t.Fatal("student 1 should be valid")
+		}
+	})
+	session, err := fake.Store.LabSession(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	questions = session.Questions
+	labSessionID = "1"
+}
+
+func TestVerifyStudentID(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		ok    bool
+	}{
+		{"verify_found", "1", true},
+		{"verify_not_found", "404", false},
+		{"verify_invalid", "abc", false},
+		{"verify_empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newFake(t)
+			studentID = tt.input
+			var ok bool
+			out := capture(t, func() { ok = verifyStudentID() })
+			if ok != tt.ok {
+				t.Errorf("verifyStudentID(%q) = %v, want %v", tt.input, ok, tt.ok)
+			}
+			checkGolden(t, tt.name, out)
+		})
+	}
+}
+
+func TestHelpCommand(t *testing.T) {
+	newFake(t)
+	checkGolden(t, "help", capture(t, func() { handleCommand("help") }))
+}
+
+func TestUnknownAndUsage(t *testing.T) {
+	fake := newFake(t)
+	login(t, fake)
+	out := capture(t, func() {
+		handleCommand("frobnicate")
+		handleCommand("submit only-one-arg")
+		handleCommand("set something")
+		handleCommand("run")
+	})
+	checkGolden(t, "usage", out)
+}
+
+func TestStatusCommand(t *testing.T) {
+	fake := newFake(t)
+	login(t, fake)
+
+	fake.SetScenario(fakeapi.PartialPass)
+	src := writeSolution(t)
+	capture(t, func() { handleCommand("submit " + src + " 1") })
+
+	checkGolden(t, "status", capture(t, func() { handleCommand("status") }))
+}
+
+func writeSolution(t *testing.T) string {
+	t.Helper()
+	src := filepath.Join(t.TempDir(), "sum.cpp")
+	code := "#include <iostream>\nint main() { int a, b; std::cin >> a >> b; std::cout << a + b << std::endl; }\n"
+	if err := os.WriteFile(src, []byte(code), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func TestSubmitScenarios(t *testing.T) {
+	tests := []struct {
+		name     string
+		scenario fakeapi.Scenario
+	}{
+		{"submit_accepted", fakeapi.Accepted},
+		{"submit_compile_error", fakeapi.CompileError},
+		{"submit_partial_pass", fakeapi.PartialPass},
+		{"submit_timeout", fakeapi.TimeLimit},
+		{"submit_slow_stream", fakeapi.SlowStream},
+		{"submit_queued", fakeapi.Queued},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFake(t)
+			login(t, fake)
+			fake.SetScenario(tt.scenario)
+
+			src := writeSolution(t)
+			out := capture(t, func() { handleCommand("submit " + src + " 1") })
+			checkGolden(t, tt.name, out)
+
+			enqueued := fake.Enqueued()
+			if len(enqueued) != 1 {
+				t.Fatalf("judge received %d submissions, want 1", len(enqueued))
+			}
+			if got := enqueued[0]; got.Channel() != "submission:1" || got.StudentID != "1" || got.QuestionID != "1" || len(got.TestCases) != 2 {
+				t.Errorf("unexpected queued submission %+v", got)
+			}
+		})
+	}
+}
+
+func TestSubmitUnknownQuestion(t *testing.T) {
+	fake := newFake(t)
+	login(t, fake)
+	out := capture(t, func() { handleCommand("submit " + writeSolution(t) + " 99") })
+	checkGolden(t, "submit_unknown_question", out)
+	if n := len(fake.Enqueued()); n != 0 {
+		t.Errorf("judge received %d submissions, want 0", n)
+	}
+}
+
+// postSubmission submits a solution to question 1 as student 1 without the
+// CLI, leaving the event stream to the caller.
+func postSubmission(t *testing.T, fake *fakeapi.Server) *http.Response {
+	t.Helper()
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	part, _ := form.CreateFormFile("solution", "sum.cpp")
+	part.Write([]byte("int main() {}\n"))
+	form.WriteField("studentId", "1")
+	form.WriteField("questionId", "1")
+	form.Close()
+	resp, err := http.Post(fake.URL+"/api/stu/submit", form.FormDataContentType(), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestConcurrentSubmissionsKeepTheirEvents(t *testing.T) {
+	fake := newFake(t)
+	fake.SetScenario(fakeapi.Scenario{Events: fakeapi.PartialPass.Events, Delay: 100 * time.Millisecond})
+	first := postSubmission(t, fake)
+	defer first.Body.Close()
+	fake.SetScenario(fakeapi.Scenario{Events: fakeapi.Accepted.Events, Delay: 100 * time.Millisecond})
+	second := postSubmission(t, fake)
+	defer second.Body.Close()
+
+	streams := make([][]byte, 2)
+	var wg sync.WaitGroup
+	for i, resp := range []*http.Response{first, second} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			streams[i], _ = io.ReadAll(resp.Body)
+		}()
+	}
+	wg.Wait()
+
+	if n := strings.Count(string(streams[0]), `"end":true`); n != 1 || !strings.Contains(string(streams[0]), `"status":"failed"`) {
+		t.Errorf("first submission's stream:\n%s", streams[0])
+	}
+	if n := strings.Count(string(streams[1]), `"end":true`); n != 1 || !strings.Contains(string(streams[1]), `"status":"passed"`) {
+		t.Errorf("second submission's stream:\n%s", streams[1])
+	}
+	if enqueued := fake.Enqueued(); enqueued[0].Channel() == enqueued[1].Channel() {
+		t.Errorf("both submissions published on %s", enqueued[0].Channel())
+	}
+}
+
+func TestProgressBarRedrawsOneLine(t *testing.T) {
+	out := capture(t, func() {
+		status := &statusLine{live: true}
+		bar := &progressBar{line: status}
+		bar.update(testProgress{Test: 1, Total: 3, Verdict: runner.Accepted, Time: 5})
+		bar.update(testProgress{Test: 2, Total: 3, Verdict: runner.RuntimeError, Time: 7})
+		status.done()
+		status.done()
+	})
+	want := "\r\x1b[KTest 1/3 ✓  ██████░░░░░░░░░░░░░░  1 passed, 0 failed  (5 ms)" +
+		"\r\x1b[KTest 2/3 ✗ RE  █████████████░░░░░░░  1 passed, 1 failed  (7 ms)\n"
+	if out != want {
+		t.Errorf("got %q\nwant %q", out, want)
+	}
+}
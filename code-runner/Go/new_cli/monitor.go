@@ -0,0 +1,409 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/term"
+
+	"new_cli/api"
+)
+
+// redisAddr is where the monitor listens for new submissions, which the
+// server publishes on a channel named by the lab session ID.
+var redisAddr = envOr("BISKUT_REDIS", "localhost:6379")
+
+const monitorLogSize = 200
+
+type monitorCell struct {
+	status string
+	count  int
+	last   time.Time
+}
+
+type monitorRow struct {
+	student api.Student
+	cells   map[int]*monitorCell // by question ID
+}
+
+// monitorState is the lab session as the dashboard shows it, built from the
+// existing submissions and then updated by the live feed.
+type monitorState struct {
+	session api.LabSession
+	rows    map[int]*monitorRow // by student ID
+	absent  []string            // enrollment numbers with no submission yet
+	seen    map[int]string      // status by submission ID, as the feed can repeat the initial load
+	log     []string
+}
+
+func newMonitorState(session api.LabSession) *monitorState {
+	return &monitorState{session: session, rows: map[int]*monitorRow{}, seen: map[int]string{}}
+}
+
+// apply records a submission, or the verdict on one already seen, and
+// reports whether anything changed.
+func (m *monitorState) apply(sub api.Submission, student api.Student) bool {
+	status, seen := m.seen[sub.ID]
+	if seen && status == sub.Status {
+		return false
+	}
+	m.seen[sub.ID] = sub.Status
+
+	row := m.rows[sub.StudentID]
+	if row == nil {
+		row = &monitorRow{student: student, cells: map[int]*monitorCell{}}
+		m.rows[sub.StudentID] = row
+		for i, enrollment := range m.absent {
+			if enrollment == student.EnrollmentNumber {
+				m.absent = append(m.absent[:i], m.absent[i+1:]...)
+				break
+			}
+		}
+	}
+	cell := row.cells[sub.QuestionID]
+	if cell == nil {
+		cell = &monitorCell{}
+		row.cells[sub.QuestionID] = cell
+	}
+	at, _ := time.Parse(api.TimeFormat, sub.SubmissionTime)
+	if !seen {
+		cell.count++
+	}
+	// A verdict only changes the cell if no later attempt came in since
+	if !seen || !at.Before(cell.last) {
+		cell.status = sub.Status
+		cell.last = at
+	}
+
+	line := fmt.Sprintf("%s  %-10s %-20s Q%-4d %s", at.Local().Format("15:04:05"), student.EnrollmentNumber, student.Name, sub.QuestionID, sub.Status)
+	if seen {
+		line += " (verdict)"
+	} else if cell.count > 1 {
+		line += fmt.Sprintf(" (submission %d)", cell.count)
+	}
+	m.log = append(m.log, line)
+	if len(m.log) > monitorLogSize {
+		m.log = m.log[len(m.log)-monitorLogSize:]
+	}
+	return true
+}
+
+func (m *monitorState) questionIDs() []int {
+	// Questions moved out of the session still show if they have submissions
+	set := map[int]bool{}
+	for _, q := range m.session.Questions {
+		set[q.ID] = true
+	}
+	for _, row := range m.rows {
+		for id := range row.cells {
+			set[id] = true
+		}
+	}
+	ids := make([]int, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func (m *monitorState) sortedRows() []*monitorRow {
+	rows := make([]*monitorRow, 0, len(m.rows))
+	for _, row := range m.rows {
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].student.EnrollmentNumber < rows[j].student.EnrollmentNumber
+	})
+	return rows
+}
+
+var (
+	passedCell  = color.New(color.FgGreen)
+	failedCell  = color.New(color.FgRed)
+	pendingCell = color.New(color.FgYellow)
+	emptyCell   = color.New(color.Faint)
+)
+
+const (
+	monitorNameWidth = 32
+	monitorCellWidth = 12
+)
+
+func (c *monitorCell) String() string {
+	if c == nil {
+		return emptyCell.Sprintf("%-*s", monitorCellWidth, "·")
+	}
+	mark, paint := "?", pendingCell
+	switch c.status {
+	case api.StatusPassed:
+		mark, paint = "✓", passedCell
+	case api.StatusFailed:
+		mark, paint = "✗", failedCell
+	case api.StatusCancelled:
+		mark, paint = "-", emptyCell
+	}
+	return paint.Sprintf("%s%-2d %-*s", mark, c.count, monitorCellWidth-4, c.last.Local().Format("15:04"))
+}
+
+func truncate(s string, width int) string {
+	if r := []rune(s); len(r) > width {
+		return string(r[:width-1]) + "…"
+	}
+	return s
+}
+
+// render draws the dashboard into at most height lines: a header, the grid
+// of students × questions, a summary and as much of the event log as fits.
+func (m *monitorState) render(width, height int, now time.Time) []string {
+	var lines []string
+	header := fmt.Sprintf("Lab session %d: %s, %s (%s)", m.session.ID, m.session.Program.Name, m.session.Description, sessionDay(m.session.SessionDate))
+	lines = append(lines, bold.Sprint(truncate(header, width)))
+	lines = append(lines, fmt.Sprintf("Updated %s. Press q to quit.", now.Local().Format("15:04:05")))
+	lines = append(lines, "")
+
+	questionIDs := m.questionIDs()
+	columns := max(1, (width-monitorNameWidth)/monitorCellWidth)
+	if len(questionIDs) > columns {
+		questionIDs = questionIDs[:columns]
+	}
+	head := fmt.Sprintf("%-*s", monitorNameWidth, "Student")
+	for _, id := range questionIDs {
+		head += fmt.Sprintf("%-*s", monitorCellWidth, "Q"+strconv.Itoa(id))
+	}
+	lines = append(lines, bold.Sprint(head))
+
+	// Keep room for the summary and the last few events
+	rows := m.sortedRows()
+	gridRoom := max(1, height-len(lines)-3-min(len(m.log), 3))
+	shown := rows
+	if len(rows) > gridRoom {
+		shown = rows[:gridRoom-1]
+	}
+	var passed, failed, pending, total int
+	for _, row := range rows {
+		for _, cell := range row.cells {
+			total += cell.count
+			switch cell.status {
+			case api.StatusPassed:
+				passed++
+			case api.StatusFailed:
+				failed++
+			case api.StatusCancelled:
+			default:
+				pending++
+			}
+		}
+	}
+	for _, row := range shown {
+		name := truncate(row.student.EnrollmentNumber+" "+row.student.Name, monitorNameWidth-2)
+		line := fmt.Sprintf("%-*s", monitorNameWidth, name)
+		for _, id := range questionIDs {
+			line += row.cells[id].String()
+		}
+		lines = append(lines, line)
+	}
+	if len(shown) < len(rows) {
+		lines = append(lines, fmt.Sprintf("… %d more students", len(rows)-len(shown)))
+	}
+	if len(rows) == 0 {
+		lines = append(lines, emptyCell.Sprint("No submissions yet."))
+	}
+
+	lines = append(lines, "")
+	summary := fmt.Sprintf("Submitted: %d/%d students, %d submissions. Latest: %d passed, %d failed, %d pending.",
+		len(rows), len(rows)+len(m.absent), total, passed, failed, pending)
+	lines = append(lines, truncate(summary, width))
+	lines = append(lines, bold.Sprint("Events"))
+
+	logRoom := max(0, height-len(lines))
+	start := max(0, len(m.log)-logRoom)
+	for _, line := range m.log[start:] {
+		lines = append(lines, truncate(line, width))
+	}
+	return lines
+}
+
+func handleMonitorCommand(args []string) {
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "--redis" {
+			redisAddr = args[i+1]
+			args = append(args[:i], args[i+2:]...)
+			break
+		}
+	}
+	if len(args) != 1 {
+		red.Println("Usage: monitor <labSessionID> [--redis host:port]")
+		return
+	}
+	if _, err := strconv.Atoi(args[0]); err != nil {
+		red.Println("Lab session ID must be a number.")
+		return
+	}
+	if err := runMonitor(args[0]); err != nil {
+		red.Println("Error running monitor:", err)
+	}
+}
+
+// studentLookup resolves the student of a live submission, which the
+// server publishes without its relations. It is not safe for concurrent
+// use.
+type studentLookup map[int]api.Student
+
+var lookupClient = &http.Client{Timeout: 5 * time.Second}
+
+func (l studentLookup) get(id int) api.Student {
+	if s, ok := l[id]; ok {
+		return s
+	}
+	s := api.Student{ID: id, Name: fmt.Sprintf("student %d", id)}
+	resp, err := lookupClient.Get(fmt.Sprintf("%s/api/stu?studentId=%d", apiBase, id))
+	if err == nil {
+		if resp.StatusCode == http.StatusOK {
+			json.NewDecoder(resp.Body).Decode(&s)
+		}
+		resp.Body.Close()
+	}
+	l[id] = s
+	return s
+}
+
+func runMonitor(labSessionID string) error {
+	fd := int(os.Stdout.Fd())
+	if !term.IsTerminal(fd) {
+		return fmt.Errorf("monitor needs a terminal")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Subscribe before loading what is already there so nothing submitted
+	// in between is missed; apply drops the duplicates
+	rdb := redis.NewClient(&redis.Options{Addr: redisAddr})
+	defer rdb.Close()
+	ps := rdb.Subscribe(ctx, labSessionID)
+	defer ps.Close()
+	if _, err := ps.Receive(ctx); err != nil {
+		return fmt.Errorf("subscribing to %s: %v", redisAddr, err)
+	}
+
+	var session api.LabSession
+	if err := instructorRequest("GET", "/labsession?labSessionId="+url.QueryEscape(labSessionID), nil, &session); err != nil {
+		return fmt.Errorf("fetching lab session: %v", err)
+	}
+	var submissions []api.Submission
+	if err := instructorRequest("GET", "/submissions?labSessionId="+url.QueryEscape(labSessionID), nil, &submissions); err != nil {
+		return fmt.Errorf("fetching submissions: %v", err)
+	}
+	var attendance struct {
+		Attendance map[string]bool `json:"attendance"`
+	}
+	if err := instructorRequest("GET", "/attendance?labSessionId="+url.QueryEscape(labSessionID), nil, &attendance); err != nil {
+		return fmt.Errorf("fetching attendance: %v", err)
+	}
+
+	state := newMonitorState(session)
+	students := studentLookup{}
+	for enrollment, present := range attendance.Attendance {
+		if !present {
+			state.absent = append(state.absent, enrollment)
+		}
+	}
+	for _, sub := range submissions {
+		if sub.Student != nil {
+			students[sub.StudentID] = *sub.Student
+		}
+		state.apply(sub, students.get(sub.StudentID))
+	}
+
+	stdin, restore, err := enterRawMode(func() {
+		fmt.Print("\x1b[?25h\x1b[?1049l")
+	})
+	if err != nil {
+		return err
+	}
+	defer restore()
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+
+	keys := make(chan byte)
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if _, err := stdin.Read(buf); err != nil {
+				if err == io.EOF {
+					close(keys)
+				}
+				return
+			}
+			select {
+			case keys <- buf[0]:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	defer stdin.Cancel()
+
+	draw := func() {
+		width, height, err := term.GetSize(fd)
+		if err != nil {
+			width, height = 80, 24
+		}
+		lines := state.render(width, height, time.Now())
+		fmt.Print("\x1b[H\x1b[2J" + strings.Join(lines, "\r\n"))
+	}
+	draw()
+
+	// Students new to the monitor are looked up here, in order, so that
+	// waiting for the server never holds up redraws or keys
+	type update struct {
+		sub     api.Submission
+		student api.Student
+	}
+	updates := make(chan update)
+	go func() {
+		defer close(updates)
+		for msg := range ps.Channel() {
+			var sub api.Submission
+			if err := json.Unmarshal([]byte(msg.Payload), &sub); err != nil {
+				continue
+			}
+			select {
+			case updates <- update{sub, students.get(sub.StudentID)}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case u, ok := <-updates:
+			if !ok {
+				return fmt.Errorf("lost connection to %s", redisAddr)
+			}
+			if state.apply(u.sub, u.student) {
+				draw()
+			}
+		case key, ok := <-keys:
+			if !ok || key == 'q' || key == 'Q' || key == 3 {
+				return nil
+			}
+		case <-ticker.C:
+			draw()
+		}
+	}
+}
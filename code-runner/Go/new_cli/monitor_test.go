@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+
+	"new_cli/api"
+	"new_cli/fakeapi"
+)
+
+func TestMonitorRender(t *testing.T) {
+	oldLocal, noColor := time.Local, color.NoColor
+	time.Local, color.NoColor = time.UTC, true
+	t.Cleanup(func() { time.Local, color.NoColor = oldLocal, noColor })
+
+	session := api.LabSession{
+		ID:          1,
+		SessionDate: fakeapi.Now.Format(api.TimeFormat),
+		Description: "Week 1",
+		Program:     api.Program{Name: "Programming Lab"},
+		Questions:   []api.Question{{ID: 1}, {ID: 2}, {ID: 3}},
+	}
+	state := newMonitorState(session)
+	state.absent = []string{"EN002", "EN003"}
+
+	alice := api.Student{ID: 1, Name: "Alice", EnrollmentNumber: "EN001"}
+	bob := api.Student{ID: 3, Name: "Bob", EnrollmentNumber: "EN003"}
+	at := func(minutes int) string {
+		return fakeapi.Now.Add(time.Duration(minutes) * time.Minute).Format(api.TimeFormat)
+	}
+	subs := []struct {
+		sub     api.Submission
+		student api.Student
+	}{
+		{api.Submission{ID: 1, StudentID: 1, QuestionID: 1, Status: api.StatusFailed, SubmissionTime: at(5)}, alice},
+		{api.Submission{ID: 2, StudentID: 1, QuestionID: 1, Status: api.StatusPassed, SubmissionTime: at(9)}, alice},
+		{api.Submission{ID: 3, StudentID: 3, QuestionID: 2, Status: api.StatusPending, SubmissionTime: at(12)}, bob},
+		{api.Submission{ID: 4, StudentID: 3, QuestionID: 1, Status: api.StatusFailed, SubmissionTime: at(15)}, bob},
+		{api.Submission{ID: 5, StudentID: 3, QuestionID: 3, Status: api.StatusPending, SubmissionTime: at(16)}, bob},
+	}
+	for _, s := range subs {
+		if !state.apply(s.sub, s.student) {
+			t.Errorf("submission %d reported as seen before", s.sub.ID)
+		}
+	}
+	if state.apply(subs[0].sub, alice) {
+		t.Error("a repeated submission was applied twice")
+	}
+	verdict := subs[4].sub
+	verdict.Status = api.StatusPassed
+	if !state.apply(verdict, bob) {
+		t.Error("the verdict on a pending submission was not applied")
+	}
+	if cell := state.rows[3].cells[3]; cell.status != api.StatusPassed || cell.count != 1 {
+		t.Errorf("cell after verdict = %+v, want passed after 1 submission", cell)
+	}
+	if len(state.absent) != 1 || state.absent[0] != "EN002" {
+		t.Errorf("absent = %v after EN003 submitted, want [EN002]", state.absent)
+	}
+
+	now := fakeapi.Now.Add(20 * time.Minute)
+	checkGolden(t, "monitor", strings.Join(state.render(80, 24, now), "\n")+"\n")
+	checkGolden(t, "monitor_small", strings.Join(state.render(60, 12, now), "\n")+"\n")
+}
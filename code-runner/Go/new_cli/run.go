@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"new_cli/runner"
+)
+
+// lastFailed holds the failing test cases of the most recent verdict so they
+// can be replayed locally with `run --case`.
+var lastFailed []runner.TestResult
+
+// failedByQuestion holds the failing test cases of the latest verdict of each
+// question, for `debug`.
+var failedByQuestion = map[string][]runner.TestResult{}
+
+// rememberVerdict records the failed test cases from the final event of a
+// submission stream.
+func rememberVerdict(data string) {
+	var verdict struct {
+		Failed     []runner.TestResult `json:"failed"`
+		QuestionID string              `json:"questionId"`
+	}
+	if err := json.Unmarshal([]byte(data), &verdict); err != nil {
+		return
+	}
+	lastFailed = verdict.Failed
+	if verdict.QuestionID != "" {
+		failedByQuestion[verdict.QuestionID] = verdict.Failed
+	}
+	for i, result := range lastFailed {
+		if result.Crash != nil {
+			fmt.Printf("Test case %d:\n", i+1)
+			printCrash(result.Crash)
+		}
+	}
+	if len(lastFailed) > 0 {
+		yellow.Printf("Replay a failing test case locally with: run <file> --case <1-%d>\n", len(lastFailed))
+		if verdict.QuestionID != "" {
+			yellow.Printf("or step through it in gdb with: debug <file> %s --case <1-%d>\n", verdict.QuestionID, len(lastFailed))
+		}
+	}
+}
+
+func printRunUsage() {
+	red.Println("Usage: run <file> [--input in.txt | --case N] [--expect out.txt] [--debug] [--args ...]")
+}
+
+// absArgs makes the program arguments that name existing files absolute.
+// The program runs in a workspace of its own, where paths relative to the
+// user's directory would name nothing.
+func absArgs(args []string) []string {
+	resolved := make([]string, len(args))
+	for i, arg := range args {
+		resolved[i] = arg
+		if filepath.IsAbs(arg) || strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if _, err := os.Stat(arg); err != nil {
+			continue
+		}
+		if abs, err := filepath.Abs(arg); err == nil {
+			resolved[i] = abs
+		}
+	}
+	return resolved
+}
+
+func handleRunCommand(args []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		printRunUsage()
+		return
+	}
+	filePath := args[0]
+	args = args[1:]
+
+	// Everything after --args belongs to the program
+	var programArgs []string
+	for i, arg := range args {
+		if arg == "--args" || arg == "-args" {
+			programArgs = absArgs(args[i+1:])
+			args = args[:i]
+			break
+		}
+	}
+
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	inputPath := fs.String("input", "", "file to use as stdin")
+	expectPath := fs.String("expect", "", "file with the expected output")
+	caseNum := fs.Int("case", 0, "failing test case from the last verdict")
+	debug := fs.Bool("debug", false, "build with sanitizers to find where the program crashes")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		printRunUsage()
+		return
+	}
+
+	var input, expected []byte
+	hasInput, hasExpected := false, false
+
+	if *caseNum != 0 {
+		if *caseNum < 1 || *caseNum > len(lastFailed) {
+			red.Printf("No failing test case %d in the last verdict (%d available).\n", *caseNum, len(lastFailed))
+			return
+		}
+		tc := lastFailed[*caseNum-1]
+		input, expected = []byte(tc.Input), []byte(tc.Expected)
+		hasInput, hasExpected = true, true
+	}
+	if *inputPath != "" {
+		data, err := os.ReadFile(*inputPath)
+		if err != nil {
+			red.Println("Error reading input file:", err)
+			return
+		}
+		input, hasInput = data, true
+	}
+	if *expectPath != "" {
+		data, err := os.ReadFile(*expectPath)
+		if err != nil {
+			red.Println("Error reading expected output:", err)
+			return
+		}
+		expected, hasExpected = data, true
+	}
+
+	// Without saved input the program talks to the keyboard as usual
+	if !hasInput {
+		output, err := compileAndRun(filePath, *debug, programArgs...)
+		if err != nil {
+			red.Println("\nError compiling and running program:", err)
+			suggestDebug(err, *debug)
+			return
+		}
+		if hasExpected {
+			// The pty translates newlines; undo that before comparing
+			printComparison(string(input), strings.ReplaceAll(output, "\r\n", "\n"), string(expected))
+		}
+		return
+	}
+
+	output, err := runWithInput(filePath, programArgs, input, *debug)
+	if err != nil {
+		red.Println("Error running program:", err)
+		suggestDebug(err, *debug)
+		return
+	}
+	if hasExpected {
+		printComparison(string(input), output, string(expected))
+	}
+}
+
+// crashError is a program killed by a signal.
+type crashError struct {
+	signal syscall.Signal
+}
+
+func (e crashError) Error() string {
+	return runner.DescribeSignal(e.signal)
+}
+
+// suggestDebug points at --debug after a crash it would have explained.
+func suggestDebug(err error, debug bool) {
+	if errors.As(err, new(crashError)) && !debug {
+		yellow.Println("Run it again with --debug to see where it crashed.")
+	}
+}
+
+// runWithInput builds filePath and runs it in the sandbox with input as
+// stdin, echoing its output. With debug it is a sanitizer build, and where
+// it crashed is shown instead of the sanitizer's report.
+func runWithInput(filePath string, args []string, input []byte, debug bool) (string, error) {
+	if !strings.HasSuffix(filePath, ".cpp") {
+		return "", fmt.Errorf("input file must have a .cpp extension")
+	}
+
+	build := buildSource
+	if debug {
+		build = buildDebug
+	}
+	execPath, err := build(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	ws, err := runner.NewWorkspace("run")
+	if err != nil {
+		return "", err
+	}
+	defer ws.Close()
+
+	var output bytes.Buffer
+	limits := runner.DefaultLimits
+	limits.Time = 30 * time.Second
+	spec := runner.Spec{
+		Path:   execPath,
+		Args:   args,
+		Dir:    ws.Dir,
+		Stdin:  bytes.NewReader(input),
+		Stdout: io.MultiWriter(&output, os.Stdout),
+		Limits: limits,
+	}
+	if debug {
+		spec.Env, spec.Limits.Memory = runner.SanitizerEnv(spec.Limits.Memory), 0
+	}
+	res, err := runner.Run(context.Background(), spec)
+	if err != nil {
+		return "", err
+	}
+
+	crash, stderr := runner.ParseSanitizerReport(string(res.Stderr))
+	os.Stderr.WriteString(stderr)
+	if crash != nil {
+		fmt.Println()
+		printCrash(crash)
+		return output.String(), fmt.Errorf("program crashed")
+	}
+
+	switch {
+	case res.TimedOut:
+		return output.String(), fmt.Errorf("program execution timed out")
+	case res.Signal != 0:
+		return output.String(), crashError{res.Signal}
+	case res.ExitCode != 0:
+		return output.String(), fmt.Errorf("program exited with code %d", res.ExitCode)
+	}
+	fmt.Printf("\nFinished in %d ms.\n", res.Time.Milliseconds())
+	return output.String(), nil
+}
+
+// printComparison grades output the same way the judge does and points at
+// the first line that differs.
+func printComparison(input, output, expected string) {
+	verdict, _, _ := runner.ExactChecker{}.Check(context.Background(), input, output, expected)
+	if verdict == runner.Accepted {
+		green.Println("Output matches the expected output.")
+		return
+	}
+
+	red.Println("Output does not match the expected output.")
+	got := strings.Split(strings.TrimSpace(output), "\n")
+	want := strings.Split(strings.TrimSpace(expected), "\n")
+	for i := 0; i < len(got) || i < len(want); i++ {
+		var g, w string
+		if i < len(got) {
+			g = got[i]
+		}
+		if i < len(want) {
+			w = want[i]
+		}
+		if g != w {
+			fmt.Printf("First difference at line %d:\n", i+1)
+			green.Printf("  expected: %q\n", w)
+			red.Printf("  got:      %q\n", g)
+			return
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAbsArgs(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "data.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	got := absArgs([]string{"data.txt", "missing.txt", "5", "-v", "/etc/hosts"})
+	want := []string{filepath.Join(dir, "data.txt"), "missing.txt", "5", "-v", "/etc/hosts"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("absArgs = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,231 @@
+package runner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultCacheSize = 512 << 20
+
+// Cache stores compiled executables keyed by a hash of the source, the local
+// headers it includes, the compiler and its flags, so unchanged files are
+// only built once.
+type Cache struct {
+	Dir      string
+	MaxBytes int64
+}
+
+// DefaultCache lives under the user cache dir. BISKUT_CACHE_MAX_MB overrides
+// the size limit.
+func DefaultCache() (*Cache, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("error locating cache dir: %v", err)
+	}
+
+	maxBytes := int64(defaultCacheSize)
+	if mb := os.Getenv("BISKUT_CACHE_MAX_MB"); mb != "" {
+		n, err := strconv.ParseInt(mb, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid BISKUT_CACHE_MAX_MB %q", mb)
+		}
+		maxBytes = n << 20
+	}
+
+	return &Cache{Dir: filepath.Join(dir, "biskut", "build"), MaxBytes: maxBytes}, nil
+}
+
+var (
+	versionMu sync.Mutex
+	versions  = map[string]string{}
+)
+
+// compilerVersion is part of the key so upgrading g++ invalidates old builds.
+func compilerVersion(path string) string {
+	versionMu.Lock()
+	defer versionMu.Unlock()
+	if v, ok := versions[path]; ok {
+		return v
+	}
+	out, _ := exec.Command(path, "--version").Output()
+	versions[path] = string(out)
+	return versions[path]
+}
+
+// key hashes everything a build of src, whose contents are source, depends
+// on.
+func (c *Cache) key(compiler Compiler, src string, source []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00", compiler.Path, compilerVersion(compiler.Path))
+	for _, flag := range compiler.Flags {
+		fmt.Fprintf(h, "%s\x00", flag)
+	}
+	// A new testlib.h changes what checkers compile to
+	h.Write(testlibHeader)
+	h.Write(source)
+	hashIncludes(h, filepath.Dir(src), source, map[string]bool{})
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+var quotedInclude = regexp.MustCompile(`(?m)^\s*#\s*include\s*"([^"]+)"`)
+
+// hashIncludes adds the headers source includes with quotes, and those they
+// include in turn, to h, so that editing a header next to the source makes
+// a new build. Headers not found relative to dir come from an include path,
+// such as testlib.h, and are left out.
+func hashIncludes(h io.Writer, dir string, source []byte, seen map[string]bool) {
+	for _, m := range quotedInclude.FindAllSubmatch(source, -1) {
+		path := string(m[1])
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		header, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(h, "\x00%s\x00", m[1])
+		h.Write(header)
+		hashIncludes(h, filepath.Dir(path), header, seen)
+	}
+}
+
+// Build returns the path of an executable for src, compiling it only on a
+// cache miss. The returned bool reports whether the build was reused.
+func (c *Cache) Build(ctx context.Context, compiler Compiler, src string) (string, bool, error) {
+	source, err := os.ReadFile(src)
+	if err != nil {
+		return "", false, fmt.Errorf("error reading %s: %v", src, err)
+	}
+
+	binary := filepath.Join(c.Dir, c.key(compiler, src, source))
+	if _, err := os.Stat(binary); err == nil {
+		now := time.Now()
+		os.Chtimes(binary, now, now)
+		return binary, true, nil
+	}
+
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return "", false, fmt.Errorf("error creating cache dir: %v", err)
+	}
+
+	// Build in a private directory next to the final path and rename, so
+	// concurrent builds of the same file never see a half-written executable.
+	tmp, err := os.MkdirTemp(c.Dir, "tmp-")
+	if err != nil {
+		return "", false, fmt.Errorf("error creating build dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	out := filepath.Join(tmp, "a.out")
+	if err := compiler.Compile(ctx, src, out); err != nil {
+		return "", false, err
+	}
+	if err := os.Rename(out, binary); err != nil {
+		return "", false, fmt.Errorf("error storing build: %v", err)
+	}
+
+	c.prune(binary)
+	return binary, false, nil
+}
+
+type cacheEntry struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+func (c *Cache) entries() ([]cacheEntry, error) {
+	dirEntries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var entries []cacheEntry
+	for _, e := range dirEntries {
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if strings.HasPrefix(e.Name(), "tmp-") {
+			// Left behind by a build that was killed mid-way.
+			if time.Since(info.ModTime()) > time.Hour {
+				os.RemoveAll(filepath.Join(c.Dir, e.Name()))
+			}
+			continue
+		}
+		if info.IsDir() {
+			continue
+		}
+		entries = append(entries, cacheEntry{filepath.Join(c.Dir, e.Name()), info.Size(), info.ModTime()})
+	}
+	return entries, nil
+}
+
+// Stats reports the number of cached builds and their total size.
+func (c *Cache) Stats() (int, int64, error) {
+	entries, err := c.entries()
+	if err != nil {
+		return 0, 0, err
+	}
+	var total int64
+	for _, e := range entries {
+		total += e.size
+	}
+	return len(entries), total, nil
+}
+
+// Clean removes every cached build.
+func (c *Cache) Clean() error {
+	return os.RemoveAll(c.Dir)
+}
+
+// prune evicts the least recently used builds until the cache fits in
+// MaxBytes. The build at keep, which the caller is about to run, stays even
+// if a concurrent build made it the oldest.
+func (c *Cache) prune(keep string) {
+	if c.MaxBytes <= 0 {
+		return
+	}
+	entries, err := c.entries()
+	if err != nil {
+		return
+	}
+
+	var total int64
+	for _, e := range entries {
+		total += e.size
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+	for _, e := range entries {
+		if total <= c.MaxBytes {
+			break
+		}
+		if e.path == keep {
+			continue
+		}
+		if os.Remove(e.path) == nil {
+			total -= e.size
+		}
+	}
+}
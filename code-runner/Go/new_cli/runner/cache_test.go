@@ -0,0 +1,152 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheKey(t *testing.T) {
+	c := &Cache{}
+	gxx := Compiler{Path: "g++"}
+	source := []byte("int main() {}\n")
+
+	key := c.key(gxx, "main.cpp", source)
+	if again := c.key(gxx, "main.cpp", []byte("int main() {}\n")); again != key {
+		t.Errorf("same build got keys %s and %s", key, again)
+	}
+	for name, other := range map[string]string{
+		"source":   c.key(gxx, "main.cpp", []byte("int main() { return 1; }\n")),
+		"flags":    c.key(gxx.WithSanitizers(), "main.cpp", source),
+		"compiler": c.key(Compiler{Path: "clang++"}, "main.cpp", source),
+	} {
+		if other == key {
+			t.Errorf("a different %s has the same key", name)
+		}
+	}
+}
+
+// Editing a local header, even one included by another, is a new build.
+func TestCacheKeyIncludes(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := &Cache{}
+	gxx := Compiler{Path: "g++"}
+	src := filepath.Join(dir, "main.cpp")
+	source := []byte("#include \"lib/a.h\"\n#include \"testlib.h\"\nint main() { return f(); }\n")
+	write("lib/a.h", "#include \"b.h\"\n")
+	write("lib/b.h", "int f() { return 0; }\n")
+
+	key := c.key(gxx, src, source)
+	if again := c.key(gxx, src, source); again != key {
+		t.Errorf("same build got keys %s and %s", key, again)
+	}
+	write("lib/b.h", "int f() { return 1; }\n")
+	if c.key(gxx, src, source) == key {
+		t.Error("editing a header included by a header kept the key")
+	}
+}
+
+// writeEntry puts a fake build of size bytes into the cache, last used age
+// ago.
+func writeEntry(t *testing.T, dir, name string, size int, age time.Duration) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, make([]byte, size), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	when := time.Now().Add(-age)
+	if err := os.Chtimes(path, when, when); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCachePrune(t *testing.T) {
+	dir := t.TempDir()
+	c := &Cache{Dir: dir, MaxBytes: 250}
+	oldest := writeEntry(t, dir, "oldest", 100, 3*time.Hour)
+	old := writeEntry(t, dir, "old", 100, 2*time.Hour)
+	recent := writeEntry(t, dir, "recent", 100, time.Hour)
+
+	c.prune("")
+	for path, want := range map[string]bool{oldest: false, old: true, recent: true} {
+		if _, err := os.Stat(path); (err == nil) != want {
+			t.Errorf("%s kept = %v, want %v", filepath.Base(path), err == nil, want)
+		}
+	}
+
+	// A concurrent build can leave the one just made the oldest; it stays
+	justBuilt := writeEntry(t, dir, "just-built", 100, 4*time.Hour)
+	c.prune(justBuilt)
+	if _, err := os.Stat(justBuilt); err != nil {
+		t.Error("pruning removed the build it was told to keep")
+	}
+	if _, err := os.Stat(old); err == nil {
+		t.Error("pruning kept the least recently used build")
+	}
+}
+
+func TestCacheRemovesStaleBuildDirs(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "tmp-stale")
+	fresh := filepath.Join(dir, "tmp-fresh")
+	for _, d := range []string{stale, fresh} {
+		if err := os.Mkdir(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	when := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(stale, when, when)
+	writeEntry(t, dir, "build", 10, 0)
+
+	count, size, err := (&Cache{Dir: dir}).Stats()
+	if err != nil || count != 1 || size != 10 {
+		t.Errorf("Stats() = %d, %d, %v; want the one build of 10 bytes", count, size, err)
+	}
+	if _, err := os.Stat(stale); err == nil {
+		t.Error("a build dir left behind for hours is still there")
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Error("removed the build dir of a build that may still be running")
+	}
+}
+
+func TestCacheBuild(t *testing.T) {
+	requireGxx(t)
+	dir := t.TempDir()
+	src := filepath.Join(dir, "main.cpp")
+	if err := os.WriteFile(src, []byte("int main() { return 0; }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Too small for even one build, which must survive anyway
+	c := &Cache{Dir: filepath.Join(dir, "cache"), MaxBytes: 1}
+	ctx := context.Background()
+
+	binary, cached, err := c.Build(ctx, DefaultCompiler, src)
+	if err != nil || cached {
+		t.Fatalf("first build: cached=%v, %v", cached, err)
+	}
+	if _, err := os.Stat(binary); err != nil {
+		t.Fatal("the build was pruned before it could run")
+	}
+	again, cached, err := c.Build(ctx, DefaultCompiler, src)
+	if err != nil || !cached || again != binary {
+		t.Errorf("second build: %s cached=%v, %v; want %s from the cache", again, cached, err, binary)
+	}
+
+	entries, _ := os.ReadDir(c.Dir)
+	if len(entries) != 1 {
+		t.Errorf("cache holds %d entries, want only the build and no build dirs", len(entries))
+	}
+}
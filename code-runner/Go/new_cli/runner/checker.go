@@ -0,0 +1,119 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Verdict string
+
+const (
+	Accepted            Verdict = "OK"
+	WrongAnswer         Verdict = "WA"
+	PresentationError   Verdict = "PE"
+	TimeLimitExceeded   Verdict = "TLE"
+	MemoryLimitExceeded Verdict = "MLE"
+	OutputLimitExceeded Verdict = "OLE"
+	RuntimeError        Verdict = "RE"
+	CheckerFailed       Verdict = "FAIL"
+)
+
+// Checker decides whether a contestant's output is an acceptable answer.
+type Checker interface {
+	Check(ctx context.Context, input, output, answer string) (Verdict, string, error)
+}
+
+// ExactChecker compares outputs after trimming surrounding whitespace, the
+// same rule run.js has always used.
+type ExactChecker struct{}
+
+func (ExactChecker) Check(ctx context.Context, input, output, answer string) (Verdict, string, error) {
+	if strings.TrimSpace(output) == strings.TrimSpace(answer) {
+		return Accepted, "", nil
+	}
+	return WrongAnswer, "output differs from expected answer", nil
+}
+
+// Exit codes of the testlib checker protocol.
+const (
+	exitOK     = 0
+	exitWA     = 1
+	exitPE     = 2
+	exitFail   = 3
+	exitPoints = 7
+)
+
+// ProgramChecker runs an instructor-supplied checker following the testlib
+// convention: `checker <input> <output> <answer>`, verdict in the exit code
+// and a human readable message on stderr.
+type ProgramChecker struct {
+	Path   string
+	Limits Limits
+}
+
+func (c ProgramChecker) Check(ctx context.Context, input, output, answer string) (Verdict, string, error) {
+	dir, err := os.MkdirTemp("", "biskut-check-")
+	if err != nil {
+		return CheckerFailed, "", fmt.Errorf("error creating checker dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := []struct{ name, content string }{
+		{"input.txt", input},
+		{"output.txt", output},
+		{"answer.txt", answer},
+	}
+	args := make([]string, 0, len(files))
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		if err := os.WriteFile(path, []byte(f.content), 0o644); err != nil {
+			return CheckerFailed, "", fmt.Errorf("error writing %s: %v", f.name, err)
+		}
+		args = append(args, path)
+	}
+
+	limits := c.Limits
+	if limits.Time == 0 {
+		limits.Time = 10 * time.Second
+	}
+
+	res, err := Run(ctx, Spec{Path: c.Path, Args: args, Dir: dir, Limits: limits})
+	if err != nil {
+		return CheckerFailed, "", err
+	}
+
+	verdict, message := testlibVerdict("checker", res)
+	return verdict, message, nil
+}
+
+// testlibVerdict interprets the exit status of a checker or interactor.
+func testlibVerdict(program string, res *Result) (Verdict, string) {
+	message := strings.TrimSpace(string(res.Stderr))
+	if res.TimedOut {
+		return CheckerFailed, program + " timed out"
+	}
+	if res.Signal != 0 {
+		return CheckerFailed, fmt.Sprintf("%s killed by %v", program, res.Signal)
+	}
+
+	switch res.ExitCode {
+	case exitOK:
+		return Accepted, message
+	case exitWA:
+		return WrongAnswer, message
+	case exitPE:
+		return PresentationError, message
+	case exitPoints:
+		// Partial scoring isn't supported by submissions, so anything short
+		// of full marks counts as a wrong answer.
+		return WrongAnswer, message
+	case exitFail:
+		return CheckerFailed, message
+	default:
+		return CheckerFailed, fmt.Sprintf("%s exited with code %d: %s", program, res.ExitCode, message)
+	}
+}
@@ -0,0 +1,103 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestTestlibVerdict(t *testing.T) {
+	tests := []struct {
+		name    string
+		res     Result
+		verdict Verdict
+		message string
+	}{
+		{"ok", Result{ExitCode: 0, Stderr: []byte("ok 3 numbers\n")}, Accepted, "ok 3 numbers"},
+		{"wrong answer", Result{ExitCode: 1, Stderr: []byte("wrong answer expected 3, found 4")}, WrongAnswer, "wrong answer expected 3, found 4"},
+		{"presentation", Result{ExitCode: 2, Stderr: []byte("wrong output format Expected integer")}, PresentationError, "wrong output format Expected integer"},
+		{"fail", Result{ExitCode: 3, Stderr: []byte("FAIL answer is broken")}, CheckerFailed, "FAIL answer is broken"},
+		{"points", Result{ExitCode: 7, Stderr: []byte("points 0.5")}, WrongAnswer, "points 0.5"},
+		{"unknown code", Result{ExitCode: 5, Stderr: []byte("huh")}, CheckerFailed, "checker exited with code 5: huh"},
+		{"timed out", Result{TimedOut: true, ExitCode: -1}, CheckerFailed, "checker timed out"},
+		{"killed", Result{Signal: syscall.SIGSEGV, ExitCode: -1}, CheckerFailed, "checker killed by segmentation fault"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict, message := testlibVerdict("checker", &tt.res)
+			if verdict != tt.verdict || message != tt.message {
+				t.Errorf("got %s %q, want %s %q", verdict, message, tt.verdict, tt.message)
+			}
+		})
+	}
+}
+
+// requireGxx skips tests that build C++ when there is no compiler.
+func requireGxx(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("g++"); err != nil {
+		t.Skip("g++ not installed")
+	}
+}
+
+// build compiles source, which may include testlib.h, and returns the
+// executable.
+func build(t *testing.T, name, source string) string {
+	t.Helper()
+	dir := t.TempDir()
+	src := filepath.Join(dir, name+".cpp")
+	if err := os.WriteFile(src, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	binary := filepath.Join(dir, name)
+	if err := DefaultCompiler.Compile(context.Background(), src, binary); err != nil {
+		t.Fatal(err)
+	}
+	return binary
+}
+
+const sumChecker = `#include "testlib.h"
+int main(int argc, char *argv[]) {
+    registerTestlibCmd(argc, argv);
+    int a = inf.readInt(), b = inf.readInt();
+    long long got = ouf.readLong();
+    if (got != a + b)
+        quitf(_wa, "expected %d, found %lld", a + b, got);
+    quitf(_ok, "%lld", got);
+}
+`
+
+func TestProgramCheckerWithTestlib(t *testing.T) {
+	requireGxx(t)
+	checker := ProgramChecker{Path: build(t, "checker", sumChecker)}
+
+	tests := []struct {
+		output  string
+		verdict Verdict
+		message string
+	}{
+		{"3\n", Accepted, "ok 3"},
+		{"4\n", WrongAnswer, "wrong answer expected 3, found 4"},
+		{"03\n", PresentationError, `wrong output format Expected integer, but "03" found`},
+		{"3 3\n", PresentationError, "wrong output format Extra information in the output file"},
+		{"", PresentationError, "wrong output format Unexpected end of file - token expected"},
+	}
+	for _, tt := range tests {
+		verdict, message, err := checker.Check(context.Background(), "1 2\n", tt.output, "3\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if verdict != tt.verdict || message != tt.message {
+			t.Errorf("output %q: got %s %q, want %s %q", tt.output, verdict, message, tt.verdict, tt.message)
+		}
+	}
+
+	// A broken test is the checker's problem, not the contestant's
+	verdict, message, _ := checker.Check(context.Background(), "x\n", "3\n", "3\n")
+	if verdict != CheckerFailed {
+		t.Errorf("bad input: got %s %q, want %s", verdict, message, CheckerFailed)
+	}
+}
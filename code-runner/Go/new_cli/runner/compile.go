@@ -0,0 +1,62 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Compiler is the C++ toolchain used for solutions, checkers and interactors.
+type Compiler struct {
+	Path  string
+	Flags []string
+}
+
+var DefaultCompiler = Compiler{Path: "g++"}
+
+// DebugFlags build a program to step through in a debugger.
+var DebugFlags = []string{"-g", "-O0"}
+
+// WithDebugInfo returns the compiler building with DebugFlags.
+func (c Compiler) WithDebugInfo() Compiler {
+	c.Flags = append(append([]string{}, c.Flags...), DebugFlags...)
+	return c
+}
+
+// CompileError carries the compiler output of a failed build, and the
+// diagnostics found in it.
+type CompileError struct {
+	Output      string
+	Diagnostics []Diagnostic
+}
+
+func (e *CompileError) Error() string {
+	return "compilation error:\n" + e.Output
+}
+
+// Compile builds src into the executable out. The bundled testlib.h is on
+// the include path.
+func (c Compiler) Compile(ctx context.Context, src, out string) error {
+	if !strings.HasSuffix(src, ".cpp") {
+		return fmt.Errorf("input file must have a .cpp extension")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	include, err := TestlibDir()
+	if err != nil {
+		return err
+	}
+	args := append(append([]string{"-I", include}, c.Flags...), src, "-o", out)
+	output, err := exec.CommandContext(ctx, c.Path, args...).CombinedOutput()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return &CompileError{Output: string(output), Diagnostics: ParseDiagnostics(string(output))}
+		}
+		return fmt.Errorf("error running %s: %v", c.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// signals explains the ways a student program usually dies.
+var signals = map[syscall.Signal]struct{ name, explanation string }{
+	syscall.SIGSEGV: {"SIGSEGV", "Segmentation fault: the program used memory it doesn't own, e.g. an array index out of bounds, a null or dangling pointer, or recursion too deep for the stack"},
+	syscall.SIGFPE:  {"SIGFPE", "Arithmetic error: an integer division or modulo by zero, or dividing the smallest int by -1"},
+	syscall.SIGABRT: {"SIGABRT", "Aborted: a failed assert, or an exception nothing caught, such as std::out_of_range from .at() or std::bad_alloc"},
+	syscall.SIGBUS:  {"SIGBUS", "Bus error: the program used an invalid address, much like a segmentation fault"},
+	syscall.SIGILL:  {"SIGILL", "Illegal instruction: often a function that should return a value ending without a return"},
+	syscall.SIGKILL: {"SIGKILL", "Killed: the program was stopped from outside, usually for using too much time or memory"},
+}
+
+// DescribeSignal explains in plain words why a program killed by sig died.
+func DescribeSignal(sig syscall.Signal) string {
+	if s, ok := signals[sig]; ok {
+		return fmt.Sprintf("%s (%s)", s.explanation, s.name)
+	}
+	return fmt.Sprintf("Killed by signal %d (%v)", int(sig), sig)
+}
+
+// SanitizerFlags build a program that reports memory errors and undefined
+// behaviour where they happen, with a backtrace.
+var SanitizerFlags = []string{"-g", "-O0", "-fno-omit-frame-pointer", "-fsanitize=address,undefined"}
+
+// WithSanitizers returns the compiler building with SanitizerFlags.
+func (c Compiler) WithSanitizers() Compiler {
+	c.Flags = append(append([]string{}, c.Flags...), SanitizerFlags...)
+	return c
+}
+
+// SanitizerEnv is the environment to run a sanitizer build in: undefined
+// behaviour stops the program with a backtrace, and leaks, which aren't
+// crashes, aren't reported. Sanitizer builds reserve terabytes of address
+// space for shadow memory, which no address space limit allows, so they
+// must run without one; memory, unless 0, caps their resident memory
+// instead, through the sanitizer itself.
+func SanitizerEnv(memory int64) []string {
+	asan := "detect_leaks=0"
+	if memory > 0 {
+		asan += fmt.Sprintf(":hard_rss_limit_mb=%d:allocator_may_return_null=1", sanitizerRSSLimit(memory)>>20)
+	}
+	return append(MinimalEnv(),
+		"ASAN_OPTIONS="+asan,
+		"UBSAN_OPTIONS=print_stacktrace=1:halt_on_error=1",
+	)
+}
+
+// sanitizerRSSLimit is the resident memory a sanitizer build of a program
+// limited to memory bytes may use: twice the limit, for shadow memory and
+// the redzones around allocations, and the 256MB of freed memory ASan
+// quarantines to catch use after free.
+func sanitizerRSSLimit(memory int64) int64 {
+	return 2*memory + 256<<20
+}
+
+// Diagnose runs a sanitizer build of a program on a test case it failed and
+// returns what the sanitizer reported, or nil if it found nothing.
+func Diagnose(ctx context.Context, binary, dir string, tc TestCase, limits Limits) *Crash {
+	if tc.TimeLimit > 0 {
+		limits.Time = time.Duration(tc.TimeLimit) * time.Millisecond
+	}
+	// Sanitizers make programs a few times slower
+	limits.Time *= 3
+	env := SanitizerEnv(limits.Memory)
+	limits.Memory = 0
+	res, err := Run(ctx, Spec{
+		Path:   binary,
+		Dir:    dir,
+		Env:    env,
+		Stdin:  strings.NewReader(tc.Input),
+		Limits: limits,
+	})
+	if err != nil {
+		return nil
+	}
+	crash, _ := ParseSanitizerReport(string(res.Stderr))
+	if crash != nil && crash.Error == outOfMemory {
+		// Not where it crashed, and the verdict says so already
+		return nil
+	}
+	return crash
+}
+
+// Frame is one call of a backtrace.
+type Frame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Source   string `json:"source,omitempty"` // the text of Line
+}
+
+// Crash is what a sanitizer reported about a failed run.
+type Crash struct {
+	Error  string  `json:"error"`  // e.g. "heap-buffer-overflow" or "runtime error: division by zero"
+	Frames []Frame `json:"frames"` // innermost first, only those with a source location
+}
+
+// crashExplanations says what the AddressSanitizer errors students meet
+// most mean.
+var crashExplanations = map[string]string{
+	"heap-buffer-overflow":     "read or wrote past the end of an array allocated with new or a vector",
+	"stack-buffer-overflow":    "read or wrote past the end of a local array",
+	"global-buffer-overflow":   "read or wrote past the end of a global array",
+	"heap-use-after-free":      "used memory after it was deleted, e.g. through a pointer into a vector that has grown",
+	"stack-use-after-return":   "used a local variable of a function that has returned, through a pointer or reference to it",
+	"stack-use-after-scope":    "used a local variable after the block it was declared in ended",
+	"attempting":               "deleted memory that wasn't allocated with new, or deleted it twice",
+	"SEGV":                     "used an invalid address, e.g. a null or uninitialized pointer",
+	"stack-overflow":           "recursion too deep for the stack, often recursion that never stops",
+	"alloc-dealloc-mismatch":   "freed memory with the wrong function, e.g. delete on memory from new[]",
+	"new-delete-type-mismatch": "deleted an object through a pointer of the wrong type",
+	outOfMemory:                "used more memory than the limit allows",
+}
+
+// outOfMemory is the error of a sanitizer build that ran out of memory,
+// whichever way ASan words it.
+const outOfMemory = "out-of-memory"
+
+// Explanation says in plain words what went wrong, or "" for errors that
+// speak for themselves.
+func (c *Crash) Explanation() string {
+	return crashExplanations[c.Error]
+}
+
+var (
+	asanError  = regexp.MustCompile(`ERROR: AddressSanitizer: (\S+)`)
+	ubsanError = regexp.MustCompile(`^\S+:\d+:\d+: (runtime error: .*)$`)
+	stackFrame = regexp.MustCompile(`^\s*#\d+ 0x[0-9a-f]+ in (.+) (\S+?):(\d+)(?::\d+)?$`)
+)
+
+// ParseSanitizerReport finds the report of a sanitizer build in its stderr.
+// It returns nil if there is none, and otherwise also what the program
+// itself wrote to stderr before the report.
+func ParseSanitizerReport(stderr string) (*Crash, string) {
+	lines := strings.Split(stderr, "\n")
+	for i, line := range lines {
+		var crash *Crash
+		if strings.Contains(line, "AddressSanitizer: hard rss limit exhausted") ||
+			strings.Contains(line, "AddressSanitizer: out of memory") {
+			crash = &Crash{Error: outOfMemory}
+		} else if m := asanError.FindStringSubmatch(line); m != nil {
+			crash = &Crash{Error: m[1]}
+		} else if m := ubsanError.FindStringSubmatch(line); m != nil {
+			crash = &Crash{Error: m[1]}
+		} else {
+			continue
+		}
+
+		start := i
+		if i > 0 && lines[i-1] != "" && strings.Trim(lines[i-1], "=") == "" {
+			// ASan reports start with a line of "="s
+			start = i - 1
+		}
+		before := strings.Join(lines[:start], "\n")
+
+		// Only the first backtrace is where it crashed; ASan follows it
+		// with where the memory was allocated or freed
+		inStack := false
+		for _, l := range lines[i+1:] {
+			m := stackFrame.FindStringSubmatch(l)
+			isFrame := strings.HasPrefix(strings.TrimSpace(l), "#")
+			if !isFrame && inStack {
+				break
+			}
+			inStack = inStack || isFrame
+			if m == nil || strings.Contains(m[2], "libsanitizer") {
+				continue
+			}
+			n, _ := strconv.Atoi(m[3])
+			crash.Frames = append(crash.Frames, Frame{Function: m[1], File: m[2], Line: n})
+		}
+		fillFrameSource(crash.Frames)
+		return crash, before
+	}
+	return nil, stderr
+}
+
+// fillFrameSource adds the source line of each frame whose file can be read.
+func fillFrameSource(frames []Frame) {
+	diags := make([]Diagnostic, len(frames))
+	for i, f := range frames {
+		diags[i] = Diagnostic{File: f.File, Line: f.Line}
+	}
+	fillSource(diags)
+	for i := range frames {
+		frames[i].Source = diags[i].Source
+	}
+}
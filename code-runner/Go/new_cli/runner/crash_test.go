@@ -0,0 +1,83 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// buildSanitized compiles source with sanitizers and returns the executable.
+func buildSanitized(t *testing.T, name, source string) string {
+	t.Helper()
+	requireGxx(t)
+	dir := t.TempDir()
+	src := filepath.Join(dir, name+".cpp")
+	if err := os.WriteFile(src, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	binary := filepath.Join(dir, name)
+	if err := DefaultCompiler.WithSanitizers().Compile(context.Background(), src, binary); err != nil {
+		t.Fatal(err)
+	}
+	return binary
+}
+
+func TestDiagnose(t *testing.T) {
+	binary := buildSanitized(t, "overflow", `#include <iostream>
+#include <vector>
+int main() {
+    std::vector<int> v(3);
+    int i;
+    std::cin >> i;
+    return v.data()[i];
+}
+`)
+	crash := Diagnose(context.Background(), binary, t.TempDir(), TestCase{Input: "5"}, DefaultLimits)
+	if crash == nil {
+		t.Fatal("Diagnose found nothing")
+	}
+	if crash.Error != "heap-buffer-overflow" || len(crash.Frames) == 0 || crash.Frames[0].Function != "main" {
+		t.Errorf("Diagnose = %+v, want a heap-buffer-overflow in main", crash)
+	}
+}
+
+// A sanitizer build can't run under an address space limit, but must not
+// get unlimited memory either.
+func TestSanitizerMemoryCap(t *testing.T) {
+	// Touches up to 2GB slowly enough for ASan's RSS check to keep up,
+	// then overflows
+	binary := buildSanitized(t, "hog", `#include <cstring>
+#include <unistd.h>
+int main() {
+    char *last = nullptr;
+    for (int i = 0; i < 2048; i++) {
+        last = new char[1 << 20];
+        memset(last, 1, 1 << 20);
+        usleep(1000);
+    }
+    return last[1 << 20];
+}
+`)
+	limits := DefaultLimits
+	limits.Memory = 32 << 20
+	limits.Time = 20 * time.Second
+
+	res, err := Run(context.Background(), Spec{Path: binary, Dir: t.TempDir(), Env: SanitizerEnv(limits.Memory), Limits: Limits{Time: limits.Time, Output: limits.Output}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(res.Stderr), "rss limit exhausted") {
+		t.Fatalf("program wasn't stopped by the memory cap; stderr:\n%s", res.Stderr)
+	}
+	if crash, _ := ParseSanitizerReport(string(res.Stderr)); crash == nil || crash.Error != outOfMemory {
+		t.Errorf("ParseSanitizerReport = %+v, want %s", crash, outOfMemory)
+	}
+
+	// Running out of memory is no place to point at
+	if crash := Diagnose(context.Background(), binary, t.TempDir(), TestCase{}, limits); crash != nil {
+		t.Errorf("Diagnose = %+v, want nil once the memory cap is hit", crash)
+	}
+}
@@ -0,0 +1,62 @@
+package runner
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Diagnostic is one message of the compiler about a place in the source.
+type Diagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column,omitempty"` // display column, tabs every 8, as g++ counts
+	Severity string `json:"severity"`         // error, warning or note
+	Message  string `json:"message"`
+	Source   string `json:"source,omitempty"` // the text of Line
+}
+
+// diagnosticLine matches the first line of a GCC or Clang diagnostic,
+// "file:line:column: severity: message", the column being optional.
+var diagnosticLine = regexp.MustCompile(`^(.+?):(\d+):(?:(\d+):)? (fatal error|error|warning|note): (.*)$`)
+
+// ParseDiagnostics picks the diagnostics out of compiler output. Everything
+// else, such as the compiler's own excerpts of the source and linker
+// errors, is left out.
+func ParseDiagnostics(output string) []Diagnostic {
+	var diags []Diagnostic
+	for _, line := range strings.Split(output, "\n") {
+		m := diagnosticLine.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+		d := Diagnostic{File: m[1], Severity: m[4], Message: m[5]}
+		d.Line, _ = strconv.Atoi(m[2])
+		d.Column, _ = strconv.Atoi(m[3])
+		if d.Severity == "fatal error" {
+			d.Severity = "error"
+		}
+		diags = append(diags, d)
+	}
+	fillSource(diags)
+	return diags
+}
+
+// fillSource adds the source line of each diagnostic whose file can be
+// read.
+func fillSource(diags []Diagnostic) {
+	files := map[string][]string{}
+	for i, d := range diags {
+		lines, ok := files[d.File]
+		if !ok {
+			if data, err := os.ReadFile(d.File); err == nil {
+				lines = strings.Split(string(data), "\n")
+			}
+			files[d.File] = lines
+		}
+		if d.Line >= 1 && d.Line <= len(lines) {
+			diags[i].Source = strings.TrimRight(lines[d.Line-1], "\r")
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const maxTranscript = 64 << 10
+
+// transcript records the conversation between solution and interactor in
+// the order the bytes were written.
+type transcript struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (t *transcript) record(prefix string, p []byte) {
+	var chunk strings.Builder
+	for _, line := range strings.SplitAfter(string(p), "\n") {
+		if line == "" {
+			continue
+		}
+		chunk.WriteString(prefix)
+		chunk.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			chunk.WriteByte('\n')
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// Truncate the last chunk so the transcript never exceeds maxTranscript.
+	text := chunk.String()
+	if room := maxTranscript - t.buf.Len(); len(text) > room {
+		text = text[:max(room, 0)]
+	}
+	t.buf.WriteString(text)
+}
+
+func (t *transcript) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.buf.String()
+}
+
+// tee forwards one direction of the conversation. Once the peer has gone
+// away writes are dropped rather than failing, so the writing program is
+// judged on its own exit status instead of a broken pipe.
+type tee struct {
+	dst    *os.File
+	prefix string
+	log    *transcript
+	out    *bytes.Buffer
+	broken bool
+}
+
+func (t *tee) Write(p []byte) (int, error) {
+	t.log.record(t.prefix, p)
+	if t.out != nil {
+		t.out.Write(p)
+	}
+	if !t.broken {
+		if _, err := t.dst.Write(p); err != nil {
+			t.broken = true
+		}
+	}
+	return len(p), nil
+}
+
+// Interact runs binary against an interactor program speaking the testlib
+// protocol: `interactor <input> <output> <answer>`, with the interactor's
+// stdout wired to the solution's stdin and vice versa. The interactor's exit
+// code decides the verdict unless the solution itself misbehaved.
+func Interact(ctx context.Context, binary, interactor, dir string, tc TestCase, limits Limits) TestResult {
+	result := TestResult{Input: tc.Input, Expected: tc.Output}
+	fail := func(err error) TestResult {
+		result.Verdict = CheckerFailed
+		result.Reason = err.Error()
+		return result
+	}
+
+	tmp, err := os.MkdirTemp("", "biskut-interact-")
+	if err != nil {
+		return fail(fmt.Errorf("error creating interactor dir: %v", err))
+	}
+	defer os.RemoveAll(tmp)
+
+	inputPath := filepath.Join(tmp, "input.txt")
+	outputPath := filepath.Join(tmp, "output.txt")
+	answerPath := filepath.Join(tmp, "answer.txt")
+	if err := os.WriteFile(inputPath, []byte(tc.Input), 0o644); err != nil {
+		return fail(err)
+	}
+	if err := os.WriteFile(answerPath, []byte(tc.Output), 0o644); err != nil {
+		return fail(err)
+	}
+
+	// toInteractor carries the solution's stdout, toSolution the interactor's.
+	fromSolution, toInteractor, err := os.Pipe()
+	if err != nil {
+		return fail(err)
+	}
+	fromInteractor, toSolution, err := os.Pipe()
+	if err != nil {
+		fromSolution.Close()
+		toInteractor.Close()
+		return fail(err)
+	}
+
+	log := &transcript{}
+	var solutionOut bytes.Buffer
+
+	var wg sync.WaitGroup
+	var solRes, intRes *Result
+	var solErr, intErr error
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		solRes, solErr = Run(ctx, Spec{
+			Path:   binary,
+			Dir:    dir,
+			Stdin:  fromInteractor,
+			Stdout: &tee{dst: toInteractor, prefix: "< ", log: log, out: &solutionOut},
+			Limits: limits,
+		})
+		// Let the interactor see EOF once the solution is gone.
+		toInteractor.Close()
+		fromInteractor.Close()
+	}()
+	go func() {
+		defer wg.Done()
+		intLimits := limits
+		intLimits.Time = 2*limits.Time + DefaultLimits.Time
+		intLimits.Output = 0
+		intRes, intErr = Run(ctx, Spec{
+			Path:   interactor,
+			Args:   []string{inputPath, outputPath, answerPath},
+			Dir:    tmp,
+			Stdin:  fromSolution,
+			Stdout: &tee{dst: toSolution, prefix: "> ", log: log},
+			Limits: intLimits,
+		})
+		toSolution.Close()
+		fromSolution.Close()
+	}()
+	wg.Wait()
+
+	result.Output = solutionOut.String()
+	result.Transcript = log.String()
+	if solErr != nil {
+		result.Verdict = RuntimeError
+		result.Reason = solErr.Error()
+		return result
+	}
+	if intErr != nil {
+		return fail(intErr)
+	}
+	result.Time = solRes.Time.Milliseconds()
+
+	verdict, message := testlibVerdict("interactor", intRes)
+	if verdict == CheckerFailed {
+		result.Verdict, result.Reason = verdict, message
+		return result
+	}
+	// An interactor reading from a solution that crashed hits the end of its
+	// output and reports a presentation error. The solution's own failure is
+	// the real cause, so report that instead.
+	if solVerdict, reason := classify(solRes, limits); solVerdict == TimeLimitExceeded ||
+		(solVerdict != "" && (verdict == Accepted || verdict == PresentationError)) {
+		result.Verdict, result.Reason = solVerdict, reason
+		return result
+	}
+
+	result.Verdict, result.Reason = verdict, message
+	result.Passed = verdict == Accepted
+	return result
+}
@@ -0,0 +1,121 @@
+package runner
+
+import (
+	"context"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// guessInteractor answers "<", ">" or "=" to guesses of the number in the
+// input, allowing ten.
+const guessInteractor = `#include "testlib.h"
+int main(int argc, char *argv[]) {
+    registerInteraction(argc, argv);
+    int secret = inf.readInt();
+    for (int i = 1; i <= 10; i++) {
+        int guess = ouf.readInt(1, 100, "guess");
+        if (guess == secret) {
+            std::cout << "=" << std::endl;
+            quitf(_ok, "guessed in %d", i);
+        }
+        std::cout << (guess < secret ? "<" : ">") << std::endl;
+    }
+    quitf(_wa, "no guesses left");
+}
+`
+
+func TestInteract(t *testing.T) {
+	requireGxx(t)
+	interactor := build(t, "interactor", guessInteractor)
+	solutions := map[string]string{
+		"search": `#include <iostream>
+int main() {
+    int lo = 1, hi = 100;
+    for (std::string reply; ; ) {
+        int mid = (lo + hi) / 2;
+        std::cout << mid << std::endl;
+        std::cin >> reply;
+        if (reply == "=") return 0;
+        if (reply == "<") lo = mid + 1; else hi = mid - 1;
+    }
+}
+`,
+		"stubborn": `#include <iostream>
+int main() { std::string reply; while (std::cout << 1 << std::endl && std::cin >> reply) {} }
+`,
+		"silent":  "int main() { for (;;) {} }\n",
+		"chatty":  "#include <cstdio>\nint main() { for (int i = 0; i < 20000; i++) printf(\"%d\\n\", 100); }\n",
+		"crashes": "#include <iostream>\nint main() { std::cout << 50 << std::endl; int *p = nullptr; return *p; }\n",
+	}
+	binaries := map[string]string{}
+	for name, source := range solutions {
+		binaries[name] = build(t, name, source)
+	}
+
+	tests := []struct {
+		solution   string
+		verdict    Verdict
+		reason     string
+		transcript string // prefix
+	}{
+		{"search", Accepted, "ok guessed in 3", "< 50\n> <\n< 75\n> >\n< 62\n> =\n"},
+		{"stubborn", WrongAnswer, "wrong answer no guesses left", "< 1\n> <\n< 1\n> <\n"},
+		{"silent", TimeLimitExceeded, "Time Limit Exceeded", ""},
+		{"chatty", Accepted, "ok guessed in 1", "< 100\n< 100\n"},
+		{"crashes", RuntimeError, DescribeSignal(syscall.SIGSEGV), "< 50\n> <\n"},
+	}
+	limits := Limits{Time: 500 * time.Millisecond, Memory: DefaultLimits.Memory}
+	for _, tt := range tests {
+		t.Run(tt.solution, func(t *testing.T) {
+			tc := TestCase{Input: "62\n"}
+			if tt.solution == "chatty" {
+				tc.Input = "100\n"
+			}
+			result := Interact(context.Background(), binaries[tt.solution], interactor, t.TempDir(), tc, limits)
+			if result.Verdict != tt.verdict || result.Reason != tt.reason {
+				t.Errorf("got %s %q, want %s %q", result.Verdict, result.Reason, tt.verdict, tt.reason)
+			}
+			if !strings.HasPrefix(result.Transcript, tt.transcript) {
+				t.Errorf("transcript starts %q, want %q", result.Transcript[:min(len(result.Transcript), 60)], tt.transcript)
+			}
+			if len(result.Transcript) > maxTranscript {
+				t.Errorf("transcript is %d bytes, over the %d cap", len(result.Transcript), maxTranscript)
+			}
+		})
+	}
+}
+
+func TestInteractorTimeout(t *testing.T) {
+	requireGxx(t)
+	// Never answers; the solution waits for it and times out first, and
+	// the interactor is given its own, longer, limit
+	interactor := build(t, "interactor", "int main() { for (;;) {} }\n")
+	solution := build(t, "solution", "#include <iostream>\nint main() { int x; std::cin >> x; }\n")
+
+	limits := Limits{Time: 200 * time.Millisecond}
+	start := time.Now()
+	result := Interact(context.Background(), solution, interactor, t.TempDir(), TestCase{Input: "1\n"}, limits)
+	if result.Verdict != CheckerFailed || result.Reason != "interactor timed out" {
+		t.Errorf("got %s %q, want %s %q", result.Verdict, result.Reason, CheckerFailed, "interactor timed out")
+	}
+	if elapsed := time.Since(start); elapsed > 2*limits.Time+DefaultLimits.Time+time.Second {
+		t.Errorf("took %s", elapsed)
+	}
+}
+
+func TestTranscriptCap(t *testing.T) {
+	log := &transcript{}
+	line := []byte(strings.Repeat("x", 99) + "\n")
+	for i := 0; i < 1000; i++ {
+		log.record("< ", line)
+	}
+	got := log.String()
+	if len(got) != maxTranscript {
+		t.Errorf("transcript is %d bytes, want the %d cap", len(got), maxTranscript)
+	}
+	if !strings.HasPrefix(got, "< xxx") {
+		t.Errorf("transcript starts %q", got[:10])
+	}
+}
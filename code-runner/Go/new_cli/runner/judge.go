@@ -0,0 +1,165 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TestCase mirrors the objects stored in Question.inputsOutputs.
+type TestCase struct {
+	Input     string `json:"input"`
+	Output    string `json:"output"`
+	TimeLimit int    `json:"timeLimit,omitempty"` // milliseconds
+}
+
+// TestResult keeps the field names run.js reported so existing consumers of
+// resultDetails keep working.
+type TestResult struct {
+	Passed   bool    `json:"passed"`
+	Input    string  `json:"input"`
+	Output   string  `json:"output"`
+	Expected string  `json:"expected"`
+	Reason   string  `json:"reason,omitempty"`
+	Verdict  Verdict `json:"verdict"`
+	Time     int64   `json:"time"` // milliseconds
+
+	// Transcript is the interactor conversation, "> " lines are sent to the
+	// solution and "< " lines are its replies.
+	Transcript string `json:"transcript,omitempty"`
+
+	// Crash is where a sanitizer build crashed on this test case, if it
+	// was rerun with one.
+	Crash *Crash `json:"crash,omitempty"`
+}
+
+type Report struct {
+	Passed []TestResult `json:"passed"`
+	Failed []TestResult `json:"failed"`
+	Time   int64        `json:"time"`
+}
+
+// Progress is told about each test case as soon as it is judged. index
+// counts from 0.
+type Progress func(index int, result TestResult)
+
+// Judge runs binary against every test case and grades the output with
+// checker. Test cases are run one at a time so timings are comparable.
+// progress may be nil.
+func Judge(ctx context.Context, binary, dir string, cases []TestCase, checker Checker, limits Limits, progress Progress) *Report {
+	if checker == nil {
+		checker = ExactChecker{}
+	}
+	return judgeAll(cases, progress, func(tc TestCase) TestResult {
+		return JudgeCase(ctx, binary, dir, tc, checker, limits)
+	})
+}
+
+// JudgeInteractive grades binary by pairing it with interactor on every test
+// case instead of comparing its output.
+func JudgeInteractive(ctx context.Context, binary, interactor, dir string, cases []TestCase, limits Limits, progress Progress) *Report {
+	return judgeAll(cases, progress, func(tc TestCase) TestResult {
+		if tc.TimeLimit > 0 {
+			limits.Time = time.Duration(tc.TimeLimit) * time.Millisecond
+		}
+		return Interact(ctx, binary, interactor, dir, tc, limits)
+	})
+}
+
+func judgeAll(cases []TestCase, progress Progress, judge func(TestCase) TestResult) *Report {
+	start := time.Now()
+	report := &Report{Passed: []TestResult{}, Failed: []TestResult{}}
+	for i, tc := range cases {
+		result := judge(tc)
+		if progress != nil {
+			progress(i, result)
+		}
+		if result.Passed {
+			report.Passed = append(report.Passed, result)
+		} else {
+			report.Failed = append(report.Failed, result)
+		}
+	}
+	report.Time = time.Since(start).Milliseconds()
+	return report
+}
+
+// JudgeCase runs a single test case.
+func JudgeCase(ctx context.Context, binary, dir string, tc TestCase, checker Checker, limits Limits) TestResult {
+	if tc.TimeLimit > 0 {
+		limits.Time = time.Duration(tc.TimeLimit) * time.Millisecond
+	}
+
+	result := TestResult{Input: tc.Input, Expected: tc.Output}
+	res, err := Run(ctx, Spec{
+		Path:   binary,
+		Dir:    dir,
+		Stdin:  strings.NewReader(tc.Input),
+		Limits: limits,
+	})
+	if err != nil {
+		result.Verdict = RuntimeError
+		result.Reason = err.Error()
+		return result
+	}
+
+	result.Output = string(res.Stdout)
+	result.Time = res.Time.Milliseconds()
+	if verdict, reason := classify(res, limits); verdict != "" {
+		result.Verdict = verdict
+		result.Reason = reason
+		return result
+	}
+
+	verdict, message, err := checker.Check(ctx, tc.Input, result.Output, tc.Output)
+	if err != nil {
+		verdict, message = CheckerFailed, err.Error()
+	}
+	result.Verdict = verdict
+	result.Reason = message
+	result.Passed = verdict == Accepted
+	return result
+}
+
+// Output runs binary with args on input and returns what it printed, or an
+// error naming the verdict when it doesn't exit normally. Generators and
+// reference solutions are run this way.
+func Output(ctx context.Context, binary, dir string, args []string, input string, limits Limits) (string, error) {
+	res, err := Run(ctx, Spec{
+		Path:   binary,
+		Args:   args,
+		Dir:    dir,
+		Stdin:  strings.NewReader(input),
+		Limits: limits,
+	})
+	if err != nil {
+		return "", err
+	}
+	if _, reason := classify(res, limits); reason != "" {
+		if stderr := strings.TrimSpace(string(res.Stderr)); stderr != "" {
+			reason += ": " + stderr
+		}
+		return string(res.Stdout), fmt.Errorf("%s", reason)
+	}
+	return string(res.Stdout), nil
+}
+
+// classify maps abnormal terminations to a verdict, or returns "" when the
+// program exited normally and its output should be checked.
+func classify(res *Result, limits Limits) (Verdict, string) {
+	switch {
+	case res.TimedOut:
+		return TimeLimitExceeded, "Time Limit Exceeded"
+	case res.OutputExceeded:
+		return OutputLimitExceeded, "Output Limit Exceeded"
+	case limits.Memory > 0 && (res.Signal != 0 || res.ExitCode != 0) &&
+		(res.MemoryKB<<10 >= limits.Memory*9/10 || strings.Contains(string(res.Stderr), "bad_alloc")):
+		return MemoryLimitExceeded, "Memory Limit Exceeded"
+	case res.Signal != 0:
+		return RuntimeError, DescribeSignal(res.Signal)
+	case res.ExitCode != 0:
+		return RuntimeError, fmt.Sprintf("Process exited with code %d", res.ExitCode)
+	}
+	return "", ""
+}
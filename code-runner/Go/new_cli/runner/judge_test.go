@@ -0,0 +1,34 @@
+package runner
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	limits := Limits{Memory: 256 << 20}
+	tests := []struct {
+		name    string
+		res     Result
+		limits  Limits
+		verdict Verdict
+		reason  string
+	}{
+		{"exited normally", Result{}, limits, "", ""},
+		{"timed out", Result{TimedOut: true, Signal: syscall.SIGKILL}, limits, TimeLimitExceeded, "Time Limit Exceeded"},
+		{"too much output", Result{OutputExceeded: true}, limits, OutputLimitExceeded, "Output Limit Exceeded"},
+		{"killed near the memory limit", Result{Signal: syscall.SIGKILL, MemoryKB: 250 << 10}, limits, MemoryLimitExceeded, "Memory Limit Exceeded"},
+		{"bad_alloc", Result{ExitCode: 134, MemoryKB: 10 << 10, Stderr: []byte("terminate called after throwing an instance of 'std::bad_alloc'")}, limits, MemoryLimitExceeded, "Memory Limit Exceeded"},
+		{"crash far from the limit", Result{Signal: syscall.SIGSEGV, MemoryKB: 10 << 10}, limits, RuntimeError, DescribeSignal(syscall.SIGSEGV)},
+		{"no memory limit", Result{Signal: syscall.SIGKILL, MemoryKB: 250 << 10}, Limits{}, RuntimeError, DescribeSignal(syscall.SIGKILL)},
+		{"exit code", Result{ExitCode: 3}, limits, RuntimeError, "Process exited with code 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict, reason := classify(&tt.res, tt.limits)
+			if verdict != tt.verdict || reason != tt.reason {
+				t.Errorf("got %q %q, want %q %q", verdict, reason, tt.verdict, tt.reason)
+			}
+		})
+	}
+}
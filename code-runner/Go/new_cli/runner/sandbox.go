@@ -0,0 +1,187 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Limits bounds a single execution of an untrusted program.
+type Limits struct {
+	Time   time.Duration // wall clock time
+	Memory int64         // address space in bytes, 0 means unlimited
+	Output int64         // captured stdout in bytes, 0 means unlimited
+}
+
+var DefaultLimits = Limits{
+	Time:   2 * time.Second,
+	Memory: 256 << 20,
+	Output: 16 << 20,
+}
+
+// Spec describes a program to run inside the sandbox.
+type Spec struct {
+	Path   string
+	Args   []string
+	Dir    string
+	Env    []string // if nil, MinimalEnv()
+	Stdin  io.Reader
+	Stdout io.Writer // if nil, stdout is captured into Result.Stdout
+	Limits Limits
+}
+
+type Result struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+	Signal   syscall.Signal
+	Time     time.Duration
+	MemoryKB int64
+
+	TimedOut       bool
+	OutputExceeded bool
+}
+
+// Run executes spec.Path with the given limits. The program runs in its own
+// process group so that anything it forks is killed together with it.
+// A non-nil error means the program could not be started at all; crashes and
+// timeouts are reported through the Result.
+func Run(ctx context.Context, spec Spec) (*Result, error) {
+	limits := spec.Limits
+	if limits.Time == 0 {
+		limits.Time = DefaultLimits.Time
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, limits.Time)
+	defer cancel()
+
+	path := spec.Path
+	if !strings.Contains(path, "/") {
+		path = "./" + path
+	}
+
+	cmd := exec.Command("/bin/sh", append([]string{"-c", ulimitScript(limits), path}, spec.Args...)...)
+	cmd.Dir = spec.Dir
+	cmd.Env = spec.Env
+	if cmd.Env == nil {
+		cmd.Env = MinimalEnv()
+	}
+	cmd.Stdin = spec.Stdin
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.WaitDelay = time.Second
+
+	var stdout, stderr bytes.Buffer
+	out := &limitedWriter{w: &stdout, n: limits.Output}
+	if spec.Stdout != nil {
+		out.w = spec.Stdout
+	}
+	cmd.Stdout = out
+	cmd.Stderr = &limitedWriter{w: &stderr, n: 64 << 10}
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error starting %s: %v", spec.Path, err)
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		waitExit(cmd.Process.Pid)
+		close(exited)
+	}()
+
+	result := &Result{}
+	select {
+	case <-exited:
+	case <-ctx.Done():
+		result.TimedOut = errors.Is(ctx.Err(), context.DeadlineExceeded)
+	}
+	// The program isn't reaped yet, so the group is still its own: kill
+	// whatever it forked, and the program itself if it timed out
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	err := cmd.Wait()
+	result.Time = time.Since(start)
+	result.Stdout = stdout.Bytes()
+	result.Stderr = stderr.Bytes()
+	result.OutputExceeded = out.exceeded
+
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("error waiting for %s: %v", spec.Path, err)
+		}
+	}
+
+	state := cmd.ProcessState
+	result.ExitCode = state.ExitCode()
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		result.Signal = ws.Signal()
+	}
+	if ru, ok := state.SysUsage().(*syscall.Rusage); ok {
+		result.MemoryKB = ru.Maxrss
+	}
+
+	return result, nil
+}
+
+// MinimalEnv is the environment programs run in by default: only what they
+// need of ours, so that nothing like the Redis address or database
+// credentials reaches a student's program.
+func MinimalEnv() []string {
+	env := []string{"PATH=/usr/local/bin:/usr/bin:/bin"}
+	if path, ok := os.LookupEnv("PATH"); ok {
+		env[0] = "PATH=" + path
+	}
+	if lang, ok := os.LookupEnv("LANG"); ok {
+		env = append(env, "LANG="+lang)
+	}
+	return env
+}
+
+// ulimitScript builds the shell prologue that applies the limits and then
+// replaces itself with the target program, passed as $0.
+func ulimitScript(limits Limits) string {
+	script := ""
+	if limits.Memory > 0 {
+		script += "ulimit -v " + strconv.FormatInt(limits.Memory>>10, 10) + " && "
+	}
+	// CPU time is a backstop for the wall clock timer.
+	script += "ulimit -t " + strconv.Itoa(int(limits.Time/time.Second)+1) + " && "
+	return script + `exec "$0" "$@"`
+}
+
+// limitedWriter stops accepting data after n bytes but keeps reporting
+// success so the child doesn't get SIGPIPE before we kill it.
+type limitedWriter struct {
+	w        io.Writer
+	n        int64
+	written  int64
+	exceeded bool
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if l.n <= 0 {
+		return l.w.Write(p)
+	}
+	if l.written >= l.n {
+		l.exceeded = true
+		return len(p), nil
+	}
+	chunk := p
+	if int64(len(chunk)) > l.n-l.written {
+		chunk = chunk[:l.n-l.written]
+		l.exceeded = true
+	}
+	l.written += int64(len(chunk))
+	if _, err := l.w.Write(chunk); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
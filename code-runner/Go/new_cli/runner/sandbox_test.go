@@ -0,0 +1,141 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestLimitedWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		limit    int64
+		writes   []string
+		want     string
+		exceeded bool
+	}{
+		{"unlimited", 0, []string{"abc", "def"}, "abcdef", false},
+		{"under the limit", 10, []string{"abc", "def"}, "abcdef", false},
+		{"exactly the limit", 6, []string{"abc", "def"}, "abcdef", false},
+		{"cut in a write", 4, []string{"abc", "def"}, "abcd", true},
+		{"after the limit", 3, []string{"abc", "def", "ghi"}, "abc", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := &limitedWriter{w: &buf, n: tt.limit}
+			for _, s := range tt.writes {
+				// The program must never see a short write
+				if n, err := w.Write([]byte(s)); n != len(s) || err != nil {
+					t.Fatalf("Write(%q) = %d, %v", s, n, err)
+				}
+			}
+			if buf.String() != tt.want || w.exceeded != tt.exceeded {
+				t.Errorf("got %q exceeded=%v, want %q exceeded=%v", buf.String(), w.exceeded, tt.want, tt.exceeded)
+			}
+		})
+	}
+}
+
+func TestRunLimits(t *testing.T) {
+	ctx := context.Background()
+
+	res, err := Run(ctx, Spec{Path: "/bin/sh", Args: []string{"-c", "head -c 5000 /dev/zero"}, Limits: Limits{Output: 1000}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Stdout) != 1000 || !res.OutputExceeded || res.ExitCode != 0 {
+		t.Errorf("output limit: got %d bytes, exceeded=%v, exit %d", len(res.Stdout), res.OutputExceeded, res.ExitCode)
+	}
+	if verdict, _ := classify(res, Limits{Output: 1000}); verdict != OutputLimitExceeded {
+		t.Errorf("output limit classified as %q", verdict)
+	}
+
+	start := time.Now()
+	res, err = Run(ctx, Spec{Path: "/bin/sh", Args: []string{"-c", "while :; do :; done"}, Limits: Limits{Time: 200 * time.Millisecond}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.TimedOut || time.Since(start) > 2*time.Second {
+		t.Errorf("time limit: timed out=%v after %s", res.TimedOut, time.Since(start))
+	}
+
+	res, err = Run(ctx, Spec{Path: "/bin/sh", Args: []string{"-c", "echo oops >&2; exit 4"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ExitCode != 4 || strings.TrimSpace(string(res.Stderr)) != "oops" {
+		t.Errorf("exit: got code %d, stderr %q", res.ExitCode, res.Stderr)
+	}
+}
+
+// Nothing of the caller's environment but what programs need reaches them.
+func TestRunEnvironment(t *testing.T) {
+	t.Setenv("REDIS_URL", "redis://secret")
+	for name, env := range map[string][]string{"default": nil, "sanitizer": SanitizerEnv(0)} {
+		res, err := Run(context.Background(), Spec{Path: "/bin/sh", Args: []string{"-c", "env"}, Env: env})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := string(res.Stdout)
+		if strings.Contains(got, "secret") || !strings.Contains(got, "PATH=") {
+			t.Errorf("%s environment:\n%s", name, got)
+		}
+	}
+}
+
+// running reports whether pid is a live process, zombies not counted.
+func running(pid int) bool {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	// The state follows the parenthesised command name
+	fields := strings.Fields(string(data[bytes.LastIndexByte(data, ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+func TestRunKillsForkedProcesses(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("no /proc to look for processes in")
+	}
+	tests := []struct {
+		name   string
+		script string
+		limit  time.Duration
+		within time.Duration // for Run to return
+	}{
+		{"on a timeout", "sleep 30 & echo $!; sleep 30", 300 * time.Millisecond, 900 * time.Millisecond},
+		{"when the program exits", "sleep 30 & echo $!", 5 * time.Second, 500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			res, err := Run(context.Background(), Spec{Path: "/bin/sh", Args: []string{"-c", tt.script}, Limits: Limits{Time: tt.limit}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); elapsed > tt.within {
+				t.Errorf("Run took %s; the forked process kept it waiting", elapsed)
+			}
+			pid, err := strconv.Atoi(strings.TrimSpace(string(res.Stdout)))
+			if err != nil {
+				t.Fatalf("no pid in %q", res.Stdout)
+			}
+			// The orphan is reaped by whoever adopts it, which can take a moment
+			for running(pid) && time.Since(start) < 3*time.Second {
+				time.Sleep(10 * time.Millisecond)
+			}
+			if running(pid) {
+				syscall.Kill(pid, syscall.SIGKILL)
+				t.Errorf("the forked process %d outlived the program", pid)
+			}
+		})
+	}
+}
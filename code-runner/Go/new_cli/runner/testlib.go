@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// testlibHeader is the testlib.h every build can include, so instructors'
+// checkers, interactors and generators compile wherever the judge runs.
+//
+//go:embed testlib/testlib.h
+var testlibHeader []byte
+
+var (
+	testlibOnce sync.Once
+	testlibDir  string
+	testlibErr  error
+)
+
+// TestlibDir returns a directory holding testlib.h, to pass to the compiler
+// with -I.
+func TestlibDir() (string, error) {
+	testlibOnce.Do(func() {
+		testlibDir, testlibErr = writeTestlib()
+	})
+	return testlibDir, testlibErr
+}
+
+// writeTestlib puts the header in a directory named after its contents, so
+// every process of the same version shares one copy.
+func writeTestlib() (string, error) {
+	sum := sha256.Sum256(testlibHeader)
+	dir := filepath.Join(os.TempDir(), fmt.Sprintf("biskut-testlib-%d-%x", os.Getuid(), sum[:6]))
+	path := filepath.Join(dir, "testlib.h")
+	if data, err := os.ReadFile(path); err == nil && bytes.Equal(data, testlibHeader) {
+		return dir, nil
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("error creating testlib dir: %v", err)
+	}
+	// Write and rename, so a concurrent build never reads half a header
+	tmp, err := os.CreateTemp(dir, "testlib-*.h")
+	if err != nil {
+		return "", fmt.Errorf("error writing testlib.h: %v", err)
+	}
+	_, err = tmp.Write(testlibHeader)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("error writing testlib.h: %v", err)
+	}
+	return dir, nil
+}
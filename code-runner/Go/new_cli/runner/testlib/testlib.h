@@ -0,0 +1,532 @@
+/*
+ * A self-contained subset of testlib (https://github.com/MikeMirzayanov/testlib)
+ * for the checkers, interactors and generators biskut builds. It keeps the
+ * names, command lines, messages and exit codes of testlib, so programs
+ * written against it build and judge the same way, but covers only the
+ * common part of its API:
+ *
+ *   - registerTestlibCmd, registerInteraction and registerGen
+ *   - inf, ouf, ans and tout, with readInt, readLong, readDouble, readToken,
+ *     readWord, readLine, readSpace, readEoln, readEof, eof and seekEof
+ *   - quitf, quit, ensure and ensuref
+ *   - rnd.next, rnd.any, rnd.wnext and shuffle
+ *   - format, toString, vtos, englishEnding and doubleCompare
+ *
+ * Validators (strict whitespace) are not supported, and rnd produces other
+ * numbers than upstream testlib for the same seed. To use everything
+ * testlib offers, replace this file with the upstream testlib.h.
+ */
+#ifndef _TESTLIB_H_
+#define _TESTLIB_H_
+
+#include <algorithm>
+#include <cctype>
+#include <cerrno>
+#include <cmath>
+#include <cstdarg>
+#include <cstdint>
+#include <cstdio>
+#include <cstdlib>
+#include <cstring>
+#include <fstream>
+#include <iostream>
+#include <sstream>
+#include <string>
+#include <vector>
+
+#define OK_EXIT_CODE 0
+#define WA_EXIT_CODE 1
+#define PE_EXIT_CODE 2
+#define FAIL_EXIT_CODE 3
+#define POINTS_EXIT_CODE 7
+
+enum TResult {
+    _ok = 0,
+    _wa = 1,
+    _pe = 2,
+    _fail = 3,
+    _points = 7
+};
+
+enum TMode {
+    _input,
+    _output,
+    _answer
+};
+
+enum TTestlibMode {
+    _unknown,
+    _checker,
+    _interactor,
+    _generator
+};
+
+static TTestlibMode testlibMode = _unknown;
+
+inline std::string vformat(const char *fmt, va_list ap) {
+    va_list copy;
+    va_copy(copy, ap);
+    int n = vsnprintf(NULL, 0, fmt, copy);
+    va_end(copy);
+    if (n < 0)
+        return std::string();
+    std::vector<char> buf(n + 1);
+    vsnprintf(buf.data(), buf.size(), fmt, ap);
+    return std::string(buf.data(), n);
+}
+
+inline std::string format(const char *fmt, ...) {
+    va_list ap;
+    va_start(ap, fmt);
+    std::string s = vformat(fmt, ap);
+    va_end(ap);
+    return s;
+}
+
+template<typename T>
+std::string toString(const T &t) {
+    std::ostringstream ss;
+    ss << t;
+    return ss.str();
+}
+
+template<typename T>
+std::string vtos(const T &t) {
+    return toString(t);
+}
+
+inline std::string englishEnding(int x) {
+    x %= 100;
+    if (x / 10 == 1)
+        return "th";
+    if (x % 10 == 1)
+        return "st";
+    if (x % 10 == 2)
+        return "nd";
+    if (x % 10 == 3)
+        return "rd";
+    return "th";
+}
+
+// doubleCompare accepts result if its absolute or relative error is at
+// most maxError.
+inline bool doubleCompare(double expected, double result, double maxError) {
+    if (std::isnan(expected) || std::isnan(result))
+        return std::isnan(expected) && std::isnan(result);
+    if (std::isinf(expected) || std::isinf(result))
+        return expected == result;
+    if (std::fabs(result - expected) <= maxError + 1e-15)
+        return true;
+    double lo = std::min(expected * (1.0 - maxError), expected * (1.0 + maxError));
+    double hi = std::max(expected * (1.0 - maxError), expected * (1.0 + maxError));
+    return result + 1e-15 >= lo && result <= hi + 1e-15;
+}
+
+inline void quit(TResult result, const std::string &message);
+
+struct InStream {
+    std::string name;
+    TMode mode;
+    FILE *file;
+    bool opened;
+
+    InStream() : mode(_input), file(NULL), opened(false) {}
+
+    void init(FILE *f, const std::string &streamName, TMode streamMode) {
+        file = f;
+        name = streamName;
+        mode = streamMode;
+        opened = true;
+    }
+
+    void init(const char *path, TMode streamMode) {
+        FILE *f = std::fopen(path, "rb");
+        if (f == NULL) {
+            opened = false;
+            quit(_fail, std::string("Can not open file ") + path);
+        }
+        init(f, path, streamMode);
+    }
+
+    int peek() {
+        int c = std::getc(file);
+        if (c != EOF)
+            std::ungetc(c, file);
+        return c;
+    }
+
+    static bool isBlank(int c) {
+        return c == ' ' || c == '\t' || c == '\n' || c == '\r';
+    }
+
+    void skipBlanks() {
+        while (isBlank(peek()))
+            std::getc(file);
+    }
+
+    // Errors in the contestant's output are a wrong format; in the input or
+    // the answer, the test itself is broken.
+    void quit(TResult result, const std::string &message) {
+        if (mode != _output && result != _fail)
+            ::quit(_fail, message + " (" + name + ")");
+        ::quit(result, message);
+    }
+
+    void quitf(TResult result, const char *fmt, ...) {
+        va_list ap;
+        va_start(ap, fmt);
+        std::string message = vformat(fmt, ap);
+        va_end(ap);
+        quit(result, message);
+    }
+
+    bool eof() {
+        return peek() == EOF;
+    }
+
+    bool seekEof() {
+        skipBlanks();
+        return eof();
+    }
+
+    bool eoln() {
+        int c = peek();
+        return c == '\n' || c == '\r' || c == EOF;
+    }
+
+    bool seekEoln() {
+        while (peek() == ' ' || peek() == '\t')
+            std::getc(file);
+        return eoln();
+    }
+
+    void nextLine() {
+        int c;
+        while ((c = std::getc(file)) != EOF && c != '\n') {
+        }
+    }
+
+    std::string readToken() {
+        skipBlanks();
+        std::string token;
+        int c;
+        while ((c = peek()) != EOF && !isBlank(c)) {
+            token += (char) std::getc(file);
+        }
+        if (token.empty())
+            quit(_pe, "Unexpected end of file - token expected");
+        return token;
+    }
+
+    std::string readWord() {
+        return readToken();
+    }
+
+    std::string readToken(const std::string &, const std::string &) {
+        return readToken();
+    }
+
+    long long readLong() {
+        std::string token = readToken();
+        const char *s = token.c_str();
+        char *end;
+        errno = 0;
+        long long value = std::strtoll(s, &end, 10);
+        // Only the canonical form: no "+", no leading zeros, no "-0"
+        if (*end != '\0' || end == s || errno == ERANGE || token[0] == '+' ||
+            (token.size() > 1 && token[0] == '0') || (token.size() > 1 && token[0] == '-' && token[1] == '0'))
+            quit(_pe, "Expected integer, but \"" + token + "\" found");
+        return value;
+    }
+
+    long long readLong(long long minv, long long maxv, const std::string &variableName = "") {
+        long long value = readLong();
+        if (value < minv || value > maxv) {
+            std::string what = variableName.empty() ? "Integer" : "Integer " + variableName;
+            quit(_wa, format("%s %lld violates the range [%lld, %lld]", what.c_str(), value, minv, maxv));
+        }
+        return value;
+    }
+
+    int readInt() {
+        long long value = readLong();
+        if (value < INT32_MIN || value > INT32_MAX)
+            quit(_pe, "Expected int32, but \"" + toString(value) + "\" found");
+        return (int) value;
+    }
+
+    int readInt(int minv, int maxv, const std::string &variableName = "") {
+        return (int) readLong(minv, maxv, variableName);
+    }
+
+    std::vector<int> readInts(int size, int minv, int maxv, const std::string &variableName = "") {
+        std::vector<int> result(size);
+        for (int i = 0; i < size; i++)
+            result[i] = readInt(minv, maxv, variableName);
+        return result;
+    }
+
+    std::vector<long long> readLongs(int size, long long minv, long long maxv, const std::string &variableName = "") {
+        std::vector<long long> result(size);
+        for (int i = 0; i < size; i++)
+            result[i] = readLong(minv, maxv, variableName);
+        return result;
+    }
+
+    double readDouble() {
+        std::string token = readToken();
+        const char *s = token.c_str();
+        char *end;
+        double value = std::strtod(s, &end);
+        if (*end != '\0' || end == s || std::isnan(value) || std::isinf(value))
+            quit(_pe, "Expected double, but \"" + token + "\" found");
+        return value;
+    }
+
+    double readDouble(double minv, double maxv, const std::string &variableName = "") {
+        double value = readDouble();
+        if (value < minv || value > maxv) {
+            std::string what = variableName.empty() ? "Double" : "Double " + variableName;
+            quit(_wa, format("%s %f violates the range [%f, %f]", what.c_str(), value, minv, maxv));
+        }
+        return value;
+    }
+
+    double readReal() {
+        return readDouble();
+    }
+
+    double readReal(double minv, double maxv, const std::string &variableName = "") {
+        return readDouble(minv, maxv, variableName);
+    }
+
+    std::string readLine() {
+        if (eof())
+            quit(_pe, "Unexpected end of file - line expected");
+        std::string line;
+        int c;
+        while ((c = std::getc(file)) != EOF && c != '\n') {
+            if (c != '\r')
+                line += (char) c;
+        }
+        return line;
+    }
+
+    std::string readString() {
+        return readLine();
+    }
+
+    void readSpace() {
+        if (std::getc(file) != ' ')
+            quit(_pe, "Expected space");
+    }
+
+    void readEoln() {
+        int c = std::getc(file);
+        if (c == '\r')
+            c = std::getc(file);
+        if (c != '\n')
+            quit(_pe, "Expected EOLN");
+    }
+
+    void readEof() {
+        if (!eof())
+            quit(_pe, "Expected EOF");
+    }
+};
+
+static InStream inf;
+static InStream ouf;
+static InStream ans;
+static std::ofstream tout;
+
+inline const char *resultName(TResult result) {
+    switch (result) {
+    case _ok:
+        return "ok";
+    case _wa:
+        return "wrong answer";
+    case _pe:
+        return "wrong output format";
+    case _points:
+        return "points";
+    default:
+        return "FAIL";
+    }
+}
+
+inline void exitWith(TResult result, const std::string &message) {
+    std::fprintf(stderr, "%s %s\n", resultName(result), message.c_str());
+    std::fflush(stdout);
+    if (tout.is_open())
+        tout.close();
+    std::exit(result == _points ? POINTS_EXIT_CODE : (int) result);
+}
+
+inline void quit(TResult result, const std::string &message) {
+    // Whatever is left in the output is an error too, as in testlib
+    if (result == _ok && testlibMode == _checker && ouf.opened && !ouf.seekEof())
+        exitWith(_pe, "Extra information in the output file");
+    exitWith(result, message);
+}
+
+inline void quitf(TResult result, const char *fmt, ...) {
+    va_list ap;
+    va_start(ap, fmt);
+    std::string message = vformat(fmt, ap);
+    va_end(ap);
+    quit(result, message);
+}
+
+inline void ensuref(bool condition, const char *fmt, ...) {
+    if (!condition) {
+        va_list ap;
+        va_start(ap, fmt);
+        std::string message = vformat(fmt, ap);
+        va_end(ap);
+        quit(_fail, message);
+    }
+}
+
+#define ensure(cond) ensuref((cond), "%s", "Condition failed: \"" #cond "\"")
+
+// registerTestlibCmd sets a checker up: checker <input> <output> <answer>.
+inline void registerTestlibCmd(int argc, char *argv[]) {
+    testlibMode = _checker;
+    if (argc < 4)
+        quit(_fail, std::string("Program must be run with the arguments <input-file> <output-file> <answer-file>, got ") +
+                        toString(argc - 1));
+    inf.init(argv[1], _input);
+    ouf.init(argv[2], _output);
+    ans.init(argv[3], _answer);
+}
+
+// registerInteraction sets an interactor up: interactor <input> <output>
+// [<answer>], with the solution on stdin and stdout.
+inline void registerInteraction(int argc, char *argv[]) {
+    testlibMode = _interactor;
+    if (argc < 3)
+        quit(_fail, "Program must be run with the arguments <input-file> <output-file> [<answer-file>]");
+    inf.init(argv[1], _input);
+    tout.open(argv[2], std::ios_base::out);
+    if (!tout.is_open())
+        quit(_fail, std::string("Can not write to the file ") + argv[2]);
+    ouf.init(stdin, "stdin", _output);
+    if (argc > 3)
+        ans.init(argv[3], _answer);
+}
+
+struct random_t {
+    uint64_t state;
+
+    random_t() : state(0x9E3779B97F4A7C15ULL) {}
+
+    void setSeed(uint64_t seed) {
+        state = seed ^ 0x9E3779B97F4A7C15ULL;
+    }
+
+    // splitmix64
+    uint64_t nextBits() {
+        uint64_t z = (state += 0x9E3779B97F4A7C15ULL);
+        z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9ULL;
+        z = (z ^ (z >> 27)) * 0x94D049BB133111EBULL;
+        return z ^ (z >> 31);
+    }
+
+    // A number in [0, n), without modulo bias.
+    uint64_t below(uint64_t n) {
+        if (n == 0)
+            quit(_fail, "random_t::next(n): n must be positive");
+        uint64_t limit = UINT64_MAX - UINT64_MAX % n;
+        uint64_t x;
+        do {
+            x = nextBits();
+        } while (x >= limit);
+        return x % n;
+    }
+
+    int next(int n) {
+        if (n <= 0)
+            quit(_fail, "random_t::next(int n): n must be positive");
+        return (int) below(n);
+    }
+
+    long long next(long long n) {
+        if (n <= 0)
+            quit(_fail, "random_t::next(long long n): n must be positive");
+        return (long long) below(n);
+    }
+
+    int next(int from, int to) {
+        if (from > to)
+            quit(_fail, "random_t::next(int from, int to): from can't be greater than to");
+        return (int) (from + (long long) below((uint64_t) ((long long) to - from + 1)));
+    }
+
+    long long next(long long from, long long to) {
+        if (from > to)
+            quit(_fail, "random_t::next(long long from, long long to): from can't be greater than to");
+        uint64_t range = (uint64_t) to - (uint64_t) from + 1;
+        if (range == 0)
+            return (long long) nextBits();
+        return (long long) ((uint64_t) from + below(range));
+    }
+
+    // A number in [0, 1).
+    double next() {
+        return (nextBits() >> 11) * (1.0 / 9007199254740992.0);
+    }
+
+    double next(double n) {
+        return n * next();
+    }
+
+    double next(double from, double to) {
+        return from + (to - from) * next();
+    }
+
+    // wnext(n, type) is the largest of type+1 draws for a positive type,
+    // and the smallest of -type+1 for a negative one.
+    int wnext(int n, int type) {
+        int result = next(n);
+        for (int i = 0; i < std::abs(type); i++) {
+            int other = next(n);
+            result = type > 0 ? std::max(result, other) : std::min(result, other);
+        }
+        return result;
+    }
+
+    int wnext(int from, int to, int type) {
+        return from + wnext(to - from + 1, type);
+    }
+
+    template<typename Container>
+    typename Container::value_type any(const Container &c) {
+        if (c.empty())
+            quit(_fail, "random_t::any(c): c is empty");
+        return *(c.begin() + next((int) c.size()));
+    }
+};
+
+static random_t rnd;
+
+template<typename Iter>
+void shuffle(Iter begin, Iter end) {
+    for (long long i = end - begin - 1; i > 0; i--)
+        std::iter_swap(begin + i, begin + rnd.next(0LL, i));
+}
+
+// registerGen seeds rnd from the command line, so a generator prints the
+// same test for the same arguments.
+inline void registerGen(int argc, char *argv[], int = 1) {
+    testlibMode = _generator;
+    uint64_t seed = 3905348978240129619ULL;
+    for (int i = 1; i < argc; i++) {
+        for (const char *p = argv[i]; *p; p++)
+            seed = seed * 0x100000001B3ULL ^ (unsigned char) *p;
+        seed = seed * 0x100000001B3ULL ^ ' ';
+    }
+    rnd.setSeed(seed);
+}
+
+#endif
@@ -0,0 +1,24 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package runner
+
+import "syscall"
+
+// waitExit blocks until the process pid exits, without reaping it. Until
+// cmd.Wait reaps it, its pid, and so its process group ID, can't be reused.
+func waitExit(pid int) {
+	kq, err := syscall.Kqueue()
+	if err != nil {
+		return
+	}
+	defer syscall.Close(kq)
+
+	change := syscall.Kevent_t{Ident: uint64(pid), Filter: syscall.EVFILT_PROC, Flags: syscall.EV_ADD | syscall.EV_ONESHOT, Fflags: syscall.NOTE_EXIT}
+	events := make([]syscall.Kevent_t, 1)
+	for {
+		_, err := syscall.Kevent(kq, []syscall.Kevent_t{change}, events, nil)
+		if err != syscall.EINTR {
+			return
+		}
+	}
+}
@@ -0,0 +1,11 @@
+package runner
+
+import "golang.org/x/sys/unix"
+
+// waitExit blocks until the process pid exits, without reaping it. Until
+// cmd.Wait reaps it, its pid, and so its process group ID, can't be reused.
+func waitExit(pid int) {
+	var info unix.Siginfo
+	for unix.Waitid(unix.P_PID, pid, &info, unix.WEXITED|unix.WNOWAIT, nil) == unix.EINTR {
+	}
+}
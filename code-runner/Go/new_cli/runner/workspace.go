@@ -0,0 +1,87 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Workspace is a private scratch directory for a single build or run, so
+// concurrent runs of the same file never share executables or output files.
+type Workspace struct {
+	Dir  string
+	Keep bool // leave the directory behind for debugging
+}
+
+var (
+	workspacesMu sync.Mutex
+	workspaces   = map[*Workspace]struct{}{}
+)
+
+// KeepArtifacts reports whether BISKUT_KEEP_ARTIFACTS asks for workspaces to
+// survive after a run.
+func KeepArtifacts() bool {
+	return os.Getenv("BISKUT_KEEP_ARTIFACTS") != ""
+}
+
+// NewWorkspace creates a unique temporary directory. Callers must Close it;
+// CleanupWorkspaces handles the paths where deferred calls don't run.
+func NewWorkspace(name string) (*Workspace, error) {
+	dir, err := os.MkdirTemp("", "biskut-"+name+"-")
+	if err != nil {
+		return nil, fmt.Errorf("error creating workspace: %v", err)
+	}
+
+	ws := &Workspace{Dir: dir, Keep: KeepArtifacts()}
+	workspacesMu.Lock()
+	workspaces[ws] = struct{}{}
+	workspacesMu.Unlock()
+	return ws, nil
+}
+
+func (w *Workspace) Close() error {
+	workspacesMu.Lock()
+	delete(workspaces, w)
+	workspacesMu.Unlock()
+
+	if w.Keep {
+		return nil
+	}
+	return os.RemoveAll(w.Dir)
+}
+
+// CleanupWorkspaces removes every workspace that is still open. It is meant
+// for signal handlers that are about to exit the process.
+func CleanupWorkspaces() {
+	workspacesMu.Lock()
+	open := make([]*Workspace, 0, len(workspaces))
+	for ws := range workspaces {
+		open = append(open, ws)
+	}
+	workspacesMu.Unlock()
+
+	for _, ws := range open {
+		ws.Close()
+	}
+}
+
+// CopyFile copies src to dst with the given permissions, used to place a
+// cached executable inside a workspace that is being kept.
+func CopyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
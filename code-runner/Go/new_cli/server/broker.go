@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"new_cli/worker"
+)
+
+// Broker connects the server to the judge: the submissions queue and the
+// pub/sub channels progress is reported on.
+type Broker interface {
+	Enqueue(ctx context.Context, sub worker.Submission) error
+	// Subscribe delivers messages published on channel until ctx is done.
+	// The subscription is active by the time Subscribe returns.
+	Subscribe(ctx context.Context, channel string) (<-chan string, error)
+	Publish(ctx context.Context, channel string, message []byte) error
+	// Position reports where a queued submission stands, or nil once the
+	// judge has started on it.
+	Position(ctx context.Context, submissionID int) (*worker.Position, error)
+	// Cancel withdraws a submission from the queue, or stops the judge
+	// working on it.
+	Cancel(ctx context.Context, submissionID int) error
+	// NextVerdict returns the final event of a submission the judge
+	// finished, waiting up to block for one; nil if none came. It is handed
+	// out again, possibly to another server, until AckVerdict.
+	NextVerdict(ctx context.Context, block time.Duration) (*worker.Verdict, error)
+	AckVerdict(ctx context.Context, v *worker.Verdict) error
+	// Known returns the IDs of the submissions the judge still has: queued,
+	// being judged, or with a verdict not yet acknowledged.
+	Known(ctx context.Context) (map[int]bool, error)
+}
+
+type RedisBroker struct {
+	Redis *redis.Client
+	Queue *worker.Queue
+	// Consumer is this server's name in the group reading verdicts.
+	Consumer string
+}
+
+func (b *RedisBroker) Enqueue(ctx context.Context, sub worker.Submission) error {
+	return b.Queue.Push(ctx, sub)
+}
+
+func (b *RedisBroker) Subscribe(ctx context.Context, channel string) (<-chan string, error) {
+	ps := b.Redis.Subscribe(ctx, channel)
+	if _, err := ps.Receive(ctx); err != nil {
+		ps.Close()
+		return nil, err
+	}
+
+	messages := make(chan string)
+	go func() {
+		defer close(messages)
+		defer ps.Close()
+		ch := ps.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				select {
+				case messages <- msg.Payload:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return messages, nil
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, channel string, message []byte) error {
+	return b.Redis.Publish(ctx, channel, message).Err()
+}
+
+func (b *RedisBroker) Position(ctx context.Context, submissionID int) (*worker.Position, error) {
+	return b.Queue.Position(ctx, submissionID)
+}
+
+func (b *RedisBroker) Cancel(ctx context.Context, submissionID int) error {
+	return b.Queue.Cancel(ctx, submissionID)
+}
+
+func (b *RedisBroker) NextVerdict(ctx context.Context, block time.Duration) (*worker.Verdict, error) {
+	return b.Queue.NextVerdict(ctx, b.Consumer, block)
+}
+
+func (b *RedisBroker) AckVerdict(ctx context.Context, v *worker.Verdict) error {
+	return b.Queue.AckVerdict(ctx, v)
+}
+
+func (b *RedisBroker) Known(ctx context.Context) (map[int]bool, error) {
+	return b.Queue.Known(ctx)
+}
@@ -0,0 +1,334 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"new_cli/api"
+	"new_cli/runner"
+)
+
+// The instructor endpoints (/api/ins), with the same validation messages as
+// InstructorController.ts.
+
+func (s *Server) instructorRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/ins/labsession", s.createLabSession)
+	mux.HandleFunc("GET /api/ins/labsession", s.getLabSession)
+	mux.HandleFunc("POST /api/ins/question", s.createQuestion)
+	mux.HandleFunc("GET /api/ins/question", s.getQuestion)
+	mux.HandleFunc("POST /api/ins/question/testcases", s.updateTestCases)
+	mux.HandleFunc("POST /api/ins/question/add", s.addQuestionToLabSession)
+	mux.HandleFunc("GET /api/ins/attendance", s.getLabAttendance)
+	mux.HandleFunc("GET /api/ins/details", s.getInstructorDetail)
+	mux.HandleFunc("GET /api/ins/submissions", s.getSubmissionsForLabSession)
+	mux.HandleFunc("POST /api/ins/judge", s.judgeSubmission)
+}
+
+// readJSON decodes the request body into v, answering 400 itself when it
+// isn't valid JSON.
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON body"})
+		return false
+	}
+	return true
+}
+
+// parseSessionDate accepts the date formats the CLI and WebApp send.
+func parseSessionDate(value string) (time.Time, error) {
+	var err error
+	for _, layout := range []string{api.TimeFormat, time.RFC3339, "2006-01-02T15:04", "2006-01-02"} {
+		var t time.Time
+		if t, err = time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+func (s *Server) createLabSession(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ProgramID    int    `json:"programId"`
+		InstructorID int    `json:"instructorId"`
+		SessionDate  string `json:"sessionDate"`
+		Description  string `json:"description"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+	if body.ProgramID == 0 || body.InstructorID == 0 || body.SessionDate == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "programId, instructorId and sessionDate are required"})
+		return
+	}
+	date, err := parseSessionDate(body.SessionDate)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid date format for sessionDate"})
+		return
+	}
+
+	ls := &api.LabSession{
+		ProgramID:    body.ProgramID,
+		InstructorID: body.InstructorID,
+		SessionDate:  formatTime(date),
+		Description:  body.Description,
+	}
+	err = s.Store.CreateLabSession(r.Context(), ls)
+	if errors.Is(err, ErrNotFound) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Program or instructor not found"})
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	created, err := s.Store.LabSession(r.Context(), ls.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (s *Server) getLabSession(w http.ResponseWriter, r *http.Request) {
+	labSessionID, ok := intParam(w, r, "labSessionId")
+	if !ok {
+		return
+	}
+
+	ls, err := s.Store.LabSession(r.Context(), labSessionID)
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "labSession not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ls)
+}
+
+func (s *Server) createQuestion(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Description   string            `json:"description"`
+		InstructorID  int               `json:"instructorId"`
+		LabSessionID  int               `json:"labSessionId"`
+		TestCases     []runner.TestCase `json:"testCases"`
+		TestCaseBased bool              `json:"testCaseBased"`
+		Checker       string            `json:"checker"`
+		Interactor    string            `json:"interactor"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+	if body.Description == "" || body.InstructorID == 0 || body.TestCases == nil || body.LabSessionID == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "description, instructorId, testCases, and labSessionId are required"})
+		return
+	}
+
+	testCases, err := json.Marshal(body.TestCases)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	q := &api.Question{
+		InstructorID:  body.InstructorID,
+		LabSessionID:  body.LabSessionID,
+		Description:   body.Description,
+		InputsOutputs: string(testCases),
+		TestCaseBased: body.TestCaseBased,
+		Checker:       body.Checker,
+		Interactor:    body.Interactor,
+	}
+	err = s.Store.CreateQuestion(r.Context(), q)
+	if errors.Is(err, ErrNotFound) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Lab session not found"})
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, q)
+}
+
+func (s *Server) getQuestion(w http.ResponseWriter, r *http.Request) {
+	questionID, ok := intParam(w, r, "questionId")
+	if !ok {
+		return
+	}
+
+	q, err := s.Store.Question(r.Context(), questionID)
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "Question not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, q)
+}
+
+func (s *Server) updateTestCases(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		QuestionID int               `json:"questionId"`
+		TestCases  []runner.TestCase `json:"testCases"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+	if body.QuestionID == 0 || body.TestCases == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "questionId and testCases are required"})
+		return
+	}
+
+	testCases, err := json.Marshal(body.TestCases)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	q, err := s.Store.UpdateTestCases(r.Context(), body.QuestionID, string(testCases))
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "Question not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, q)
+}
+
+func (s *Server) addQuestionToLabSession(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		LabSessionID int `json:"labSessionId"`
+		QuestionID   int `json:"questionId"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+
+	err := s.Store.MoveQuestion(r.Context(), body.QuestionID, body.LabSessionID)
+	if errors.Is(err, ErrNotFound) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Question or lab session not found"})
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	ls, err := s.Store.LabSession(r.Context(), body.LabSessionID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ls)
+}
+
+func (s *Server) getLabAttendance(w http.ResponseWriter, r *http.Request) {
+	labSessionID, ok := intParam(w, r, "labSessionId")
+	if !ok {
+		return
+	}
+
+	ls, err := s.Store.LabSession(r.Context(), labSessionID)
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "labSession not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	students, err := s.Store.ProgramStudents(r.Context(), ls.ProgramID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	submissions, err := s.Store.LabSessionSubmissions(r.Context(), labSessionID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Everyone enrolled is absent until they submit something
+	attendance := map[string]bool{}
+	for _, st := range students {
+		attendance[st.EnrollmentNumber] = false
+	}
+	for _, sub := range submissions {
+		attendance[sub.Student.EnrollmentNumber] = true
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"attendance": attendance})
+}
+
+func (s *Server) getInstructorDetail(w http.ResponseWriter, r *http.Request) {
+	instructorID, ok := intParam(w, r, "instructorId")
+	if !ok {
+		return
+	}
+
+	ins, err := s.Store.Instructor(r.Context(), instructorID)
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "Instructor not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ins)
+}
+
+func (s *Server) getSubmissionsForLabSession(w http.ResponseWriter, r *http.Request) {
+	labSessionID, ok := intParam(w, r, "labSessionId")
+	if !ok {
+		return
+	}
+
+	subs, err := s.Store.LabSessionSubmissions(r.Context(), labSessionID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, subs)
+}
+
+func (s *Server) judgeSubmission(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		SubmissionID int    `json:"submissionId"`
+		Verdict      string `json:"verdict"`
+		Comment      string `json:"comment"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+	if body.SubmissionID == 0 {
+		http.Error(w, "submissionId is required", http.StatusBadRequest)
+		return
+	}
+	if body.Verdict == "" {
+		http.Error(w, "verdict is required", http.StatusBadRequest)
+		return
+	}
+	if body.Verdict != api.StatusPassed && body.Verdict != api.StatusFailed {
+		http.Error(w, "Invalid verdict", http.StatusBadRequest)
+		return
+	}
+
+	sub, err := s.Store.JudgeSubmission(r.Context(), body.SubmissionID, body.Verdict, body.Comment)
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "Submission not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sub)
+}
@@ -0,0 +1,309 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"new_cli/api"
+)
+
+// MemoryStore keeps everything in maps. Seed it with the Add methods.
+type MemoryStore struct {
+	mu          sync.Mutex
+	students    map[int]api.Student
+	programs    map[int]api.Program
+	enrolled    map[int]map[int]bool // program ID -> student IDs
+	instructors map[int]api.Instructor
+	sessions    map[int]api.LabSession
+	questions   map[int]api.Question
+	submissions []api.Submission
+
+	// Now stamps new submissions.
+	Now func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		students:    map[int]api.Student{},
+		programs:    map[int]api.Program{},
+		enrolled:    map[int]map[int]bool{},
+		instructors: map[int]api.Instructor{},
+		sessions:    map[int]api.LabSession{},
+		questions:   map[int]api.Question{},
+		Now:         time.Now,
+	}
+}
+
+func (m *MemoryStore) AddStudent(s api.Student) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.students[s.ID] = s
+}
+
+// AddProgram stores p and enrolls the given students in it.
+func (m *MemoryStore) AddProgram(p api.Program, studentIDs ...int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.programs[p.ID] = p
+	if m.enrolled[p.ID] == nil {
+		m.enrolled[p.ID] = map[int]bool{}
+	}
+	for _, id := range studentIDs {
+		m.enrolled[p.ID][id] = true
+	}
+}
+
+func (m *MemoryStore) AddInstructor(i api.Instructor) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.instructors[i.ID] = i
+}
+
+// AddLabSession stores ls; its program, instructor and questions are
+// resolved from the store when it is read back.
+func (m *MemoryStore) AddLabSession(ls api.LabSession) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[ls.ID] = ls
+}
+
+func (m *MemoryStore) AddQuestion(q api.Question) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.questions[q.ID] = q
+}
+
+func (m *MemoryStore) Student(ctx context.Context, id int) (api.Student, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.students[id]
+	if !ok {
+		return api.Student{}, ErrNotFound
+	}
+	return s, nil
+}
+
+// session fills in the relations of a stored lab session. m.mu must be held.
+func (m *MemoryStore) session(ls api.LabSession) api.LabSession {
+	ls.Program = m.programs[ls.ProgramID]
+	ls.Instructor = m.instructors[ls.InstructorID]
+	ls.Questions = []api.Question{}
+	for _, q := range m.questions {
+		if q.LabSessionID == ls.ID {
+			ls.Questions = append(ls.Questions, q)
+		}
+	}
+	sort.Slice(ls.Questions, func(i, j int) bool { return ls.Questions[i].ID < ls.Questions[j].ID })
+	return ls
+}
+
+func (m *MemoryStore) LabSessions(ctx context.Context, studentID int, from, to time.Time) ([]api.LabSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sessions := []api.LabSession{}
+	for _, ls := range m.sessions {
+		date, err := time.Parse(api.TimeFormat, ls.SessionDate)
+		if err != nil || date.Before(from) || date.After(to) {
+			continue
+		}
+		if !m.enrolled[ls.ProgramID][studentID] {
+			continue
+		}
+		sessions = append(sessions, m.session(ls))
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+	return sessions, nil
+}
+
+func (m *MemoryStore) LabSession(ctx context.Context, id int) (api.LabSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ls, ok := m.sessions[id]
+	if !ok {
+		return api.LabSession{}, ErrNotFound
+	}
+	return m.session(ls), nil
+}
+
+func (m *MemoryStore) Question(ctx context.Context, id int) (api.Question, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	q, ok := m.questions[id]
+	if !ok {
+		return api.Question{}, ErrNotFound
+	}
+	return q, nil
+}
+
+func (m *MemoryStore) Submissions(ctx context.Context, studentID, labSessionID int) ([]api.Submission, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subs := []api.Submission{}
+	for _, s := range m.submissions {
+		if s.StudentID == studentID && s.LabSessionID == labSessionID {
+			subs = append(subs, s)
+		}
+	}
+	return subs, nil
+}
+
+func (m *MemoryStore) Submission(ctx context.Context, id int) (api.Submission, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id < 1 || id > len(m.submissions) {
+		return api.Submission{}, ErrNotFound
+	}
+	return m.submissions[id-1], nil
+}
+
+func (m *MemoryStore) CreateSubmission(ctx context.Context, sub *api.Submission) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sub.ID = len(m.submissions) + 1
+	sub.SubmissionTime = m.Now().UTC().Format(api.TimeFormat)
+	m.submissions = append(m.submissions, *sub)
+	return nil
+}
+
+func (m *MemoryStore) CompleteSubmission(ctx context.Context, id int, status, resultDetails string) (api.Submission, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id < 1 || id > len(m.submissions) {
+		return api.Submission{}, ErrNotFound
+	}
+	if m.submissions[id-1].Status != api.StatusPending {
+		return api.Submission{}, ErrNotPending
+	}
+	m.submissions[id-1].Status = status
+	m.submissions[id-1].ResultDetails = resultDetails
+	return m.submissions[id-1], nil
+}
+
+func (m *MemoryStore) PendingSubmissions(ctx context.Context) ([]api.Submission, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subs := []api.Submission{}
+	for _, s := range m.submissions {
+		if s.Status == api.StatusPending {
+			subs = append(subs, s)
+		}
+	}
+	return subs, nil
+}
+
+func (m *MemoryStore) Instructor(ctx context.Context, id int) (api.Instructor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ins, ok := m.instructors[id]
+	if !ok {
+		return api.Instructor{}, ErrNotFound
+	}
+	ins.LabSessions = []api.LabSession{}
+	for _, ls := range m.sessions {
+		if ls.InstructorID == id {
+			ins.LabSessions = append(ins.LabSessions, m.session(ls))
+		}
+	}
+	sort.Slice(ins.LabSessions, func(i, j int) bool { return ins.LabSessions[i].ID < ins.LabSessions[j].ID })
+	return ins, nil
+}
+
+func (m *MemoryStore) CreateLabSession(ctx context.Context, ls *api.LabSession) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.programs[ls.ProgramID]; !ok {
+		return ErrNotFound
+	}
+	if _, ok := m.instructors[ls.InstructorID]; !ok {
+		return ErrNotFound
+	}
+	ls.ID = 1
+	for id := range m.sessions {
+		ls.ID = max(ls.ID, id+1)
+	}
+	m.sessions[ls.ID] = *ls
+	*ls = m.session(*ls)
+	return nil
+}
+
+func (m *MemoryStore) CreateQuestion(ctx context.Context, q *api.Question) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.sessions[q.LabSessionID]; !ok {
+		return ErrNotFound
+	}
+	q.ID = 1
+	for id := range m.questions {
+		q.ID = max(q.ID, id+1)
+	}
+	m.questions[q.ID] = *q
+	return nil
+}
+
+func (m *MemoryStore) UpdateTestCases(ctx context.Context, questionID int, inputsOutputs string) (api.Question, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	q, ok := m.questions[questionID]
+	if !ok {
+		return api.Question{}, ErrNotFound
+	}
+	q.InputsOutputs = inputsOutputs
+	m.questions[questionID] = q
+	return q, nil
+}
+
+func (m *MemoryStore) MoveQuestion(ctx context.Context, questionID, labSessionID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	q, ok := m.questions[questionID]
+	if !ok {
+		return ErrNotFound
+	}
+	if _, ok := m.sessions[labSessionID]; !ok {
+		return ErrNotFound
+	}
+	q.LabSessionID = labSessionID
+	m.questions[questionID] = q
+	return nil
+}
+
+func (m *MemoryStore) ProgramStudents(ctx context.Context, programID int) ([]api.Student, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	students := []api.Student{}
+	for id := range m.enrolled[programID] {
+		students = append(students, m.students[id])
+	}
+	sort.Slice(students, func(i, j int) bool { return students[i].EnrollmentNumber < students[j].EnrollmentNumber })
+	return students, nil
+}
+
+func (m *MemoryStore) LabSessionSubmissions(ctx context.Context, labSessionID int) ([]api.Submission, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subs := []api.Submission{}
+	for _, s := range m.submissions {
+		if s.LabSessionID == labSessionID {
+			student := m.students[s.StudentID]
+			s.Student = &student
+			subs = append(subs, s)
+		}
+	}
+	return subs, nil
+}
+
+func (m *MemoryStore) JudgeSubmission(ctx context.Context, id int, status, comment string) (api.Submission, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id < 1 || id > len(m.submissions) {
+		return api.Submission{}, ErrNotFound
+	}
+	m.submissions[id-1].Status = status
+	if comment != "" {
+		m.submissions[id-1].Comment = comment
+	}
+	return m.submissions[id-1], nil
+}
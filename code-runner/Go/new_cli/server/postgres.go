@@ -0,0 +1,341 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+
+	"new_cli/api"
+)
+
+// PostgresStore reads and writes the tables created by the Prisma
+// migrations in Server/prisma.
+type PostgresStore struct {
+	DB *sql.DB
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(api.TimeFormat)
+}
+
+// notFound maps a missing row, or a foreign key pointing at one, to
+// ErrNotFound.
+func notFound(err error) error {
+	var pqErr *pq.Error
+	if errors.Is(err, sql.ErrNoRows) || errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return ErrNotFound
+	}
+	return err
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func (p *PostgresStore) Student(ctx context.Context, id int) (api.Student, error) {
+	var s api.Student
+	err := p.DB.QueryRowContext(ctx,
+		`SELECT id, name, email, enrollment_number, "departmentId" FROM students WHERE id = $1`, id,
+	).Scan(&s.ID, &s.Name, &s.Email, &s.EnrollmentNumber, &s.DepartmentID)
+	return s, notFound(err)
+}
+
+const labSessionColumns = `
+	ls.id, ls.program_id, ls.instructor_id, ls.session_date, COALESCE(ls.description, ''),
+	p.id, p.name, COALESCE(p.description, ''), p.program_code, p."departmentId", p.created_at,
+	i.id, i.name, i.email, COALESCE(i."departmentId", 0)
+	FROM lab_sessions ls
+	JOIN programs p ON p.id = ls.program_id
+	JOIN instructors i ON i.id = ls.instructor_id`
+
+func scanLabSession(row interface{ Scan(...any) error }) (api.LabSession, error) {
+	var ls api.LabSession
+	var date, created time.Time
+	err := row.Scan(
+		&ls.ID, &ls.ProgramID, &ls.InstructorID, &date, &ls.Description,
+		&ls.Program.ID, &ls.Program.Name, &ls.Program.Description, &ls.Program.ProgramCode, &ls.Program.DepartmentID, &created,
+		&ls.Instructor.ID, &ls.Instructor.Name, &ls.Instructor.Email, &ls.Instructor.DepartmentID,
+	)
+	ls.SessionDate = formatTime(date)
+	ls.Program.CreatedAt = formatTime(created)
+	return ls, err
+}
+
+func (p *PostgresStore) LabSessions(ctx context.Context, studentID int, from, to time.Time) ([]api.LabSession, error) {
+	rows, err := p.DB.QueryContext(ctx, `SELECT `+labSessionColumns+`
+		WHERE ls.session_date BETWEEN $2 AND $3
+		AND EXISTS (SELECT 1 FROM "_ProgramToStudent" ps WHERE ps."A" = p.id AND ps."B" = $1)
+		ORDER BY ls.id`, studentID, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []api.LabSession{}
+	for rows.Next() {
+		ls, err := scanLabSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, ls)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range sessions {
+		if sessions[i].Questions, err = p.questions(ctx, sessions[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return sessions, nil
+}
+
+func (p *PostgresStore) LabSession(ctx context.Context, id int) (api.LabSession, error) {
+	ls, err := scanLabSession(p.DB.QueryRowContext(ctx, `SELECT `+labSessionColumns+` WHERE ls.id = $1`, id))
+	if err != nil {
+		return ls, notFound(err)
+	}
+	ls.Questions, err = p.questions(ctx, id)
+	return ls, err
+}
+
+const questionColumns = `id, instructor_id, lab_session_id, description, inputs_outputs, "testCaseBased",
+	COALESCE(checker, ''), COALESCE(interactor, '') FROM questions`
+
+func scanQuestion(row interface{ Scan(...any) error }) (api.Question, error) {
+	var q api.Question
+	err := row.Scan(&q.ID, &q.InstructorID, &q.LabSessionID, &q.Description, &q.InputsOutputs, &q.TestCaseBased, &q.Checker, &q.Interactor)
+	return q, err
+}
+
+func (p *PostgresStore) questions(ctx context.Context, labSessionID int) ([]api.Question, error) {
+	rows, err := p.DB.QueryContext(ctx, `SELECT `+questionColumns+` WHERE lab_session_id = $1 ORDER BY id`, labSessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	questions := []api.Question{}
+	for rows.Next() {
+		q, err := scanQuestion(rows)
+		if err != nil {
+			return nil, err
+		}
+		questions = append(questions, q)
+	}
+	return questions, rows.Err()
+}
+
+func (p *PostgresStore) Question(ctx context.Context, id int) (api.Question, error) {
+	q, err := scanQuestion(p.DB.QueryRowContext(ctx, `SELECT `+questionColumns+` WHERE id = $1`, id))
+	return q, notFound(err)
+}
+
+const submissionColumns = `s.id, s.student_id, s.question_id, s.lab_session_id, s.submission_time, s.status,
+	COALESCE(s."resultDetails", ''), COALESCE(s.solution, ''), COALESCE(s.comment, '')`
+
+func scanSubmission(row interface{ Scan(...any) error }, extra ...any) (api.Submission, error) {
+	var s api.Submission
+	var at time.Time
+	err := row.Scan(append([]any{&s.ID, &s.StudentID, &s.QuestionID, &s.LabSessionID, &at, &s.Status, &s.ResultDetails, &s.Solution, &s.Comment}, extra...)...)
+	s.SubmissionTime = formatTime(at)
+	return s, err
+}
+
+func (p *PostgresStore) Submissions(ctx context.Context, studentID, labSessionID int) ([]api.Submission, error) {
+	rows, err := p.DB.QueryContext(ctx, `SELECT `+submissionColumns+`
+		FROM submissions s WHERE s.student_id = $1 AND s.lab_session_id = $2 ORDER BY s.id`, studentID, labSessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []api.Submission{}
+	for rows.Next() {
+		s, err := scanSubmission(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, s)
+	}
+	return subs, rows.Err()
+}
+
+func (p *PostgresStore) Submission(ctx context.Context, id int) (api.Submission, error) {
+	s, err := scanSubmission(p.DB.QueryRowContext(ctx, `SELECT `+submissionColumns+` FROM submissions s WHERE s.id = $1`, id))
+	return s, notFound(err)
+}
+
+func (p *PostgresStore) CreateSubmission(ctx context.Context, sub *api.Submission) error {
+	var at time.Time
+	err := p.DB.QueryRowContext(ctx, `
+		INSERT INTO submissions (student_id, question_id, lab_session_id, status, "resultDetails", solution)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, submission_time`,
+		sub.StudentID, sub.QuestionID, sub.LabSessionID, sub.Status, sub.ResultDetails, sub.Solution,
+	).Scan(&sub.ID, &at)
+	if err != nil {
+		return err
+	}
+	sub.SubmissionTime = formatTime(at)
+	return nil
+}
+
+func (p *PostgresStore) CompleteSubmission(ctx context.Context, id int, status, resultDetails string) (api.Submission, error) {
+	s, err := scanSubmission(p.DB.QueryRowContext(ctx, `
+		UPDATE submissions s SET status = $2, "resultDetails" = $3 WHERE s.id = $1 AND s.status = $4
+		RETURNING `+submissionColumns, id, status, resultDetails, api.StatusPending))
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := p.Submission(ctx, id); err != nil {
+			return api.Submission{}, err
+		}
+		return api.Submission{}, ErrNotPending
+	}
+	return s, err
+}
+
+func (p *PostgresStore) PendingSubmissions(ctx context.Context) ([]api.Submission, error) {
+	rows, err := p.DB.QueryContext(ctx, `SELECT `+submissionColumns+`
+		FROM submissions s WHERE s.status = $1 ORDER BY s.id`, api.StatusPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []api.Submission{}
+	for rows.Next() {
+		s, err := scanSubmission(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, s)
+	}
+	return subs, rows.Err()
+}
+
+func (p *PostgresStore) Instructor(ctx context.Context, id int) (api.Instructor, error) {
+	var ins api.Instructor
+	err := p.DB.QueryRowContext(ctx,
+		`SELECT id, name, email, COALESCE("departmentId", 0) FROM instructors WHERE id = $1`, id,
+	).Scan(&ins.ID, &ins.Name, &ins.Email, &ins.DepartmentID)
+	if err != nil {
+		return ins, notFound(err)
+	}
+
+	rows, err := p.DB.QueryContext(ctx, `SELECT `+labSessionColumns+` WHERE ls.instructor_id = $1 ORDER BY ls.id`, id)
+	if err != nil {
+		return ins, err
+	}
+	defer rows.Close()
+
+	ins.LabSessions = []api.LabSession{}
+	for rows.Next() {
+		ls, err := scanLabSession(rows)
+		if err != nil {
+			return ins, err
+		}
+		ins.LabSessions = append(ins.LabSessions, ls)
+	}
+	return ins, rows.Err()
+}
+
+func (p *PostgresStore) CreateLabSession(ctx context.Context, ls *api.LabSession) error {
+	date, err := time.Parse(api.TimeFormat, ls.SessionDate)
+	if err != nil {
+		return err
+	}
+	err = p.DB.QueryRowContext(ctx, `
+		INSERT INTO lab_sessions (program_id, instructor_id, session_date, description)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id`,
+		ls.ProgramID, ls.InstructorID, date, nullString(ls.Description),
+	).Scan(&ls.ID)
+	return notFound(err)
+}
+
+func (p *PostgresStore) CreateQuestion(ctx context.Context, q *api.Question) error {
+	err := p.DB.QueryRowContext(ctx, `
+		INSERT INTO questions (instructor_id, lab_session_id, description, inputs_outputs, "testCaseBased", checker, interactor)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`,
+		q.InstructorID, q.LabSessionID, q.Description, q.InputsOutputs, q.TestCaseBased, nullString(q.Checker), nullString(q.Interactor),
+	).Scan(&q.ID)
+	return notFound(err)
+}
+
+func (p *PostgresStore) UpdateTestCases(ctx context.Context, questionID int, inputsOutputs string) (api.Question, error) {
+	res, err := p.DB.ExecContext(ctx, `UPDATE questions SET inputs_outputs = $2 WHERE id = $1`, questionID, inputsOutputs)
+	if err != nil {
+		return api.Question{}, err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return api.Question{}, ErrNotFound
+	}
+	return p.Question(ctx, questionID)
+}
+
+func (p *PostgresStore) MoveQuestion(ctx context.Context, questionID, labSessionID int) error {
+	res, err := p.DB.ExecContext(ctx, `UPDATE questions SET lab_session_id = $2 WHERE id = $1`, questionID, labSessionID)
+	if err != nil {
+		return notFound(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *PostgresStore) ProgramStudents(ctx context.Context, programID int) ([]api.Student, error) {
+	rows, err := p.DB.QueryContext(ctx, `
+		SELECT s.id, s.name, s.email, s.enrollment_number, s."departmentId"
+		FROM students s JOIN "_ProgramToStudent" ps ON ps."B" = s.id
+		WHERE ps."A" = $1 ORDER BY s.enrollment_number`, programID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	students := []api.Student{}
+	for rows.Next() {
+		var s api.Student
+		if err := rows.Scan(&s.ID, &s.Name, &s.Email, &s.EnrollmentNumber, &s.DepartmentID); err != nil {
+			return nil, err
+		}
+		students = append(students, s)
+	}
+	return students, rows.Err()
+}
+
+func (p *PostgresStore) LabSessionSubmissions(ctx context.Context, labSessionID int) ([]api.Submission, error) {
+	rows, err := p.DB.QueryContext(ctx, `SELECT `+submissionColumns+`,
+			st.id, st.name, st.email, st.enrollment_number, st."departmentId"
+		FROM submissions s JOIN students st ON st.id = s.student_id
+		WHERE s.lab_session_id = $1 ORDER BY s.id`, labSessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []api.Submission{}
+	for rows.Next() {
+		var st api.Student
+		s, err := scanSubmission(rows, &st.ID, &st.Name, &st.Email, &st.EnrollmentNumber, &st.DepartmentID)
+		if err != nil {
+			return nil, err
+		}
+		s.Student = &st
+		subs = append(subs, s)
+	}
+	return subs, rows.Err()
+}
+
+func (p *PostgresStore) JudgeSubmission(ctx context.Context, id int, status, comment string) (api.Submission, error) {
+	s, err := scanSubmission(p.DB.QueryRowContext(ctx, `
+		UPDATE submissions s SET status = $2, comment = COALESCE($3, s.comment) WHERE s.id = $1
+		RETURNING `+submissionColumns, id, status, nullString(comment)))
+	return s, notFound(err)
+}
@@ -0,0 +1,528 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"new_cli/api"
+	"new_cli/runner"
+	"new_cli/worker"
+)
+
+// Server implements the student (/api/stu) and instructor (/api/ins)
+// endpoints of the Lab API with the same routes and JSON shapes as the
+// Express server.
+type Server struct {
+	Store  Store
+	Broker Broker
+	Now    func() time.Time
+
+	// PollInterval is how often a student waiting for a verdict is told
+	// their place in the queue; a second if zero.
+	PollInterval time.Duration
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/stu", s.getStudent)
+	mux.HandleFunc("GET /api/stu/{$}", s.getStudent)
+	mux.HandleFunc("GET /api/stu/labsessions", s.getLabSessions)
+	mux.HandleFunc("GET /api/stu/questions", s.getQuestions)
+	mux.HandleFunc("GET /api/stu/status", s.getStatus)
+	mux.HandleFunc("POST /api/stu/submit", s.uploadSolution)
+	mux.HandleFunc("GET /api/stu/submission", s.getSubmission)
+	mux.HandleFunc("GET /api/stu/submission/events", s.streamSubmission)
+	mux.HandleFunc("POST /api/stu/submission/cancel", s.cancelSubmission)
+	s.instructorRoutes(mux)
+	return mux
+}
+
+func (s *Server) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	log.Println(err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// intParam reads a required integer query parameter, answering 400 itself
+// when it is missing or malformed.
+func intParam(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		http.Error(w, name+" is required", http.StatusBadRequest)
+		return 0, false
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		http.Error(w, name+" is not valid", http.StatusBadRequest)
+		return 0, false
+	}
+	return n, true
+}
+
+func (s *Server) getStudent(w http.ResponseWriter, r *http.Request) {
+	studentID, ok := intParam(w, r, "studentId")
+	if !ok {
+		return
+	}
+
+	student, err := s.Store.Student(r.Context(), studentID)
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "Student not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, student)
+}
+
+func (s *Server) getLabSessions(w http.ResponseWriter, r *http.Request) {
+	studentID, ok := intParam(w, r, "studentId")
+	if !ok {
+		return
+	}
+
+	from, to := dayBounds(s.now())
+	sessions, err := s.Store.LabSessions(r.Context(), studentID, from, to)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	for i := range sessions {
+		sessions[i].Questions = forStudent(sessions[i].Questions)
+	}
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+func (s *Server) getQuestions(w http.ResponseWriter, r *http.Request) {
+	studentID, ok := intParam(w, r, "studentId")
+	if !ok {
+		return
+	}
+
+	from, to := dayBounds(s.now())
+	sessions, err := s.Store.LabSessions(r.Context(), studentID, from, to)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if len(sessions) == 0 {
+		http.Error(w, "No lab session found for today or student not enrolled.", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, forStudent(sessions[0].Questions))
+}
+
+// forStudent copies questions without their checker and interactor, which
+// would show a student how their answers are judged.
+func forStudent(questions []api.Question) []api.Question {
+	hidden := make([]api.Question, len(questions))
+	for i, q := range questions {
+		q.Checker, q.Interactor = "", ""
+		hidden[i] = q
+	}
+	return hidden
+}
+
+func (s *Server) getStatus(w http.ResponseWriter, r *http.Request) {
+	studentID, ok := intParam(w, r, "studentId")
+	if !ok {
+		return
+	}
+	labSessionID, ok := intParam(w, r, "labSessionId")
+	if !ok {
+		return
+	}
+
+	session, err := s.Store.LabSession(r.Context(), labSessionID)
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "No lab session found for the given labSessionId", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	submissions, err := s.Store.Submissions(r.Context(), studentID, labSessionID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	status := map[string]string{}
+	for _, q := range session.Questions {
+		status[strconv.Itoa(q.ID)] = "Not Attempted"
+	}
+	for _, sub := range submissions {
+		// A cancelled submission leaves the question as it was
+		if sub.Status != api.StatusCancelled {
+			status[strconv.Itoa(sub.QuestionID)] = sub.Status
+		}
+	}
+
+	writeJSON(w, http.StatusOK, api.Status{
+		StudentID:    r.URL.Query().Get("studentId"),
+		LabSessionID: r.URL.Query().Get("labSessionId"),
+		Status:       status,
+	})
+}
+
+func (s *Server) uploadSolution(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid form data"})
+		return
+	}
+
+	studentIDParam := r.FormValue("studentId")
+	questionIDParam := r.FormValue("questionId")
+	if questionIDParam == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Question id is required"})
+		return
+	}
+	studentID, err := strconv.Atoi(studentIDParam)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid student id"})
+		return
+	}
+	questionID, err := strconv.Atoi(questionIDParam)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid question id"})
+		return
+	}
+
+	question, err := s.Store.Question(ctx, questionID)
+	if errors.Is(err, ErrNotFound) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid question id"})
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var solution []byte
+	file, _, err := r.FormFile("solution")
+	if err == nil {
+		solution, err = io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	// Non test case questions are judged by the instructor from the output
+	// the CLI captured while running the program
+	if !question.TestCaseBased {
+		details, _ := json.Marshal(map[string]string{"output": r.FormValue("userOutput")})
+		sub := &api.Submission{
+			StudentID:     studentID,
+			QuestionID:    questionID,
+			LabSessionID:  question.LabSessionID,
+			Status:        api.StatusPending,
+			ResultDetails: string(details),
+			Solution:      string(solution),
+		}
+		if err := s.Store.CreateSubmission(ctx, sub); err != nil {
+			writeError(w, err)
+			return
+		}
+		s.publishSubmission(ctx, *sub)
+		writeJSON(w, http.StatusOK, sub)
+		return
+	}
+
+	if solution == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "No solution file uploaded"})
+		return
+	}
+
+	// The row exists before the judge sees the submission, so the verdict
+	// has somewhere to be stored
+	sub := &api.Submission{
+		StudentID:    studentID,
+		QuestionID:   questionID,
+		LabSessionID: question.LabSessionID,
+		Status:       api.StatusPending,
+		Solution:     string(solution),
+	}
+	job, err := newJob(*sub, question)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.Store.CreateSubmission(ctx, sub); err != nil {
+		writeError(w, err)
+		return
+	}
+	s.publishSubmission(ctx, *sub)
+	job.SubmissionID = sub.ID
+
+	// Subscribe before queueing so no event can be published unheard
+	events, err := s.Broker.Subscribe(ctx, job.Channel())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.Broker.Enqueue(ctx, job); err != nil {
+		details, _ := json.Marshal(map[string]any{"end": true, "status": api.StatusFailed, "output": "Could not queue the submission for judging"})
+		s.Store.CompleteSubmission(context.Background(), sub.ID, api.StatusFailed, string(details))
+		writeError(w, err)
+		return
+	}
+
+	send := startEventStream(w)
+	send(fmt.Sprintf(`{"pushed":true,"submissionId":%d}`, sub.ID))
+	s.streamEvents(ctx, sub.ID, events, send)
+}
+
+// newJob returns what the judge is sent for sub, a submission to question.
+func newJob(sub api.Submission, question api.Question) (worker.Submission, error) {
+	var testCases []runner.TestCase
+	if err := json.Unmarshal([]byte(question.InputsOutputs), &testCases); err != nil {
+		return worker.Submission{}, fmt.Errorf("invalid test cases for question %d: %v", question.ID, err)
+	}
+	return worker.Submission{
+		SubmissionID: sub.ID,
+		StudentID:    strconv.Itoa(sub.StudentID),
+		QuestionID:   strconv.Itoa(sub.QuestionID),
+		Source:       sub.Solution,
+		TestCases:    testCases,
+		Checker:      question.Checker,
+		Interactor:   question.Interactor,
+	}, nil
+}
+
+// publishSubmission tells the instructor's monitor about a new or judged
+// submission.
+func (s *Server) publishSubmission(ctx context.Context, sub api.Submission) {
+	if data, err := json.Marshal(sub); err == nil {
+		s.Broker.Publish(ctx, strconv.Itoa(sub.LabSessionID), data)
+	}
+}
+
+type judgeEvent struct {
+	End    bool   `json:"end"`
+	Status string `json:"status"`
+}
+
+func parseEvent(message string) (judgeEvent, error) {
+	var event judgeEvent
+	err := json.Unmarshal([]byte(message), &event)
+	return event, err
+}
+
+// startEventStream answers with a server-sent event stream and returns a
+// function sending one event on it.
+func startEventStream(w http.ResponseWriter) func(data string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	return func(data string) {
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// streamEvents forwards a submission's judging events until the final one.
+// The stored submission is checked as well, as the final event may have
+// been published before the subscription started. Until the judge starts,
+// the student is also told where the submission is in the queue.
+func (s *Server) streamEvents(ctx context.Context, id int, events <-chan string, send func(string)) {
+	interval := s.PollInterval
+	if interval == 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	queued, last := true, ""
+	sendPosition := func() {
+		pos, err := s.Broker.Position(ctx, id)
+		if err != nil {
+			log.Println("Error reading queue position:", err)
+			return
+		}
+		if pos == nil {
+			queued = false
+			return
+		}
+		data, _ := json.Marshal(struct {
+			Queued   bool  `json:"queued"`
+			Position int64 `json:"position"`
+			ETA      int64 `json:"eta,omitempty"` // seconds
+		}{true, pos.Ahead + 1, int64(pos.ETA.Round(time.Second).Seconds())})
+		if string(data) != last {
+			send(string(data))
+			last = string(data)
+		}
+	}
+	sendPosition()
+
+	for {
+		select {
+		case message, ok := <-events:
+			if !ok {
+				return
+			}
+			queued = false
+			event, err := parseEvent(message)
+			if err != nil {
+				log.Println("Error parsing message:", err)
+				return
+			}
+			send(message)
+			if event.End {
+				return
+			}
+		case <-ticker.C:
+			if queued {
+				sendPosition()
+			}
+			sub, err := s.Store.Submission(ctx, id)
+			if err == nil && sub.Status != api.StatusPending {
+				send(sub.ResultDetails)
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// submission loads the submission named by the query, answering 404 itself
+// if it doesn't exist or belongs to another student.
+func (s *Server) submission(w http.ResponseWriter, r *http.Request) (api.Submission, bool) {
+	id, ok := intParam(w, r, "submissionId")
+	if !ok {
+		return api.Submission{}, false
+	}
+	studentID, ok := intParam(w, r, "studentId")
+	if !ok {
+		return api.Submission{}, false
+	}
+
+	sub, err := s.Store.Submission(r.Context(), id)
+	if errors.Is(err, ErrNotFound) || err == nil && sub.StudentID != studentID {
+		http.Error(w, "Submission not found", http.StatusNotFound)
+		return api.Submission{}, false
+	}
+	if err != nil {
+		writeError(w, err)
+		return api.Submission{}, false
+	}
+	return sub, true
+}
+
+func (s *Server) getSubmission(w http.ResponseWriter, r *http.Request) {
+	if sub, ok := s.submission(w, r); ok {
+		writeJSON(w, http.StatusOK, sub)
+	}
+}
+
+// streamSubmission lets a student reattach to the judging of a submission
+// after losing the stream uploadSolution answered with.
+func (s *Server) streamSubmission(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sub, ok := s.submission(w, r)
+	if !ok {
+		return
+	}
+	question, err := s.Store.Question(ctx, sub.QuestionID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		writeError(w, err)
+		return
+	}
+	if !question.TestCaseBased {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Submission is judged by the instructor"})
+		return
+	}
+
+	job := worker.Submission{SubmissionID: sub.ID}
+	events, err := s.Broker.Subscribe(ctx, job.Channel())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	send := startEventStream(w)
+	if sub, err = s.Store.Submission(ctx, sub.ID); err == nil && sub.Status != api.StatusPending {
+		send(sub.ResultDetails)
+		return
+	}
+	send(fmt.Sprintf(`{"attached":true,"submissionId":%d}`, sub.ID))
+	s.streamEvents(ctx, sub.ID, events, send)
+}
+
+// cancelSubmission withdraws a submission that has no verdict yet. The
+// final event is stored first, as the judge may finish the submission at
+// the same time, and only published for whoever follows it if it was.
+func (s *Server) cancelSubmission(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sub, ok := s.submission(w, r)
+	if !ok {
+		return
+	}
+	switch sub.Status {
+	case api.StatusPending:
+	case api.StatusCancelled:
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "Submission has already been cancelled"})
+		return
+	default:
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "Submission has already been judged"})
+		return
+	}
+
+	details, _ := json.Marshal(map[string]any{
+		"submissionId": sub.ID,
+		"output":       "Cancelled by the student",
+		"end":          true,
+		"status":       api.StatusCancelled,
+	})
+	sub, err := s.Store.CompleteSubmission(ctx, sub.ID, api.StatusCancelled, string(details))
+	if errors.Is(err, ErrNotPending) {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "Submission has already been judged"})
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Its verdict would be ignored now, so judging it is only wasted work
+	if err := s.Broker.Cancel(ctx, sub.ID); err != nil {
+		log.Printf("Error withdrawing submission %d from the judge: %v", sub.ID, err)
+	}
+	job := worker.Submission{SubmissionID: sub.ID}
+	if err := s.Broker.Publish(ctx, job.Channel(), details); err != nil {
+		log.Printf("Error publishing cancellation of submission %d: %v", sub.ID, err)
+	}
+	s.publishSubmission(ctx, sub)
+	writeJSON(w, http.StatusOK, sub)
+}
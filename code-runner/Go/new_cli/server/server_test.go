@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"new_cli/api"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	now := time.Date(2024, 10, 1, 9, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.AddStudent(api.Student{ID: 1, Name: "Ada"})
+	store.AddProgram(api.Program{ID: 1, Name: "DSA"}, 1)
+	store.AddInstructor(api.Instructor{ID: 1, Name: "Grace"})
+	store.AddLabSession(api.LabSession{ID: 1, ProgramID: 1, InstructorID: 1, SessionDate: now.Format(api.TimeFormat)})
+	store.AddQuestion(api.Question{
+		ID:            1,
+		InstructorID:  1,
+		LabSessionID:  1,
+		Description:   "Guess the number",
+		InputsOutputs: "[]",
+		TestCaseBased: true,
+		Checker:       "// checker source",
+		Interactor:    "// interactor source",
+	})
+
+	srv := httptest.NewServer((&Server{Store: store, Now: func() time.Time { return now }}).Handler())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func get(t *testing.T, url string) string {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: %s: %s", url, resp.Status, body)
+	}
+	return string(body)
+}
+
+// A student must never see how their answers are judged.
+func TestStudentResponsesHideJudges(t *testing.T) {
+	srv := newTestServer(t)
+	for _, path := range []string{"/api/stu/questions?studentId=1", "/api/stu/labsessions?studentId=1"} {
+		body := get(t, srv.URL+path)
+		if !strings.Contains(body, "Guess the number") {
+			t.Errorf("%s doesn't list the question: %s", path, body)
+		}
+		for _, leak := range []string{`"checker"`, `"interactor"`, "checker source", "interactor source"} {
+			if strings.Contains(body, leak) {
+				t.Errorf("%s contains %s: %s", path, leak, body)
+			}
+		}
+	}
+
+	// Instructors still get them, and the store keeps them
+	body := get(t, srv.URL+"/api/ins/question?questionId=1")
+	for _, want := range []string{"checker source", "interactor source"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("instructor question lacks %q: %s", want, body)
+		}
+	}
+}
+
+func TestUpdateTestCases(t *testing.T) {
+	srv := newTestServer(t)
+	post := func(body string) (int, string) {
+		t.Helper()
+		resp, err := http.Post(srv.URL+"/api/ins/question/testcases", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	if code, body := post(`{"questionId": 99, "testCases": []}`); code != http.StatusNotFound {
+		t.Errorf("missing question: %d %s, want 404", code, body)
+	}
+
+	// An import with no cases leaves how the question is judged alone
+	code, body := post(`{"questionId": 1, "testCases": []}`)
+	if code != http.StatusOK || !strings.Contains(body, `"inputsOutputs":"[]"`) || !strings.Contains(body, `"testCaseBased":true`) {
+		t.Errorf("empty import: %d %s, want the question still judged by test cases", code, body)
+	}
+}
+
+// racingStore judges a submission between the server reading it and
+// storing its cancellation.
+type racingStore struct {
+	*MemoryStore
+}
+
+func (r racingStore) CompleteSubmission(ctx context.Context, id int, status, resultDetails string) (api.Submission, error) {
+	r.MemoryStore.CompleteSubmission(ctx, id, api.StatusPassed, `{"end":true,"status":"passed"}`)
+	return r.MemoryStore.CompleteSubmission(ctx, id, status, resultDetails)
+}
+
+// A cancellation that loses to the verdict is refused and announced to
+// nobody.
+func TestCancelAfterVerdict(t *testing.T) {
+	ctx := context.Background()
+	store := newVerdictStore()
+	sub := &api.Submission{StudentID: 1, QuestionID: 1, LabSessionID: 1, Status: api.StatusPending}
+	if err := store.CreateSubmission(ctx, sub); err != nil {
+		t.Fatal(err)
+	}
+	srv, q := newRedisServer(t, racingStore{store})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	events := q.Redis.Subscribe(ctx, "submission:1", "1")
+	defer events.Close()
+	if _, err := events.Receive(ctx); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(ts.URL+"/api/stu/submission/cancel?submissionId=1&studentId=1", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("cancel answered %s, want 409", resp.Status)
+	}
+	if got, _ := store.Submission(ctx, sub.ID); got.Status != api.StatusPassed {
+		t.Errorf("submission %s, want passed", got.Status)
+	}
+	select {
+	case msg := <-events.Channel():
+		t.Errorf("%s told %s", msg.Channel, msg.Payload)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
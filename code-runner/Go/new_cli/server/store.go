@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"new_cli/api"
+)
+
+var (
+	ErrNotFound = errors.New("not found")
+	// ErrNotPending is returned when a verdict is stored for a submission
+	// that already has one.
+	ErrNotPending = errors.New("submission already has a verdict")
+)
+
+// Store is the data the student and instructor endpoints need.
+// PostgresStore reads the tables managed by the Prisma schema; MemoryStore
+// backs tests.
+type Store interface {
+	// Used by the student endpoints, and by the instructor endpoints too.
+
+	Student(ctx context.Context, id int) (api.Student, error)
+	// LabSessions returns the sessions between from and to for programs the
+	// student is enrolled in, with program, instructor and questions filled.
+	LabSessions(ctx context.Context, studentID int, from, to time.Time) ([]api.LabSession, error)
+	LabSession(ctx context.Context, id int) (api.LabSession, error)
+	Question(ctx context.Context, id int) (api.Question, error)
+	// Submissions returns a student's submissions in a session, oldest first.
+	Submissions(ctx context.Context, studentID, labSessionID int) ([]api.Submission, error)
+	Submission(ctx context.Context, id int) (api.Submission, error)
+	// CreateSubmission stores sub and fills in its ID and SubmissionTime.
+	CreateSubmission(ctx context.Context, sub *api.Submission) error
+	// CompleteSubmission records the judge's verdict on a pending
+	// submission. It returns ErrNotPending if the submission was judged or
+	// cancelled already, leaving it as it was.
+	CompleteSubmission(ctx context.Context, id int, status, resultDetails string) (api.Submission, error)
+	// PendingSubmissions returns every submission without a verdict, oldest
+	// first.
+	PendingSubmissions(ctx context.Context) ([]api.Submission, error)
+
+	// Used by the instructor endpoints only.
+
+	// Instructor returns an instructor with their lab sessions.
+	Instructor(ctx context.Context, id int) (api.Instructor, error)
+	// CreateLabSession stores ls and fills in its ID. It returns ErrNotFound
+	// when the program or instructor doesn't exist.
+	CreateLabSession(ctx context.Context, ls *api.LabSession) error
+	// CreateQuestion stores q and fills in its ID. It returns ErrNotFound
+	// when the lab session doesn't exist.
+	CreateQuestion(ctx context.Context, q *api.Question) error
+	// UpdateTestCases replaces a question's test cases (JSON, as in
+	// InputsOutputs). Whether it is judged by them stays as it was.
+	UpdateTestCases(ctx context.Context, questionID int, inputsOutputs string) (api.Question, error)
+	// MoveQuestion moves a question to another lab session.
+	MoveQuestion(ctx context.Context, questionID, labSessionID int) error
+	// ProgramStudents returns the students enrolled in a program, ordered by
+	// enrollment number.
+	ProgramStudents(ctx context.Context, programID int) ([]api.Student, error)
+	// LabSessionSubmissions returns every submission in a session, oldest
+	// first, with Student filled.
+	LabSessionSubmissions(ctx context.Context, labSessionID int) ([]api.Submission, error)
+	// JudgeSubmission sets a submission's status, and its comment if one is
+	// given.
+	JudgeSubmission(ctx context.Context, id int, status, comment string) (api.Submission, error)
+}
+
+// dayBounds returns the start and end of t's UTC day, matching how the
+// TypeScript controllers pick "today's" sessions.
+func dayBounds(t time.Time) (time.Time, time.Time) {
+	start := t.UTC().Truncate(24 * time.Hour)
+	return start, start.Add(24*time.Hour - time.Millisecond)
+}
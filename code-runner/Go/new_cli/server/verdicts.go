@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"new_cli/api"
+)
+
+// RecordVerdicts stores the verdicts the judge reports until ctx is done.
+// A verdict stays with the broker until it is stored, so none is lost while
+// no server is running, and it doesn't matter which server the submission
+// was uploaded to.
+func (s *Server) RecordVerdicts(ctx context.Context) {
+	for ctx.Err() == nil {
+		v, err := s.Broker.NextVerdict(ctx, 5*time.Second)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Println("Error reading verdicts:", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		if v == nil {
+			continue
+		}
+		// Left with the broker, to be tried again
+		if err := s.recordVerdict(ctx, v.SubmissionID, v.Event); err != nil {
+			log.Printf("Error saving verdict of submission %d: %v", v.SubmissionID, err)
+			continue
+		}
+		if err := s.Broker.AckVerdict(ctx, v); err != nil {
+			log.Printf("Error acknowledging verdict of submission %d: %v", v.SubmissionID, err)
+		}
+	}
+}
+
+// recordVerdict stores the final event of a submission's judging and tells
+// the instructor's monitor. It fails only if trying again could help.
+func (s *Server) recordVerdict(ctx context.Context, id int, message string) error {
+	event, err := parseEvent(message)
+	if err != nil {
+		log.Printf("Error parsing verdict of submission %d: %v", id, err)
+		return nil
+	}
+	status := event.Status
+	if status != api.StatusPassed && status != api.StatusCancelled {
+		status = api.StatusFailed
+	}
+	sub, err := s.Store.CompleteSubmission(ctx, id, status, message)
+	if errors.Is(err, ErrNotFound) {
+		log.Printf("Verdict of submission %d, which doesn't exist", id)
+		return nil
+	}
+	// Cancelled, or judged already by an earlier delivery
+	if errors.Is(err, ErrNotPending) {
+		log.Printf("Ignoring verdict of submission %d, which has one already", id)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.publishSubmission(ctx, sub)
+	return nil
+}
+
+// Requeue sends the judge the pending submissions it no longer has, such as
+// those whose verdict was lost before verdicts were kept until stored. It is
+// meant to run when the server starts.
+func (s *Server) Requeue(ctx context.Context) error {
+	pending, err := s.Store.PendingSubmissions(ctx)
+	if err != nil {
+		return err
+	}
+	known, err := s.Broker.Known(ctx)
+	if err != nil {
+		return err
+	}
+	for _, sub := range pending {
+		if known[sub.ID] {
+			continue
+		}
+		question, err := s.Store.Question(ctx, sub.QuestionID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		// The instructor judges the rest
+		if !question.TestCaseBased {
+			continue
+		}
+		job, err := newJob(sub, question)
+		if err != nil {
+			log.Printf("Not requeueing submission %d: %v", sub.ID, err)
+			continue
+		}
+		if err := s.Broker.Enqueue(ctx, job); err != nil {
+			return err
+		}
+		log.Printf("Requeued submission %d", sub.ID)
+	}
+	return nil
+}
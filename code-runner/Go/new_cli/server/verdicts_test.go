@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"new_cli/api"
+	"new_cli/worker"
+)
+
+// newRedisServer returns a server on store whose broker is backed by
+// miniredis.
+func newRedisServer(t *testing.T, store Store) (*Server, *worker.Queue) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	q := worker.NewQueue(rdb, worker.DefaultStream)
+	if err := q.Setup(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return &Server{Store: store, Broker: &RedisBroker{Redis: rdb, Queue: q, Consumer: "test"}}, q
+}
+
+func newVerdictStore() *MemoryStore {
+	store := NewMemoryStore()
+	store.AddQuestion(api.Question{ID: 1, LabSessionID: 1, TestCaseBased: true, InputsOutputs: `[{"input":"1 2","output":"3"}]`})
+	store.AddQuestion(api.Question{ID: 2, LabSessionID: 1, InputsOutputs: "[]"})
+	return store
+}
+
+// A verdict the judge reported is stored, even though no server was
+// listening when it was.
+func TestRecordVerdicts(t *testing.T) {
+	ctx := context.Background()
+	store := newVerdictStore()
+	sub := &api.Submission{StudentID: 1, QuestionID: 1, LabSessionID: 1, Status: api.StatusPending}
+	if err := store.CreateSubmission(ctx, sub); err != nil {
+		t.Fatal(err)
+	}
+	srv, q := newRedisServer(t, store)
+	event := `{"end":true,"status":"passed"}`
+	if err := q.AddVerdict(ctx, sub.ID, []byte(event)); err != nil {
+		t.Fatal(err)
+	}
+
+	recordCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	go srv.RecordVerdicts(recordCtx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, err := store.Submission(ctx, sub.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != api.StatusPending {
+			if got.Status != api.StatusPassed || got.ResultDetails != event {
+				t.Errorf("stored %s %s, want passed %s", got.Status, got.ResultDetails, event)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("verdict never stored")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for q.Redis.XLen(ctx, q.Verdicts).Val() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("verdict never acknowledged")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Pending submissions the judge has lost are queued again, once.
+func TestRequeue(t *testing.T) {
+	ctx := context.Background()
+	store := newVerdictStore()
+	for _, sub := range []api.Submission{
+		{StudentID: 1, QuestionID: 1, LabSessionID: 1, Status: api.StatusPending, Solution: "// queued"},
+		{StudentID: 1, QuestionID: 1, LabSessionID: 1, Status: api.StatusPending, Solution: "// lost"},
+		{StudentID: 1, QuestionID: 1, LabSessionID: 1, Status: api.StatusPassed, Solution: "// judged"},
+		{StudentID: 1, QuestionID: 2, LabSessionID: 1, Status: api.StatusPending, Solution: "// for the instructor"},
+	} {
+		if err := store.CreateSubmission(ctx, &sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv, q := newRedisServer(t, store)
+	if err := q.Push(ctx, worker.Submission{SubmissionID: 1, Source: "// queued"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		if err := srv.Requeue(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var queued []worker.Submission
+	for {
+		msg, err := q.Next(ctx, "a", 10*time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg == nil {
+			break
+		}
+		queued = append(queued, msg.Submission)
+	}
+	if len(queued) != 2 || queued[1].SubmissionID != 2 || queued[1].Source != "// lost" || len(queued[1].TestCases) != 1 {
+		t.Errorf("queued %+v, want 1 and then 2 with its source and test cases", queued)
+	}
+}
+
+// A second verdict, such as one the judge finished as the submission was
+// cancelled, neither replaces the first nor reaches the monitor.
+func TestRecordVerdictOnce(t *testing.T) {
+	ctx := context.Background()
+	store := newVerdictStore()
+	sub := &api.Submission{StudentID: 1, QuestionID: 1, LabSessionID: 1, Status: api.StatusPending}
+	if err := store.CreateSubmission(ctx, sub); err != nil {
+		t.Fatal(err)
+	}
+	srv, q := newRedisServer(t, store)
+	cancelled := `{"end":true,"status":"cancelled"}`
+	if err := srv.recordVerdict(ctx, sub.ID, cancelled); err != nil {
+		t.Fatal(err)
+	}
+
+	monitor := q.Redis.Subscribe(ctx, "1")
+	defer monitor.Close()
+	if _, err := monitor.Receive(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.recordVerdict(ctx, sub.ID, `{"end":true,"status":"passed"}`); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := store.Submission(ctx, sub.ID); got.Status != api.StatusCancelled || got.ResultDetails != cancelled {
+		t.Errorf("stored %s %s, want the first verdict", got.Status, got.ResultDetails)
+	}
+	select {
+	case msg := <-monitor.Channel():
+		t.Errorf("monitor told %s", msg.Payload)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+
+	"new_cli/api"
+	"new_cli/similarity"
+)
+
+func handleSimilarityCommand(args []string) {
+	if len(args) < 2 {
+		red.Println("Usage: instructor similarity <labSessionID> <questionID> [--min percent] [--show pairs] [--base template.cpp]")
+		return
+	}
+	values, err := parseFlags(args[2:], "min", "show", "base")
+	if err != nil {
+		red.Println("Error:", err)
+		return
+	}
+	minPercent, show := 50, 3
+	for name, n := range map[string]*int{"min": &minPercent, "show": &show} {
+		if values[name] == "" {
+			continue
+		}
+		if *n, err = strconv.Atoi(values[name]); err != nil || *n < 0 {
+			red.Printf("--%s must be a number.\n", name)
+			return
+		}
+	}
+	questionID, err := strconv.Atoi(args[1])
+	if err != nil {
+		red.Println("Question ID must be a number.")
+		return
+	}
+
+	var base []*similarity.Document
+	if values["base"] != "" {
+		src, err := os.ReadFile(values["base"])
+		if err != nil {
+			red.Println("Error reading base file:", err)
+			return
+		}
+		base = append(base, similarity.Fingerprint("base", string(src)))
+	}
+
+	width := 100
+	if w, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && w > 40 {
+		width = w
+	}
+	compareSolutions(args[0], questionID, base, float64(minPercent)/100, show, width)
+}
+
+// compareSolutions ranks the pairs of students whose latest solutions to a
+// question look alike, and shows the shared code of the closest pairs.
+func compareSolutions(labSessionID string, questionID int, base []*similarity.Document, minScore float64, show, width int) {
+	var submissions []api.Submission
+	if err := instructorRequest("GET", "/submissions?labSessionId="+url.QueryEscape(labSessionID), nil, &submissions); err != nil {
+		red.Println("Error fetching submissions:", err)
+		return
+	}
+
+	// Only each student's latest attempt counts; earlier ones are usually
+	// the same code with a bug in it
+	latest := map[int]api.Submission{}
+	var order []int
+	for _, sub := range submissions {
+		if sub.QuestionID != questionID || strings.TrimSpace(sub.Solution) == "" {
+			continue
+		}
+		if _, seen := latest[sub.StudentID]; !seen {
+			order = append(order, sub.StudentID)
+		}
+		if sub.ID > latest[sub.StudentID].ID {
+			latest[sub.StudentID] = sub
+		}
+	}
+	if len(order) < 2 {
+		fmt.Printf("Only %d solution to question %d in lab session %s, nothing to compare.\n", len(order), questionID, labSessionID)
+		return
+	}
+
+	sort.Slice(order, func(i, j int) bool { return solutionName(latest[order[i]]) < solutionName(latest[order[j]]) })
+	docs := make([]*similarity.Document, len(order))
+	subs := map[*similarity.Document]api.Submission{}
+	for i, studentID := range order {
+		sub := latest[studentID]
+		docs[i] = similarity.Fingerprint(solutionName(sub), sub.Solution)
+		subs[docs[i]] = sub
+	}
+
+	// With a handful of solutions, code in most of them is boilerplate
+	// rather than copying
+	opts := similarity.Options{Base: base}
+	if len(docs) >= 4 {
+		opts.MaxShare = 0.5
+	}
+	pairs := similarity.Compare(docs, opts)
+	total := len(docs) * (len(docs) - 1) / 2
+	fmt.Printf("Compared %d solutions to question %d (%d pairs).\n", len(docs), questionID, total)
+
+	var suspicious []similarity.Pair
+	for _, p := range pairs {
+		if p.Score() >= minScore {
+			suspicious = append(suspicious, p)
+		}
+	}
+	if len(suspicious) == 0 {
+		green.Printf("No pair is %.0f%% or more alike.\n", minScore*100)
+		return
+	}
+
+	bold.Printf("%4s  %5s  %s\n", "", "Score", "Pair")
+	for i, p := range suspicious {
+		line := fmt.Sprintf("%4d  %4.0f%%  %s  ↔  %s  (%d%% / %d%%)\n",
+			i+1, p.Score()*100, p.A.Name, p.B.Name, percent(p.ScoreA), percent(p.ScoreB))
+		if p.Score() >= 0.8 {
+			red.Print(line)
+		} else {
+			yellow.Print(line)
+		}
+	}
+	if below := len(pairs) - len(suspicious); below > 0 {
+		fmt.Printf("%d pairs below %.0f%% not shown.\n", below, minScore*100)
+	}
+
+	for _, p := range suspicious[:min(show, len(suspicious))] {
+		fmt.Println()
+		printSideBySide(p, subs[p.A].Solution, subs[p.B].Solution, width)
+	}
+}
+
+func solutionName(sub api.Submission) string {
+	if sub.Student == nil {
+		return fmt.Sprintf("student %d (#%d)", sub.StudentID, sub.ID)
+	}
+	return fmt.Sprintf("%s %s (#%d)", sub.Student.EnrollmentNumber, sub.Student.Name, sub.ID)
+}
+
+func percent(score float64) int {
+	return int(score*100 + 0.5)
+}
+
+// printSideBySide shows the longest shared blocks of a pair next to each
+// other, highlighted, with their line numbers.
+func printSideBySide(p similarity.Pair, srcA, srcB string, width int) {
+	const maxBlocks = 3
+	bold.Printf("%s  ↔  %s\n", p.A.Name, p.B.Name)
+
+	linesA, linesB := highlightLines(srcA), highlightLines(srcB)
+	column := (width - 3) / 2
+	for i, m := range p.Matches[:min(maxBlocks, len(p.Matches))] {
+		fmt.Printf("Block %d: lines %d-%d  ↔  lines %d-%d (%d tokens)\n", i+1, m.A.From, m.A.To, m.B.From, m.B.To, m.Tokens)
+		for row := 0; row <= max(m.A.To-m.A.From, m.B.To-m.B.From); row++ {
+			left := sourceCell(linesA, m.A, row, column)
+			right := sourceCell(linesB, m.B, row, column)
+			fmt.Println(strings.TrimRight(left+" │ "+right, " "))
+		}
+	}
+	if more := len(p.Matches) - maxBlocks; more > 0 {
+		fmt.Printf("(%d smaller blocks not shown)\n", more)
+	}
+}
+
+// sourceCell is line From+row of a block, numbered, or blank past its end.
+func sourceCell(lines [][]segment, block similarity.Lines, row, width int) string {
+	n := block.From + row
+	if n > block.To || n > len(lines) {
+		return strings.Repeat(" ", width)
+	}
+	return emptyCell.Sprintf("%4d ", n) + renderSegments(lines[n-1], width-5)
+}
@@ -0,0 +1,241 @@
+// Package similarity finds C++ solutions that share code, the way MOSS does:
+// sources are reduced to tokens with comments, layout, names and literals
+// taken out, every run of K tokens is hashed, and winnowing keeps a sample
+// of those hashes as the document's fingerprints. Two documents are as
+// similar as the share of fingerprints they have in common.
+package similarity
+
+import (
+	"hash/fnv"
+	"sort"
+
+	"new_cli/cpp"
+)
+
+const (
+	// K is the number of tokens hashed together; shorter runs match by
+	// accident too often.
+	K = 5
+	// Window is the winnowing window. Any common run of K+Window-1 tokens is
+	// guaranteed to share a fingerprint.
+	Window = 4
+)
+
+type token struct {
+	text string
+	line int
+}
+
+type fingerprint struct {
+	hash uint64
+	pos  int // index of the first token of the k-gram
+}
+
+// Document is a fingerprinted source file.
+type Document struct {
+	Name   string
+	tokens []token
+	hashes []uint64 // of every k-gram, by position
+	prints []fingerprint
+}
+
+// normalize keeps the tokens that carry structure. Identifiers and literals
+// all look the same, so renaming variables or changing constants doesn't
+// hide a copy; #include lines are the same in everyone's solution.
+func normalize(src string) []token {
+	var tokens []token
+	for _, tok := range cpp.Tokenize(src) {
+		text := tok.Text
+		switch tok.Kind {
+		case cpp.Space, cpp.Comment, cpp.Preprocessor:
+			continue
+		case cpp.Ident:
+			text = "id"
+		case cpp.Number:
+			text = "0"
+		case cpp.String:
+			text = `""`
+		case cpp.Char:
+			text = "''"
+		}
+		tokens = append(tokens, token{text, tok.Line})
+	}
+	return tokens
+}
+
+// Fingerprint tokenizes and fingerprints a C++ source.
+func Fingerprint(name, src string) *Document {
+	d := &Document{Name: name, tokens: normalize(src)}
+	for i := 0; i+K <= len(d.tokens); i++ {
+		h := fnv.New64a()
+		for _, tok := range d.tokens[i : i+K] {
+			h.Write([]byte(tok.text))
+			h.Write([]byte{0})
+		}
+		d.hashes = append(d.hashes, h.Sum64())
+	}
+	d.prints = winnow(d.hashes)
+	return d
+}
+
+// winnow selects the smallest hash of every window (the rightmost one on
+// ties), recording each selected position once.
+func winnow(hashes []uint64) []fingerprint {
+	var prints []fingerprint
+	last := -1
+	// A document shorter than a window still gets its smallest hash
+	windows := max(0, len(hashes)-Window) + min(1, len(hashes))
+	for start := 0; start < windows; start++ {
+		end := min(start+Window, len(hashes))
+		best := start
+		for i := start + 1; i < end; i++ {
+			if hashes[i] <= hashes[best] {
+				best = i
+			}
+		}
+		if best != last {
+			prints = append(prints, fingerprint{hashes[best], best})
+			last = best
+		}
+	}
+	return prints
+}
+
+// Lines is an inclusive range of 1-based source lines.
+type Lines struct {
+	From, To int
+}
+
+// Match is a run of tokens the two documents of a pair have in common.
+type Match struct {
+	A, B   Lines
+	Tokens int
+}
+
+type Pair struct {
+	A, B *Document
+	// ScoreA is the fraction of A's fingerprints that are also in B, and
+	// ScoreB the other way round.
+	ScoreA, ScoreB float64
+	Matches        []Match // longest first
+}
+
+// Score is the higher of the two scores, so that a solution copied into a
+// longer one still ranks high.
+func (p Pair) Score() float64 {
+	return max(p.ScoreA, p.ScoreB)
+}
+
+type Options struct {
+	// Base documents, such as a template handed out with the question, hold
+	// code everyone is expected to share. Their fingerprints are ignored.
+	Base []*Document
+	// MaxShare ignores fingerprints found in more than this fraction of the
+	// documents, being common boilerplate rather than copying. Zero keeps
+	// them all.
+	MaxShare float64
+}
+
+// Compare scores every pair of docs, most similar first. Pairs without a
+// fingerprint in common are left out.
+func Compare(docs []*Document, opts Options) []Pair {
+	ignored := map[uint64]bool{}
+	for _, base := range opts.Base {
+		for _, h := range base.hashes {
+			ignored[h] = true
+		}
+	}
+	if opts.MaxShare > 0 {
+		count := map[uint64]int{}
+		for _, d := range docs {
+			for h := range d.printSet(nil) {
+				count[h]++
+			}
+		}
+		for h, n := range count {
+			if float64(n) > opts.MaxShare*float64(len(docs)) {
+				ignored[h] = true
+			}
+		}
+	}
+
+	sets := make([]map[uint64]bool, len(docs))
+	for i, d := range docs {
+		sets[i] = d.printSet(ignored)
+	}
+
+	var pairs []Pair
+	for i := range docs {
+		for j := i + 1; j < len(docs); j++ {
+			shared := 0
+			for h := range sets[i] {
+				if sets[j][h] {
+					shared++
+				}
+			}
+			if shared == 0 {
+				continue
+			}
+			pairs = append(pairs, Pair{
+				A:       docs[i],
+				B:       docs[j],
+				ScoreA:  float64(shared) / float64(len(sets[i])),
+				ScoreB:  float64(shared) / float64(len(sets[j])),
+				Matches: matches(docs[i], docs[j], ignored),
+			})
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].Score() > pairs[j].Score() })
+	return pairs
+}
+
+func (d *Document) printSet(ignored map[uint64]bool) map[uint64]bool {
+	set := map[uint64]bool{}
+	for _, p := range d.prints {
+		if !ignored[p.hash] {
+			set[p.hash] = true
+		}
+	}
+	return set
+}
+
+// matches finds the common runs of at least K tokens by greedily taking
+// the longest run starting at each k-gram of a that also appears in b.
+func matches(a, b *Document, ignored map[uint64]bool) []Match {
+	positions := map[uint64][]int{}
+	for j, h := range b.hashes {
+		if !ignored[h] {
+			positions[h] = append(positions[h], j)
+		}
+	}
+	usedB := make([]bool, len(b.tokens))
+
+	var found []Match
+	for i := 0; i < len(a.hashes); {
+		bestLen, bestJ := 0, 0
+		for _, j := range positions[a.hashes[i]] {
+			n := 0
+			for i+n < len(a.tokens) && j+n < len(b.tokens) && !usedB[j+n] && a.tokens[i+n].text == b.tokens[j+n].text {
+				n++
+			}
+			if n > bestLen {
+				bestLen, bestJ = n, j
+			}
+		}
+		if bestLen < K {
+			i++
+			continue
+		}
+		for n := 0; n < bestLen; n++ {
+			usedB[bestJ+n] = true
+		}
+		found = append(found, Match{
+			A:      Lines{a.tokens[i].line, a.tokens[i+bestLen-1].line},
+			B:      Lines{b.tokens[bestJ].line, b.tokens[bestJ+bestLen-1].line},
+			Tokens: bestLen,
+		})
+		i += bestLen
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Tokens > found[j].Tokens })
+	return found
+}
@@ -0,0 +1,124 @@
+package similarity
+
+import (
+	"fmt"
+	"testing"
+)
+
+const original = `#include <iostream>
+using namespace std;
+
+int main() {
+	int n;
+	cin >> n;
+	long long total = 0;
+	for (int i = 1; i <= n; i++) {
+		if (i % 3 == 0 || i % 5 == 0) {
+			total += i;
+		}
+	}
+	cout << total << endl;
+	return 0;
+}
+`
+
+// The same program with names, literals, comments and layout changed.
+const disguised = `#include <bits/stdc++.h>
+using namespace std;
+// my own work
+int main()
+{
+    int count; cin >> count;
+    long long answer = 0;   /* running sum */
+    for (int k = 1; k <= count; k++)
+    {
+        if (k % 7 == 0 || k % 11 == 0) { answer += k; }
+    }
+    cout << answer << endl;
+    return 0;
+}
+`
+
+const different = `#include <iostream>
+#include <string>
+using namespace std;
+
+int main() {
+	string s;
+	getline(cin, s);
+	string r(s.rbegin(), s.rend());
+	cout << (s == r ? "Palindrome" : "Not Palindrome") << "\n";
+}
+`
+
+func TestCompare(t *testing.T) {
+	docs := []*Document{
+		Fingerprint("original", original),
+		Fingerprint("disguised", disguised),
+		Fingerprint("different", different),
+	}
+	pairs := Compare(docs, Options{})
+	if len(pairs) == 0 {
+		t.Fatal("no pairs")
+	}
+
+	top := pairs[0]
+	if top.A.Name != "original" || top.B.Name != "disguised" || top.ScoreA != 1 || top.ScoreB != 1 {
+		t.Errorf("top pair %s/%s scored %.2f/%.2f, want original/disguised at 1/1", top.A.Name, top.B.Name, top.ScoreA, top.ScoreB)
+	}
+	if got := fmt.Sprint(top.Matches); got != "[{{2 15} {2 14} 68}]" {
+		t.Errorf("matches = %s", got)
+	}
+	for _, p := range pairs[1:] {
+		if p.Score() > 0.5 {
+			t.Errorf("%s/%s scored %.2f, want unrelated programs below 0.5", p.A.Name, p.B.Name, p.Score())
+		}
+	}
+}
+
+func TestCompareIgnoresBase(t *testing.T) {
+	template := "#include <iostream>\nusing namespace std;\n\nint main() {\n\tint n;\n\tcin >> n;\n"
+	docs := []*Document{Fingerprint("a", original), Fingerprint("b", disguised)}
+
+	pairs := Compare(docs, Options{Base: []*Document{Fingerprint("template", template)}})
+	if len(pairs) != 1 || pairs[0].Score() != 1 {
+		t.Fatalf("with a base: %+v", pairs)
+	}
+	// The template's lines are no longer part of any match
+	if m := pairs[0].Matches[0]; m.A.From < 6 || m.Tokens >= 68 {
+		t.Errorf("match %+v still covers the template", m)
+	}
+}
+
+func TestCompareMaxShare(t *testing.T) {
+	// Everyone wrote the same short program; only the shared extra loop
+	// between two of them is suspicious
+	common := "int main() { int a, b; cin >> a >> b; cout << a + b << endl; }\n"
+	extra := "void f() { for (int i = 0; i < 10; i++) { while (i > 0) { i--; } } }\n"
+	docs := []*Document{
+		Fingerprint("a", common+extra),
+		Fingerprint("b", common+extra),
+		Fingerprint("c", common),
+		Fingerprint("d", common),
+		Fingerprint("e", common),
+	}
+	pairs := Compare(docs, Options{MaxShare: 0.5})
+	if len(pairs) != 1 || pairs[0].A.Name != "a" || pairs[0].B.Name != "b" {
+		for _, p := range pairs {
+			t.Logf("%s/%s %.2f", p.A.Name, p.B.Name, p.Score())
+		}
+		t.Fatalf("got %d pairs, want only a/b", len(pairs))
+	}
+}
+
+func TestShortDocuments(t *testing.T) {
+	for _, src := range []string{"", "int x;", "// only a comment\n"} {
+		d := Fingerprint("short", src)
+		if len(d.prints) > 1 {
+			t.Errorf("%q has %d fingerprints", src, len(d.prints))
+		}
+	}
+	if pairs := Compare([]*Document{Fingerprint("a", ""), Fingerprint("b", "")}, Options{}); len(pairs) != 0 {
+		t.Errorf("empty documents paired: %+v", pairs)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"new_cli/api"
+)
+
+func TestSimilarity(t *testing.T) {
+	fake := instructorFake(t)
+	fake.Store.AddStudent(api.Student{ID: 3, Name: "Third Student", EnrollmentNumber: "EN003"})
+	fake.Store.AddStudent(api.Student{ID: 4, Name: "Fourth Student", EnrollmentNumber: "EN004"})
+
+	sum := "#include <iostream>\nusing namespace std;\n\nint main() {\n\tint a, b;\n\tcin >> a >> b;\n\tlong long total = a;\n\tfor (int i = 0; i < b; i++) {\n\t\ttotal += 1;\n\t}\n\tcout << total << endl;\n}\n"
+	renamed := "#include <bits/stdc++.h>\nusing namespace std;\n// written by me\nint main()\n{\n    int x, y; cin >> x >> y;\n    long long s = x;\n    for (int k = 0; k < y; k++) { s += 1; }\n    cout << s << endl;\n}\n"
+	direct := "#include <iostream>\n\nint main() {\n\tint a, b;\n\tstd::cin >> a >> b;\n\tstd::cout << a + b << '\\n';\n\treturn 0;\n}\n"
+	seed := []api.Submission{
+		{StudentID: 1, Solution: sum},
+		{StudentID: 4, Solution: sum},
+		{StudentID: 2, Solution: renamed},
+		{StudentID: 3, Solution: direct},
+		{StudentID: 4, Solution: direct + "// changed my mind\n"},
+		{StudentID: 3, QuestionID: 2, Solution: sum},
+	}
+	for i := range seed {
+		seed[i].LabSessionID, seed[i].Status = 1, api.StatusPassed
+		if seed[i].QuestionID == 0 {
+			seed[i].QuestionID = 1
+		}
+		if err := fake.Store.CreateSubmission(context.Background(), &seed[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	template := filepath.Join(t.TempDir(), "template.cpp")
+	if err := os.WriteFile(template, []byte("#include <iostream>\n\nint main() {\n\tint a, b;\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := capture(t, func() {
+		instructor("similarity 1 1")
+		instructor("similarity 1 1 --min 101")
+		instructor("similarity 1 1 --show 0 --min 0 --base " + template)
+		instructor("similarity 1 2")
+		instructor("similarity 1 x")
+		instructor("similarity 1 1 --min lots")
+	})
+	checkGolden(t, "similarity", strings.ReplaceAll(out, template, "TEMPLATE"))
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+
+	"new_cli/api"
+	"new_cli/runner"
+)
+
+// handleStreamedResponse prints the judging events of a submission. It
+// returns the submission's ID, if the server sent one, and whether the
+// verdict arrived before the stream ended. Ctrl-C offers to cancel the
+// submission.
+func handleStreamedResponse(body io.Reader) (int, bool) {
+	stop := make(chan struct{})
+	defer close(stop)
+	lines, readErr := readLines(body, stop)
+	sigs, release := catchInterrupts()
+	defer release()
+
+	submissionID := 0
+	status := &statusLine{live: term.IsTerminal(int(os.Stdout.Fd()))}
+	defer status.done()
+	bar := &progressBar{line: status}
+	for {
+		var line string
+		select {
+		case line = <-lines:
+		case err := <-readErr:
+			status.done()
+			if err != io.EOF {
+				red.Println("Error reading response:", err)
+			}
+			return submissionID, false
+		case <-sigs:
+			status.done()
+			if submissionID == 0 {
+				return submissionID, false
+			}
+			if confirm(fmt.Sprintf("Cancel submission %d?", submissionID)) {
+				// The stream ends with the cancellation
+				cancelSubmission(strconv.Itoa(submissionID))
+			}
+			continue
+		}
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		data := strings.TrimPrefix(line, "data: ")
+		var event struct {
+			SubmissionID int    `json:"submissionId"`
+			End          bool   `json:"end"`
+			Status       string `json:"status"`
+			queuePosition
+			testProgress
+		}
+		json.Unmarshal([]byte(data), &event)
+		if event.SubmissionID != 0 {
+			// Events of other submissions have no business here
+			if submissionID != 0 && event.SubmissionID != submissionID {
+				continue
+			}
+			submissionID = event.SubmissionID
+		}
+		if event.Queued {
+			status.show(event.queuePosition.String(), true)
+			continue
+		}
+		if event.Test > 0 {
+			bar.update(event.testProgress)
+			continue
+		}
+		status.done()
+		printEvent(data, submissionID)
+		if event.End {
+			if event.Status != api.StatusCancelled {
+				rememberVerdict(data)
+			}
+			return submissionID, true
+		}
+	}
+}
+
+// readLines reads body line by line in the background, until it fails or
+// stop is closed.
+func readLines(body io.Reader, stop <-chan struct{}) (<-chan string, <-chan error) {
+	lines, errs := make(chan string), make(chan error, 1)
+	go func() {
+		reader := bufio.NewReader(body)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case lines <- line:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return lines, errs
+}
+
+func printEvent(data string, submissionID int) {
+	if strings.Contains(data, "pushed") {
+		if submissionID != 0 {
+			fmt.Printf("Request sent for execution as submission %d\n", submissionID)
+		} else {
+			fmt.Println("Request sent for execution")
+		}
+	} else if strings.Contains(data, "attached") {
+		fmt.Printf("Waiting for the verdict on submission %d\n", submissionID)
+	} else if strings.Contains(data, "start") {
+		fmt.Println("Worker has picked up the request")
+	} else if strings.Contains(data, `"status":"cancelled"`) {
+		yellow.Printf("Submission %d was cancelled.\n", submissionID)
+	} else if strings.Contains(data, `"diagnostics"`) {
+		var event struct {
+			Diagnostics []runner.Diagnostic `json:"diagnostics"`
+		}
+		json.Unmarshal([]byte(data), &event)
+		red.Println("Compilation failed:")
+		printDiagnostics(event.Diagnostics)
+	} else if strings.Contains(data, "status") {
+		fmt.Println("Compilation result:")
+		fmt.Println(data)
+	} else if strings.Contains(data, "passed") {
+		fmt.Println("Execution completed:")
+		fmt.Println(data)
+	}
+}
+
+// queuePosition is the event the server sends while a submission waits for
+// a worker.
+type queuePosition struct {
+	Queued   bool  `json:"queued"`
+	Position int64 `json:"position"`
+	ETA      int64 `json:"eta"` // seconds, 0 if unknown
+}
+
+func (q queuePosition) String() string {
+	text := fmt.Sprintf("Waiting for the judge: position %d", q.Position)
+	switch {
+	case q.ETA == 0:
+	case q.ETA < 60:
+		text += fmt.Sprintf(", ~%ds", q.ETA)
+	default:
+		text += fmt.Sprintf(", ~%d min", (q.ETA+30)/60)
+	}
+	return text
+}
+
+// testProgress is the event the judge publishes after each test case.
+type testProgress struct {
+	Test    int            `json:"test"` // from 1
+	Total   int            `json:"total"`
+	Verdict runner.Verdict `json:"verdict"`
+	Time    int64          `json:"time"`
+}
+
+// progressBar shows how far judging has got.
+type progressBar struct {
+	line           *statusLine
+	passed, failed int
+}
+
+func (b *progressBar) update(p testProgress) {
+	mark := green.Sprint("✓")
+	if p.Verdict == runner.Accepted {
+		b.passed++
+	} else {
+		b.failed++
+		mark = red.Sprintf("✗ %s", p.Verdict)
+	}
+
+	const width = 20
+	filled := 0
+	if p.Total > 0 {
+		filled = min(width, width*p.Test/p.Total)
+	}
+	b.line.show(fmt.Sprintf("Test %d/%d %s  %s%s  %d passed, %d failed  (%d ms)",
+		p.Test, p.Total, mark, strings.Repeat("█", filled), strings.Repeat("░", width-filled), b.passed, b.failed, p.Time), false)
+}
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// statusLine is a line that keeps changing while a submission is judged.
+// On a terminal it is redrawn in place, with a spinner in front if asked;
+// otherwise every change gets a line of its own.
+type statusLine struct {
+	live bool
+
+	mu    sync.Mutex
+	text  string
+	open  bool // on screen and in need of a newline
+	frame int
+	stop  chan struct{}
+}
+
+func (l *statusLine) show(text string, spin bool) {
+	if !l.live {
+		fmt.Println(text)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.text = text
+	if spin && l.stop == nil {
+		l.stop = make(chan struct{})
+		go l.spin(l.stop)
+	} else if !spin && l.stop != nil {
+		close(l.stop)
+		l.stop = nil
+	}
+	l.draw(spin)
+}
+
+// draw writes the line again. l.mu must be held.
+func (l *statusLine) draw(spin bool) {
+	prefix := ""
+	if spin {
+		prefix = spinnerFrames[l.frame%len(spinnerFrames)] + " "
+	}
+	fmt.Print("\r\x1b[K" + prefix + l.text)
+	l.open = true
+}
+
+func (l *statusLine) spin(stop chan struct{}) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			l.mu.Lock()
+			select {
+			case <-stop:
+			default:
+				l.frame++
+				l.draw(true)
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+// done ends the line, so what is printed next starts on a fresh one.
+func (l *statusLine) done() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stop != nil {
+		close(l.stop)
+		l.stop = nil
+	}
+	if l.open {
+		fmt.Println()
+		l.open = false
+	}
+}
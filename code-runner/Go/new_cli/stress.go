@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+
+	"new_cli/runner"
+	"new_cli/testcases"
+)
+
+func printStressUsage() {
+	red.Println("Usage: stress <solution.cpp> <brute.cpp> <gen.cpp> [--iterations N] [--seed S]")
+}
+
+// handleStressCommand runs a solution and a brute force on generated inputs
+// until they disagree, then shows the smallest input it can find that they
+// still disagree on.
+func handleStressCommand(args []string) {
+	var files []string
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		files, args = append(files, args[0]), args[1:]
+	}
+	fs := flag.NewFlagSet("stress", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	iterations := fs.Int("iterations", 100, "number of inputs to try")
+	firstSeed := fs.Int("seed", 1, "seed of the first input")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 || len(files) != 3 || *iterations < 1 || *firstSeed < 0 {
+		printStressUsage()
+		return
+	}
+	for _, file := range files {
+		if !strings.HasSuffix(file, ".cpp") {
+			red.Println("Error:", file, "must have a .cpp extension")
+			return
+		}
+	}
+
+	stress := &testcases.Stress{Limits: runner.DefaultLimits, BruteLimits: runner.DefaultLimits}
+	stress.BruteLimits.Time = 10 * time.Second
+	roles := []struct {
+		name string
+		exe  *string
+	}{{"solution", &stress.Solution}, {"brute force", &stress.Brute}, {"generator", &stress.Generator}}
+	for i, role := range roles {
+		execPath, err := buildSource(files[i])
+		if err != nil {
+			red.Printf("Error compiling %s: %v\n", role.name, err)
+			return
+		}
+		*role.exe = execPath
+	}
+
+	ws, err := runner.NewWorkspace("stress")
+	if err != nil {
+		red.Println("Error creating workspace:", err)
+		return
+	}
+	defer ws.Close()
+	stress.Dir = ws.Dir
+
+	// Ctrl-C stops early, keeping what was found so far
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigs, release := catchInterrupts()
+	defer release()
+	go func() {
+		select {
+		case <-sigs:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	status := &statusLine{live: term.IsTerminal(int(os.Stdout.Fd()))}
+	defer status.done()
+	var mismatch *testcases.Mismatch
+	tested := 0
+	for tested < *iterations {
+		// Every line would be too much when not on a terminal
+		if status.live {
+			status.show(fmt.Sprintf("Test %d/%d", tested+1, *iterations), true)
+		}
+		mismatch, err = stress.Test(ctx, strconv.Itoa(*firstSeed+tested))
+		if ctx.Err() != nil {
+			// A program cut short says nothing
+			mismatch = nil
+			break
+		}
+		if err != nil {
+			status.done()
+			red.Println("Error:", err)
+			return
+		}
+		tested++
+		if mismatch != nil {
+			break
+		}
+	}
+	status.done()
+
+	if mismatch == nil {
+		if ctx.Err() != nil {
+			yellow.Printf("Stopped after %d tests; the solution agreed with the brute force on all of them.\n", tested)
+			return
+		}
+		green.Printf("All %d tests passed: the solution agrees with the brute force.\n", tested)
+		return
+	}
+
+	red.Printf("Test %d (seed %s) failed: %s\n", tested, mismatch.Seed, mismatch.Result.Reason)
+	if status.live {
+		status.show("Shrinking the input", true)
+	}
+	shrunk := stress.Shrink(ctx, mismatch)
+	status.done()
+	printMismatch(mismatch, shrunk)
+}
+
+// printMismatch shows the input a solution failed on, shrunk if that
+// helped, and how its output differs from the brute force's.
+func printMismatch(original, shrunk *testcases.Mismatch) {
+	lines := func(s string) int { return strings.Count(strings.TrimSuffix(s, "\n"), "\n") + 1 }
+	if shrunk.Input == original.Input {
+		bold.Println("Input:")
+	} else {
+		bold.Printf("Input, shrunk from %d lines and %d bytes:\n", lines(original.Input), len(original.Input))
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(shrunk.Input, "\n"), "\n") {
+		fmt.Println("  " + line)
+	}
+
+	result := shrunk.Result
+	switch result.Verdict {
+	case runner.WrongAnswer, runner.PresentationError:
+		printComparison(shrunk.Input, result.Output, result.Expected)
+	default:
+		red.Println(result.Reason)
+		yellow.Println("Save the input to a file and replay it with: run <file> --input <file> --debug")
+	}
+	if shrunk.Input != original.Input {
+		yellow.Printf("Shrinking leaves out lines and numbers, so check the input is still valid; seed %s gives the original.\n", original.Seed)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStress(t *testing.T) {
+	if _, err := exec.LookPath("g++"); err != nil {
+		t.Skip("g++ not installed")
+	}
+	newFake(t)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	dir := t.TempDir()
+	files := map[string]string{
+		// The largest of a few numbers; max starts at 0, which is wrong when
+		// they are all negative
+		"max.cpp":   "#include <iostream>\nint main() { int x, m = 0; while (std::cin >> x) if (x > m) m = x; std::cout << m << \"\\n\"; }\n",
+		"brute.cpp": "#include <iostream>\n#include <climits>\nint main() { int x, m = INT_MIN; while (std::cin >> x) if (x > m) m = x; std::cout << m << \"\\n\"; }\n",
+		"gen.cpp": "#include <cstdio>\n#include <cstdlib>\nint main(int argc, char **argv) {\n" +
+			"  unsigned s = atoi(argv[1]);\n  int n = 3 + s % 3;\n" +
+			"  for (int i = 0; i < n; i++) { s = s * 1103515245 + 12345; printf(\"%d\\n\", (int)((s >> 16) % 13) - 9); }\n}\n",
+		"crash.cpp": "int main() { return 1; }\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := capture(t, func() {
+		handleCommand("stress " + dir + "/max.cpp " + dir + "/brute.cpp " + dir + "/gen.cpp")
+		handleCommand("stress " + dir + "/brute.cpp " + dir + "/brute.cpp " + dir + "/gen.cpp --iterations 5")
+		handleCommand("stress " + dir + "/max.cpp " + dir + "/crash.cpp " + dir + "/gen.cpp")
+		handleCommand("stress " + dir + "/max.cpp " + dir + "/brute.cpp")
+		handleCommand("stress " + dir + "/max.cpp " + dir + "/brute.cpp " + dir + "/gen.cpp --iterations 0")
+	})
+	out = strings.ReplaceAll(out, dir+"/", "")
+	checkGolden(t, "stress", out)
+}
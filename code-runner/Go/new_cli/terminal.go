@@ -0,0 +1,299 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/creack/pty"
+	"golang.org/x/term"
+
+	"new_cli/runner"
+)
+
+// abortKey ends the running program from the keyboard. Ctrl-C is passed
+// through to the program, the same as in a normal terminal.
+const (
+	abortKey     = 0x1d
+	abortKeyName = "Ctrl-]"
+)
+
+// ptySession is a program running on a pty that is bridged to the user's
+// terminal. While a session is active, signals sent to the CLI are forwarded
+// to the program instead of killing the CLI.
+type ptySession struct {
+	cmd     *exec.Cmd
+	ptmx    *os.File
+	aborted atomic.Bool
+	exiting atomic.Bool
+}
+
+var (
+	sessionMu     sync.Mutex
+	activeSession *ptySession
+	restoreTerm   func()
+	interrupts    chan os.Signal // set by catchInterrupts
+)
+
+// installSignalHandler routes SIGINT, SIGTERM and SIGWINCH. Outside a run,
+// and unless Ctrl-C is being caught, the CLI exits, after restoring the
+// terminal and removing workspaces that deferred cleanups would otherwise
+// leave behind.
+func installSignalHandler() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM, syscall.SIGWINCH)
+	go func() {
+		for sig := range sigs {
+			sessionMu.Lock()
+			s := activeSession
+			sessionMu.Unlock()
+
+			if s != nil {
+				s.forward(sig)
+				continue
+			}
+			sessionMu.Lock()
+			caught := interrupts
+			sessionMu.Unlock()
+			if caught != nil && sig == os.Interrupt {
+				select {
+				case caught <- sig:
+				default:
+				}
+				continue
+			}
+			if sig != syscall.SIGWINCH {
+				exitCleanly(130)
+			}
+		}
+	}()
+}
+
+// catchInterrupts delivers Ctrl-C on the returned channel instead of
+// exiting, until release is called.
+func catchInterrupts() (sigs <-chan os.Signal, release func()) {
+	ch := make(chan os.Signal, 1)
+	sessionMu.Lock()
+	interrupts = ch
+	sessionMu.Unlock()
+	return ch, func() {
+		sessionMu.Lock()
+		interrupts = nil
+		sessionMu.Unlock()
+	}
+}
+
+func exitCleanly(code int) {
+	sessionMu.Lock()
+	restore := restoreTerm
+	sessionMu.Unlock()
+	if restore != nil {
+		restore()
+	}
+	runner.CleanupWorkspaces()
+	os.Exit(code)
+}
+
+func (s *ptySession) forward(sig os.Signal) {
+	switch sig {
+	case syscall.SIGWINCH:
+		s.resize()
+	case syscall.SIGTERM:
+		// Let the program go first, then leave once the terminal is back.
+		s.exiting.Store(true)
+		s.signal(syscall.SIGTERM)
+	default:
+		s.signal(sig.(syscall.Signal))
+	}
+}
+
+// signal delivers sig to the whole process group of the program.
+func (s *ptySession) signal(sig syscall.Signal) {
+	syscall.Kill(-s.cmd.Process.Pid, sig)
+}
+
+func (s *ptySession) resize() {
+	pty.InheritSize(os.Stdin, s.ptmx)
+}
+
+// copyInput forwards keystrokes to the program until the reader is
+// cancelled, stopping the program if the abort key is pressed.
+func (s *ptySession) copyInput(in io.Reader) {
+	buf := make([]byte, 1024)
+	for {
+		n, err := in.Read(buf)
+		for i := 0; i < n; i++ {
+			if buf[i] == abortKey {
+				s.ptmx.Write(buf[:i])
+				s.aborted.Store(true)
+				s.signal(syscall.SIGKILL)
+				return
+			}
+		}
+		if n > 0 {
+			if _, werr := s.ptmx.Write(buf[:n]); werr != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// cancelableStdin reads the terminal through a non-blocking duplicate of
+// stdin, so a pending read can be interrupted when the program ends instead
+// of swallowing the next line typed at the prompt.
+type cancelableStdin struct {
+	fd int
+	f  *os.File
+}
+
+func newCancelableStdin() (*cancelableStdin, error) {
+	fd, err := syscall.Dup(int(os.Stdin.Fd()))
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	return &cancelableStdin{fd: fd, f: os.NewFile(uintptr(fd), "stdin")}, nil
+}
+
+func (c *cancelableStdin) Read(p []byte) (int, error) {
+	return c.f.Read(p)
+}
+
+func (c *cancelableStdin) Cancel() {
+	c.f.SetReadDeadline(time.Now())
+}
+
+// Close puts stdin back into blocking mode; the flag is shared with
+// os.Stdin, which the REPL keeps reading from.
+func (c *cancelableStdin) Close() {
+	syscall.SetNonblock(c.fd, false)
+	c.f.Close()
+}
+
+// enterRawMode puts the terminal in raw mode and returns a cancelable reader
+// for it and a function that undoes it, which exitCleanly also calls if the
+// CLI is killed first. onRestore, if set, runs just before the terminal is
+// restored, e.g. to leave the alternate screen.
+func enterRawMode(onRestore func()) (*cancelableStdin, func(), error) {
+	stdinFd := int(os.Stdin.Fd())
+	oldState, err := term.MakeRaw(stdinFd)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error setting raw mode: %v", err)
+	}
+
+	stdin, err := newCancelableStdin()
+	if err != nil {
+		term.Restore(stdinFd, oldState)
+		return nil, nil, fmt.Errorf("error opening terminal input: %v", err)
+	}
+
+	restore := sync.OnceFunc(func() {
+		if onRestore != nil {
+			onRestore()
+		}
+		stdin.Close()
+		if err := term.Restore(stdinFd, oldState); err != nil {
+			fmt.Printf("Warning: Failed to restore terminal state: %v\n", err)
+		}
+	})
+	sessionMu.Lock()
+	restoreTerm = restore
+	sessionMu.Unlock()
+
+	return stdin, func() {
+		sessionMu.Lock()
+		restoreTerm = nil
+		sessionMu.Unlock()
+		restore()
+	}, nil
+}
+
+// runInPty starts cmd on a pty wired to the terminal, copying its output to
+// output as well. It returns once the program exits, is aborted with the
+// abort key, or exceeds timeout, with the terminal restored in every case.
+func runInPty(cmd *exec.Cmd, output io.Writer, timeout time.Duration) error {
+	stdin, restore, err := enterRawMode(nil)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	ptmx, err := pty.Start(cmd)
+	if err != nil {
+		return fmt.Errorf("error starting pty: %v", err)
+	}
+	defer ptmx.Close()
+
+	s := &ptySession{cmd: cmd, ptmx: ptmx}
+	s.resize()
+	sessionMu.Lock()
+	activeSession = s
+	sessionMu.Unlock()
+	defer func() {
+		sessionMu.Lock()
+		activeSession = nil
+		sessionMu.Unlock()
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		io.Copy(output, ptmx)
+	}()
+	go func() {
+		defer wg.Done()
+		s.copyInput(stdin)
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var runErr error
+	select {
+	case err := <-done:
+		if err != nil {
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				runErr = fmt.Errorf("program exited with code %d", exitErr.ExitCode())
+				if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+					runErr = crashError{ws.Signal()}
+				}
+			} else {
+				runErr = fmt.Errorf("error waiting for program: %v", err)
+			}
+		}
+	case <-time.After(timeout):
+		runErr = fmt.Errorf("program execution timed out")
+		s.signal(syscall.SIGKILL)
+		<-done
+	}
+	if s.aborted.Load() {
+		runErr = fmt.Errorf("program aborted with %s", abortKeyName)
+	}
+
+	// The program is gone; make sure nothing it forked keeps the pty open,
+	// stop waiting for keystrokes and drain its output
+	s.signal(syscall.SIGKILL)
+	stdin.Cancel()
+	wg.Wait()
+
+	if s.exiting.Load() {
+		restore()
+		exitCleanly(143)
+	}
+	return runErr
+}
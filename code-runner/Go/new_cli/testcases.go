@@ -0,0 +1,416 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"new_cli/api"
+	"new_cli/runner"
+	"new_cli/testcases"
+)
+
+func handleTestCasesCommand(args []string) {
+	switch {
+	case len(args) >= 2 && args[0] == "check":
+		checkTestCases(args[1], args[2:])
+	case len(args) >= 3 && args[0] == "import":
+		importTestCases(args[1], args[2], args[3:])
+	case len(args) == 3 && args[0] == "export":
+		exportTestCases(args[1], args[2])
+	case len(args) >= 2 && args[0] == "generate":
+		generateTestCases(args[1], args[2:])
+	case len(args) >= 2 && args[0] == "verify":
+		verifyTestCases(args[1], args[2:])
+	default:
+		red.Println("Usage: instructor testcases check <dir|zip> [--solution ref.cpp] [--checker file.cpp]")
+		red.Println("       instructor testcases import <questionID> <dir|zip> [--solution ref.cpp] [--checker file.cpp] [--time-limit ms]")
+		red.Println("       instructor testcases export <questionID> <dir|file.zip>")
+		red.Println("       instructor testcases generate <dir|zip> --solution ref.cpp (--generator gen.cpp [--seeds 1-10] | --inputs dir) [--time-limit ms]")
+		red.Println("       instructor testcases verify <questionID> --solution ref.cpp [--checker file.cpp] [--fix]")
+	}
+}
+
+// parseFlags reads "--name value" pairs, accepting only the given names.
+func parseFlags(flags []string, names ...string) (map[string]string, error) {
+	values := map[string]string{}
+	for i := 0; i < len(flags); i += 2 {
+		known := false
+		for _, name := range names {
+			known = known || flags[i] == "--"+name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown option: %s", flags[i])
+		}
+		if i+1 == len(flags) {
+			return nil, fmt.Errorf("missing value for %s", flags[i])
+		}
+		values[flags[i][2:]] = flags[i+1]
+	}
+	return values, nil
+}
+
+// takeFlag removes a flag without a value, such as --fix, from flags and
+// reports whether it was there.
+func takeFlag(flags []string, name string) (bool, []string) {
+	for i, flag := range flags {
+		if flag == "--"+name {
+			return true, append(flags[:i:i], flags[i+1:]...)
+		}
+	}
+	return false, flags
+}
+
+// parseTimeLimit reads --time-limit, 0 when it isn't given.
+func parseTimeLimit(values map[string]string) (int, error) {
+	if values["time-limit"] == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(values["time-limit"])
+	if err != nil || limit <= 0 {
+		return 0, fmt.Errorf("time limit must be a positive number of milliseconds")
+	}
+	return limit, nil
+}
+
+// loadTestCases reads a test case package and reports what was found.
+func loadTestCases(path string, timeLimit int) (*testcases.Package, bool) {
+	pkg, err := testcases.Load(path)
+	if err != nil {
+		red.Println("Error reading test cases:", err)
+		return nil, false
+	}
+	if timeLimit > 0 {
+		for i := range pkg.Cases {
+			pkg.Cases[i].TimeLimit = timeLimit
+		}
+	}
+
+	fmt.Printf("Read %d test cases (%s format)", len(pkg.Cases), pkg.Format)
+	if limit := pkg.Cases[0].TimeLimit; limit > 0 {
+		fmt.Printf(", time limit %d ms", limit)
+	}
+	fmt.Println(".")
+	return pkg, true
+}
+
+func checkTestCases(path string, flags []string) {
+	values, err := parseFlags(flags, "solution", "checker")
+	if err != nil {
+		red.Println("Error:", err)
+		return
+	}
+	pkg, ok := loadTestCases(path, 0)
+	if !ok {
+		return
+	}
+
+	if values["solution"] == "" {
+		for _, c := range pkg.Cases {
+			fmt.Printf("  %-8s %6d bytes in  %6d bytes out\n", c.Name, len(c.Input), len(c.Output))
+		}
+		return
+	}
+	validateTestCases(pkg, values["solution"], values["checker"])
+}
+
+// validateTestCases runs a reference solution on every case and reports the
+// ones it doesn't pass, which usually means a wrong answer file. It returns
+// the result of every case, or nil if the programs don't compile.
+func validateTestCases(pkg *testcases.Package, solutionFile, checkerFile string) ([]runner.TestResult, bool) {
+	binary, err := buildSource(solutionFile)
+	if err != nil {
+		red.Println("Error compiling reference solution:", err)
+		return nil, false
+	}
+	var checker runner.Checker = runner.ExactChecker{}
+	if checkerFile != "" {
+		path, err := buildSource(checkerFile)
+		if err != nil {
+			red.Println("Error compiling checker:", err)
+			return nil, false
+		}
+		checker = runner.ProgramChecker{Path: path, Limits: runner.DefaultLimits}
+	}
+
+	ws, err := runner.NewWorkspace("validate")
+	if err != nil {
+		red.Println("Error:", err)
+		return nil, false
+	}
+	defer ws.Close()
+
+	results := make([]runner.TestResult, len(pkg.Cases))
+	failed := 0
+	for i, c := range pkg.Cases {
+		result := runner.JudgeCase(context.Background(), binary, ws.Dir, c.TestCase, checker, runner.DefaultLimits)
+		results[i] = result
+		if result.Passed {
+			green.Printf("  ✓ %-8s", c.Name)
+			fmt.Printf(" %d ms\n", result.Time)
+			continue
+		}
+		failed++
+		red.Printf("  ✗ %-8s", c.Name)
+		fmt.Printf(" %s", result.Verdict)
+		if result.Reason != "" && result.Reason != string(result.Verdict) {
+			fmt.Printf(": %s", result.Reason)
+		}
+		fmt.Println()
+		if result.Verdict == runner.WrongAnswer {
+			fmt.Printf("             expected %q, reference printed %q\n",
+				truncate(strings.TrimSpace(c.Output), 30), truncate(strings.TrimSpace(result.Output), 30))
+		}
+	}
+
+	if failed > 0 {
+		red.Printf("The reference solution fails %d of %d test cases.\n", failed, len(pkg.Cases))
+		return results, false
+	}
+	green.Printf("The reference solution passes all %d test cases.\n", len(pkg.Cases))
+	return results, true
+}
+
+// questionChecker returns the checker to validate a question's cases with:
+// the file given, or else the question's own checker saved to a temporary
+// file. The cleanup func removes that file.
+func questionChecker(q api.Question, checkerFile string) (string, func(), error) {
+	if checkerFile != "" || q.Checker == "" {
+		return checkerFile, func() {}, nil
+	}
+	dir, err := os.MkdirTemp("", "biskut-checker-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	checkerFile = filepath.Join(dir, "checker.cpp")
+	if err := os.WriteFile(checkerFile, []byte(q.Checker), 0o644); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return checkerFile, cleanup, nil
+}
+
+// fetchQuestion gets a question with its test cases from /api/ins.
+func fetchQuestion(questionID string) (api.Question, bool) {
+	if _, err := strconv.Atoi(questionID); err != nil {
+		red.Println("Question ID must be a number.")
+		return api.Question{}, false
+	}
+	var q api.Question
+	if err := instructorRequest("GET", "/question?questionId="+questionID, nil, &q); err != nil {
+		red.Println("Error fetching question:", err)
+		return api.Question{}, false
+	}
+	return q, true
+}
+
+func importTestCases(questionID, path string, flags []string) {
+	values, err := parseFlags(flags, "solution", "checker", "time-limit")
+	if err != nil {
+		red.Println("Error:", err)
+		return
+	}
+	timeLimit, err := parseTimeLimit(values)
+	if err != nil {
+		red.Println("Error:", err)
+		return
+	}
+	q, ok := fetchQuestion(questionID)
+	if !ok {
+		return
+	}
+	pkg, ok := loadTestCases(path, timeLimit)
+	if !ok {
+		return
+	}
+
+	if values["solution"] != "" {
+		checkerFile, cleanup, err := questionChecker(q, values["checker"])
+		if err != nil {
+			red.Println("Error:", err)
+			return
+		}
+		defer cleanup()
+		if _, ok := validateTestCases(pkg, values["solution"], checkerFile); !ok {
+			red.Println("Test cases not imported.")
+			return
+		}
+	}
+
+	body := map[string]any{"questionId": q.ID, "testCases": pkg.TestCases()}
+	if err := instructorRequest("POST", "/question/testcases", body, &q); err != nil {
+		red.Println("Error importing test cases:", err)
+		return
+	}
+	green.Printf("Imported %d test cases into question %d.\n", len(pkg.Cases), q.ID)
+}
+
+func exportTestCases(questionID, path string) {
+	q, ok := fetchQuestion(questionID)
+	if !ok {
+		return
+	}
+	var cases []runner.TestCase
+	if err := json.Unmarshal([]byte(q.InputsOutputs), &cases); err != nil {
+		red.Println("Error reading the question's test cases:", err)
+		return
+	}
+	if len(cases) == 0 {
+		yellow.Printf("Question %d has no test cases.\n", q.ID)
+		return
+	}
+
+	if err := testcases.Write(path, cases); err != nil {
+		red.Println("Error writing test cases:", err)
+		return
+	}
+	green.Printf("Exported %d test cases of question %d to %s.\n", len(cases), q.ID, path)
+}
+
+// generateTestCases writes a test set whose outputs come from a reference
+// solution, with inputs from a generator run once per seed or from files.
+func generateTestCases(path string, flags []string) {
+	values, err := parseFlags(flags, "solution", "generator", "seeds", "inputs", "time-limit")
+	if err != nil {
+		red.Println("Error:", err)
+		return
+	}
+	if values["solution"] == "" || (values["generator"] == "") == (values["inputs"] == "") {
+		red.Println("Error: give --solution, and either --generator or --inputs")
+		return
+	}
+	timeLimit, err := parseTimeLimit(values)
+	if err != nil {
+		red.Println("Error:", err)
+		return
+	}
+
+	ws, err := runner.NewWorkspace("generate")
+	if err != nil {
+		red.Println("Error:", err)
+		return
+	}
+	defer ws.Close()
+	ctx := context.Background()
+
+	var cases []testcases.Case
+	if values["inputs"] != "" {
+		pkg, err := testcases.LoadInputs(values["inputs"])
+		if err != nil {
+			red.Println("Error reading inputs:", err)
+			return
+		}
+		cases = pkg.Cases
+	} else {
+		if values["seeds"] == "" {
+			values["seeds"] = "1-10"
+		}
+		seeds, err := testcases.ParseSeeds(values["seeds"])
+		if err != nil {
+			red.Println("Error:", err)
+			return
+		}
+		generator, err := buildSource(values["generator"])
+		if err != nil {
+			red.Println("Error compiling generator:", err)
+			return
+		}
+		if cases, err = testcases.Generate(ctx, generator, ws.Dir, seeds, runner.DefaultLimits); err != nil {
+			red.Println("Error:", err)
+			return
+		}
+	}
+	for i := range cases {
+		if timeLimit > 0 {
+			cases[i].TimeLimit = timeLimit
+		}
+	}
+
+	solution, err := buildSource(values["solution"])
+	if err != nil {
+		red.Println("Error compiling reference solution:", err)
+		return
+	}
+	if err := testcases.Answer(ctx, solution, ws.Dir, cases, runner.DefaultLimits); err != nil {
+		red.Println("Error:", err)
+		return
+	}
+
+	pkg := &testcases.Package{Cases: cases}
+	for _, c := range cases {
+		fmt.Printf("  %-8s %6d bytes in  %6d bytes out\n", c.Name, len(c.Input), len(c.Output))
+	}
+	if err := testcases.Write(path, pkg.TestCases()); err != nil {
+		red.Println("Error writing test cases:", err)
+		return
+	}
+	green.Printf("Wrote %d test cases to %s.\n", len(cases), path)
+}
+
+// verifyTestCases checks a question's stored test cases against a reference
+// solution. With --fix, the expected outputs the reference disagrees with
+// are replaced by its output.
+func verifyTestCases(questionID string, flags []string) {
+	fix, flags := takeFlag(flags, "fix")
+	values, err := parseFlags(flags, "solution", "checker")
+	if err != nil {
+		red.Println("Error:", err)
+		return
+	}
+	if values["solution"] == "" {
+		red.Println("Error: --solution is required")
+		return
+	}
+	q, ok := fetchQuestion(questionID)
+	if !ok {
+		return
+	}
+	var stored []runner.TestCase
+	if err := json.Unmarshal([]byte(q.InputsOutputs), &stored); err != nil {
+		red.Println("Error reading the question's test cases:", err)
+		return
+	}
+	if len(stored) == 0 {
+		yellow.Printf("Question %d has no test cases.\n", q.ID)
+		return
+	}
+
+	pkg := &testcases.Package{}
+	for i, tc := range stored {
+		pkg.Cases = append(pkg.Cases, testcases.Case{TestCase: tc, Name: strconv.Itoa(i + 1)})
+	}
+	checkerFile, cleanup, err := questionChecker(q, values["checker"])
+	if err != nil {
+		red.Println("Error:", err)
+		return
+	}
+	defer cleanup()
+	results, ok := validateTestCases(pkg, values["solution"], checkerFile)
+	if ok || results == nil || !fix {
+		return
+	}
+
+	// Only wrong answers can be fixed; a reference that crashes or times out
+	// needs looking at by hand
+	fixed := 0
+	for i, result := range results {
+		if result.Verdict == runner.WrongAnswer {
+			stored[i].Output = result.Output
+			fixed++
+		}
+	}
+	if fixed == 0 {
+		return
+	}
+	body := map[string]any{"questionId": q.ID, "testCases": stored}
+	if err := instructorRequest("POST", "/question/testcases", body, &q); err != nil {
+		red.Println("Error updating test cases:", err)
+		return
+	}
+	green.Printf("Replaced %d expected outputs of question %d with the reference solution's.\n", fixed, q.ID)
+}
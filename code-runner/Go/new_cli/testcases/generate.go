@@ -0,0 +1,75 @@
+package testcases
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"new_cli/runner"
+)
+
+// ParseSeeds expands a list like "1-5,10,42" into the seeds it names.
+func ParseSeeds(spec string) ([]string, error) {
+	var seeds []string
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		from, to, isRange := strings.Cut(part, "-")
+		if !isRange {
+			if _, err := strconv.ParseUint(part, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid seed %q", part)
+			}
+			seeds = append(seeds, part)
+			continue
+		}
+		first, err1 := strconv.Atoi(from)
+		last, err2 := strconv.Atoi(to)
+		if err1 != nil || err2 != nil || first < 0 || last < first {
+			return nil, fmt.Errorf("invalid seed range %q", part)
+		}
+		if last-first >= 1000 {
+			return nil, fmt.Errorf("seed range %q is over 1000 tests", part)
+		}
+		for seed := first; seed <= last; seed++ {
+			seeds = append(seeds, strconv.Itoa(seed))
+		}
+	}
+	return seeds, nil
+}
+
+// Generate runs generator once per seed, passing the seed as its only
+// argument the way testlib generators expect, and returns the inputs it
+// printed. The outputs are left for Answer.
+func Generate(ctx context.Context, generator, dir string, seeds []string, limits runner.Limits) ([]Case, error) {
+	cases := make([]Case, 0, len(seeds))
+	for _, seed := range seeds {
+		input, err := runner.Output(ctx, generator, dir, []string{seed}, "", limits)
+		if err != nil {
+			return nil, fmt.Errorf("generator failed with seed %s: %v", seed, err)
+		}
+		if strings.TrimSpace(input) == "" {
+			return nil, fmt.Errorf("generator printed nothing with seed %s", seed)
+		}
+		cases = append(cases, Case{TestCase: runner.TestCase{Input: input}, Name: "seed " + seed})
+	}
+	return cases, nil
+}
+
+// Answer runs the reference solution on every case and stores what it
+// prints as the expected output.
+func Answer(ctx context.Context, solution, dir string, cases []Case, limits runner.Limits) error {
+	for i := range cases {
+		// The reference has to fit in the limit students get
+		caseLimits := limits
+		if cases[i].TimeLimit > 0 {
+			caseLimits.Time = time.Duration(cases[i].TimeLimit) * time.Millisecond
+		}
+		output, err := runner.Output(ctx, solution, dir, nil, cases[i].Input, caseLimits)
+		if err != nil {
+			return fmt.Errorf("reference solution failed on %s: %v", cases[i].Name, err)
+		}
+		cases[i].Output = output
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package testcases
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"new_cli/runner"
+)
+
+// Stress compares a solution with a brute force on generated inputs, to find
+// a test case the solution gets wrong before the judge does.
+type Stress struct {
+	Solution, Brute, Generator string // executables
+	Dir                        string // where they run
+	Limits                     runner.Limits
+	// BruteLimits are usually looser; a brute force is allowed to be slow.
+	BruteLimits runner.Limits
+}
+
+// Mismatch is an input the solution fails on.
+type Mismatch struct {
+	Seed   string
+	Input  string
+	Result runner.TestResult // Expected holds the brute force's output
+}
+
+// Test runs the generator with seed and both programs on its output. It
+// returns nil if the solution agrees with the brute force, and an error if
+// the generator or the brute force doesn't work, since then nothing can be
+// said about the solution.
+func (s *Stress) Test(ctx context.Context, seed string) (*Mismatch, error) {
+	input, err := runner.Output(ctx, s.Generator, s.Dir, []string{seed}, "", s.Limits)
+	if err != nil {
+		return nil, fmt.Errorf("generator failed with seed %s: %v", seed, err)
+	}
+	if strings.TrimSpace(input) == "" {
+		return nil, fmt.Errorf("generator printed nothing with seed %s", seed)
+	}
+	result, err := s.judge(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("brute force failed with seed %s: %v", seed, err)
+	}
+	if result.Passed {
+		return nil, nil
+	}
+	return &Mismatch{Seed: seed, Input: input, Result: result}, nil
+}
+
+// judge runs the solution on input and checks it against the brute force.
+func (s *Stress) judge(ctx context.Context, input string) (runner.TestResult, error) {
+	expected, err := runner.Output(ctx, s.Brute, s.Dir, nil, input, s.BruteLimits)
+	if err != nil {
+		return runner.TestResult{}, err
+	}
+	tc := runner.TestCase{Input: input, Output: expected}
+	return runner.JudgeCase(ctx, s.Solution, s.Dir, tc, runner.ExactChecker{}, s.Limits), nil
+}
+
+// maxShrinkRuns bounds how long Shrink tries; each attempt runs both
+// programs.
+const maxShrinkRuns = 300
+
+// Shrink makes the input of m as small as it can while the solution still
+// fails on it, by leaving out whole lines and then single tokens. An attempt
+// only counts if the brute force still runs cleanly, but that can't tell
+// every malformed input apart, e.g. a count that no longer matches the
+// numbers after it, so the result needs a look before it is trusted.
+func (s *Stress) Shrink(ctx context.Context, m *Mismatch) *Mismatch {
+	best := *m
+	runs := 0
+	try := func(input string) bool {
+		if runs == maxShrinkRuns || ctx.Err() != nil || strings.TrimSpace(input) == "" {
+			return false
+		}
+		runs++
+		result, err := s.judge(ctx, input)
+		if err != nil || result.Passed {
+			return false
+		}
+		best.Input, best.Result = input, result
+		return true
+	}
+
+	for progress := true; progress; {
+		progress = false
+		lines := strings.Split(strings.TrimSuffix(best.Input, "\n"), "\n")
+		for i := len(lines) - 1; i >= 0 && len(lines) > 1; i-- {
+			candidate := append(append([]string{}, lines[:i]...), lines[i+1:]...)
+			if try(strings.Join(candidate, "\n") + "\n") {
+				lines, progress = candidate, true
+			}
+		}
+		for i := len(lines) - 1; i >= 0; i-- {
+			for j := len(strings.Fields(lines[i])) - 1; j >= 0; j-- {
+				tokens := strings.Fields(lines[i])
+				if len(tokens) < 2 {
+					break
+				}
+				candidate := append([]string{}, lines...)
+				candidate[i] = strings.Join(append(append([]string{}, tokens[:j]...), tokens[j+1:]...), " ")
+				if try(strings.Join(candidate, "\n") + "\n") {
+					lines, progress = candidate, true
+				}
+			}
+		}
+	}
+	return &best
+}
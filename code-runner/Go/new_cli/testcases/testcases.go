@@ -0,0 +1,367 @@
+// Package testcases reads and writes the test cases of a question in the
+// layouts problem setters already use: a directory of N.in/N.out files, a
+// zip of one, and Polygon and CMS packages.
+package testcases
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"new_cli/runner"
+)
+
+// Layouts Load understands.
+const (
+	Plain   = "plain"   // N.in with N.out or N.ans
+	Polygon = "polygon" // tests/NN with tests/NN.a, limits from problem.xml
+	CMS     = "cms"     // input/inputN.txt with output/outputN.txt, limits from task.yaml
+)
+
+// Case is a test case and the name of the file it came from, for reports.
+type Case struct {
+	runner.TestCase
+	Name string
+}
+
+type Package struct {
+	Format string
+	Cases  []Case
+}
+
+func (p *Package) TestCases() []runner.TestCase {
+	cases := make([]runner.TestCase, len(p.Cases))
+	for i, c := range p.Cases {
+		cases[i] = c.TestCase
+	}
+	return cases
+}
+
+// Load reads the test cases in a directory or zip file.
+func Load(name string) (*Package, error) {
+	return open(name, true)
+}
+
+// LoadInputs is Load for packages whose answers are still to be produced;
+// answer files are not read and the outputs are left empty.
+func LoadInputs(name string) (*Package, error) {
+	return open(name, false)
+}
+
+func open(name string, answers bool) (*Package, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return load(os.DirFS(name), answers)
+	}
+
+	zr, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s is neither a directory nor a zip file: %v", name, err)
+	}
+	defer zr.Close()
+	return load(zr, answers)
+}
+
+// LoadFS detects the layout of fsys and reads its test cases, in the
+// natural order of their names. A zip holding a single directory is read
+// from inside that directory.
+func LoadFS(fsys fs.FS) (*Package, error) {
+	return load(fsys, true)
+}
+
+func load(fsys fs.FS, answers bool) (*Package, error) {
+	for {
+		entries, err := fs.ReadDir(fsys, ".")
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) != 1 || !entries[0].IsDir() {
+			break
+		}
+		if fsys, err = fs.Sub(fsys, entries[0].Name()); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case exists(fsys, "problem.xml"):
+		return loadPolygon(fsys, answers)
+	case exists(fsys, "task.yaml") || exists(fsys, "input") && exists(fsys, "output"):
+		return loadCMS(fsys, answers)
+	}
+
+	p, err := loadPlain(fsys, ".", answers)
+	if err == errNoTests && exists(fsys, "tests") {
+		return loadPlain(fsys, "tests", answers)
+	}
+	return p, err
+}
+
+var errNoTests = fmt.Errorf("no test cases found (expected N.in/N.out files, a Polygon package or a CMS package)")
+
+func exists(fsys fs.FS, name string) bool {
+	_, err := fs.Stat(fsys, name)
+	return err == nil
+}
+
+func readFile(fsys fs.FS, name string) (string, error) {
+	data, err := fs.ReadFile(fsys, name)
+	return string(data), err
+}
+
+func loadPlain(fsys fs.FS, dir string, answers bool) (*Package, error) {
+	inputs, err := fs.Glob(fsys, path.Join(dir, "*.in"))
+	if err != nil {
+		return nil, err
+	}
+	if len(inputs) == 0 {
+		return nil, errNoTests
+	}
+	naturalSort(inputs)
+
+	p := &Package{Format: Plain}
+	for _, in := range inputs {
+		base := strings.TrimSuffix(in, ".in")
+		answer := ""
+		if answers {
+			answer = base + ".out"
+			if !exists(fsys, answer) {
+				answer = base + ".ans"
+			}
+			if !exists(fsys, answer) {
+				return nil, fmt.Errorf("no %s.out or %[1]s.ans for %s", path.Base(base), path.Base(in))
+			}
+		}
+		tc, err := readCase(fsys, in, answer)
+		if err != nil {
+			return nil, err
+		}
+		p.Cases = append(p.Cases, Case{TestCase: tc, Name: path.Base(base)})
+	}
+	return p, nil
+}
+
+// readCase reads a test case, leaving the output empty if answer is "".
+func readCase(fsys fs.FS, input, answer string) (runner.TestCase, error) {
+	in, err := readFile(fsys, input)
+	if err != nil || answer == "" {
+		return runner.TestCase{Input: in}, err
+	}
+	out, err := readFile(fsys, answer)
+	if err != nil {
+		return runner.TestCase{}, fmt.Errorf("no answer for %s: %v", input, err)
+	}
+	return runner.TestCase{Input: in, Output: out}, nil
+}
+
+// problemXML is the part of a Polygon problem.xml we need.
+type problemXML struct {
+	Testsets []struct {
+		Name      string `xml:"name,attr"`
+		TimeLimit int    `xml:"time-limit"`
+		InputPath string `xml:"input-path-pattern"`
+		AnswerPat string `xml:"answer-path-pattern"`
+		TestCount int    `xml:"test-count"`
+	} `xml:"judging>testset"`
+}
+
+// loadPolygon reads the "tests" testset of a full Polygon package. Answers
+// only exist in packages built with generated answers.
+func loadPolygon(fsys fs.FS, answers bool) (*Package, error) {
+	data, err := fs.ReadFile(fsys, "problem.xml")
+	if err != nil {
+		return nil, err
+	}
+	var problem problemXML
+	if err := xml.Unmarshal(data, &problem); err != nil {
+		return nil, fmt.Errorf("reading problem.xml: %v", err)
+	}
+
+	inputPattern, answerPattern, timeLimit, count := "tests/%02d", "tests/%02d.a", 0, 0
+	for _, ts := range problem.Testsets {
+		if ts.Name != "tests" {
+			continue
+		}
+		if ts.InputPath != "" {
+			inputPattern = ts.InputPath
+		}
+		if ts.AnswerPat != "" {
+			answerPattern = ts.AnswerPat
+		}
+		timeLimit, count = ts.TimeLimit, ts.TestCount
+	}
+
+	// Without a test count, take tests until the first missing one
+	p := &Package{Format: Polygon}
+	for i := 1; count == 0 || i <= count; i++ {
+		input := fmt.Sprintf(inputPattern, i)
+		if count == 0 && !exists(fsys, input) {
+			break
+		}
+		answer := ""
+		if answers {
+			answer = fmt.Sprintf(answerPattern, i)
+			if !exists(fsys, answer) {
+				return nil, fmt.Errorf("no answer %s for test %d; build the package with generated answers (full package)", answer, i)
+			}
+		}
+		tc, err := readCase(fsys, input, answer)
+		if err != nil {
+			return nil, err
+		}
+		tc.TimeLimit = timeLimit
+		p.Cases = append(p.Cases, Case{TestCase: tc, Name: path.Base(input)})
+	}
+	if len(p.Cases) == 0 {
+		return nil, errNoTests
+	}
+	return p, nil
+}
+
+var cmsInput = regexp.MustCompile(`^input(\d+)\.txt$`)
+
+// loadCMS reads input/inputN.txt and output/outputN.txt, the layout of the
+// CMS italy_yaml format.
+func loadCMS(fsys fs.FS, answers bool) (*Package, error) {
+	entries, err := fs.ReadDir(fsys, "input")
+	if err != nil {
+		return nil, err
+	}
+	var inputs []string
+	for _, e := range entries {
+		if cmsInput.MatchString(e.Name()) {
+			inputs = append(inputs, e.Name())
+		}
+	}
+	if len(inputs) == 0 {
+		return nil, errNoTests
+	}
+	naturalSort(inputs)
+
+	timeLimit, err := cmsTimeLimit(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Package{Format: CMS}
+	for _, name := range inputs {
+		n := cmsInput.FindStringSubmatch(name)[1]
+		answer := ""
+		if answers {
+			answer = "output/output" + n + ".txt"
+		}
+		tc, err := readCase(fsys, "input/"+name, answer)
+		if err != nil {
+			return nil, err
+		}
+		tc.TimeLimit = timeLimit
+		p.Cases = append(p.Cases, Case{TestCase: tc, Name: n})
+	}
+	return p, nil
+}
+
+// cmsTimeLimit reads time_limit (seconds) from task.yaml, in milliseconds.
+func cmsTimeLimit(fsys fs.FS) (int, error) {
+	f, err := fsys.Open("task.yaml")
+	if err != nil {
+		return 0, nil
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || strings.TrimSpace(key) != "time_limit" {
+			continue
+		}
+		seconds, err := strconv.ParseFloat(strings.Trim(strings.TrimSpace(value), `"'`), 64)
+		if err != nil {
+			return 0, fmt.Errorf("task.yaml: invalid time_limit %q", strings.TrimSpace(value))
+		}
+		return int(seconds * 1000), nil
+	}
+	return 0, scanner.Err()
+}
+
+// naturalSort orders names so that "2" comes before "10".
+func naturalSort(names []string) {
+	digits := regexp.MustCompile(`\d+`)
+	key := func(s string) string {
+		return digits.ReplaceAllStringFunc(s, func(d string) string {
+			return fmt.Sprintf("%020s", strings.TrimLeft(d, "0"))
+		})
+	}
+	sort.SliceStable(names, func(i, j int) bool { return key(names[i]) < key(names[j]) })
+}
+
+// Write saves cases as 1.in/1.out, 2.in/2.out, ... in dir, or in a zip file
+// if dir ends in .zip. It refuses to overwrite existing files.
+func Write(dir string, cases []runner.TestCase) error {
+	if strings.HasSuffix(strings.ToLower(dir), ".zip") {
+		f, err := os.OpenFile(dir, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return err
+		}
+		if err := writeZip(f, cases); err != nil {
+			f.Close()
+			os.Remove(dir)
+			return err
+		}
+		return f.Close()
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for i, tc := range cases {
+		for name, content := range map[string]string{
+			fmt.Sprintf("%d.in", i+1):  tc.Input,
+			fmt.Sprintf("%d.out", i+1): tc.Output,
+		} {
+			f, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(f, content)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeZip(w io.Writer, cases []runner.TestCase) error {
+	zw := zip.NewWriter(w)
+	for i, tc := range cases {
+		for _, file := range []struct{ name, content string }{
+			{fmt.Sprintf("%d.in", i+1), tc.Input},
+			{fmt.Sprintf("%d.out", i+1), tc.Output},
+		} {
+			fw, err := zw.Create(file.name)
+			if err != nil {
+				return err
+			}
+			if _, err := io.WriteString(fw, file.content); err != nil {
+				return err
+			}
+		}
+	}
+	return zw.Close()
+}
@@ -0,0 +1,192 @@
+package testcases
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"new_cli/runner"
+)
+
+func files(m map[string]string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for name, content := range m {
+		fsys[name] = &fstest.MapFile{Data: []byte(content)}
+	}
+	return fsys
+}
+
+// summary lists name:input:output:timeLimit for every case.
+func summary(p *Package) string {
+	var parts []string
+	for _, c := range p.Cases {
+		parts = append(parts, fmt.Sprintf("%s:%s:%s:%d", c.Name, strings.TrimSpace(c.Input), strings.TrimSpace(c.Output), c.TimeLimit))
+	}
+	return p.Format + " " + strings.Join(parts, " ")
+}
+
+func TestLoadFS(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"plain", map[string]string{
+			"10.in": "c", "10.out": "C",
+			"2.in": "b", "2.ans": "B",
+			"1.in": "a", "1.out": "A",
+			"statement.txt": "ignored",
+		}, "plain 1:a:A:0 2:b:B:0 10:c:C:0"},
+		{"nested plain", map[string]string{
+			"week1/tests/1.in": "a", "week1/tests/1.out": "A",
+		}, "plain 1:a:A:0"},
+		{"polygon", map[string]string{
+			"problem.xml": `<?xml version="1.0" encoding="utf-8"?>
+<problem short-name="sum">
+  <judging>
+    <testset name="tests">
+      <time-limit>2000</time-limit>
+      <test-count>2</test-count>
+      <input-path-pattern>tests/%02d</input-path-pattern>
+      <answer-path-pattern>tests/%02d.a</answer-path-pattern>
+    </testset>
+  </judging>
+</problem>`,
+			"tests/01": "a", "tests/01.a": "A",
+			"tests/02": "b", "tests/02.a": "B",
+		}, "polygon 01:a:A:2000 02:b:B:2000"},
+		{"cms", map[string]string{
+			"task.yaml":           "name: sum\ntime_limit: 1.5\nmemory_limit: 256\n",
+			"input/input0.txt":    "a",
+			"output/output0.txt":  "A",
+			"input/input10.txt":   "c",
+			"output/output10.txt": "C",
+			"input/input2.txt":    "b",
+			"output/output2.txt":  "B",
+		}, "cms 0:a:A:1500 2:b:B:1500 10:c:C:1500"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := LoadFS(files(tt.files))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := summary(p); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadFSErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"empty", map[string]string{"readme.md": ""}, "no test cases found"},
+		{"missing answer", map[string]string{"1.in": "a"}, "no 1.out or 1.ans for 1.in"},
+		{"polygon without answers", map[string]string{
+			"problem.xml": `<problem><judging><testset name="tests"><test-count>1</test-count></testset></judging></problem>`,
+			"tests/01":    "a",
+		}, "no answer tests/01.a for test 1"},
+		{"bad time limit", map[string]string{
+			"task.yaml": "time_limit: soon\n", "input/input0.txt": "a", "output/output0.txt": "A",
+		}, `invalid time_limit "soon"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadFS(files(tt.files))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteAndLoad(t *testing.T) {
+	cases := []runner.TestCase{{Input: "1 2\n", Output: "3\n"}, {Input: "", Output: "0\n"}}
+	dir := t.TempDir()
+
+	for _, path := range []string{filepath.Join(dir, "cases"), filepath.Join(dir, "cases.zip")} {
+		if err := Write(path, cases); err != nil {
+			t.Fatal(err)
+		}
+		p, err := Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := summary(p); got != "plain 1:1 2:3:0 2::0:0" {
+			t.Errorf("%s read back as %s", filepath.Base(path), got)
+		}
+		if err := Write(path, cases); !os.IsExist(err) {
+			t.Errorf("writing %s twice: got %v, want a file exists error", filepath.Base(path), err)
+		}
+	}
+}
+
+func TestLoadZipWithFolder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tests.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, content := range map[string]string{"sum/1.in": "a", "sum/1.out": "A"} {
+		w, _ := zw.Create(name)
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	f.Close()
+
+	p, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := summary(p); got != "plain 1:a:A:0" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestLoadInputs(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "1.in"), []byte("a"), 0o644)
+	os.WriteFile(filepath.Join(dir, "2.in"), []byte("b"), 0o644)
+	os.WriteFile(filepath.Join(dir, "2.out"), []byte("stale"), 0o644)
+
+	p, err := LoadInputs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := summary(p); got != "plain 1:a::0 2:b::0" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestParseSeeds(t *testing.T) {
+	tests := []struct {
+		spec, want string
+	}{
+		{"1-3", "1 2 3"},
+		{"5, 1-2,42", "5 1 2 42"},
+		{"7", "7"},
+		{"3-1", `invalid seed range "3-1"`},
+		{"x", `invalid seed "x"`},
+		{"", `invalid seed ""`},
+		{"0-5000", `seed range "0-5000" is over 1000 tests`},
+	}
+	for _, tt := range tests {
+		seeds, err := ParseSeeds(tt.spec)
+		got := strings.Join(seeds, " ")
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tt.want {
+			t.Errorf("ParseSeeds(%q) = %s, want %s", tt.spec, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"new_cli/api"
+)
+
+// How often, and after how long, wait reconnects after losing the server.
+const (
+	waitRetries    = 5
+	waitRetryDelay = 2 * time.Second
+)
+
+// waitForSubmission follows a submission until it has a verdict: straight
+// from the store if judging is over, otherwise by reattaching to its event
+// stream.
+func waitForSubmission(submissionID string) {
+	if studentID == "" {
+		red.Println("Student ID is not set. Use 'set studentid <ID>' first.")
+		return
+	}
+	if _, err := strconv.Atoi(submissionID); err != nil {
+		red.Println("Submission ID must be a number.")
+		return
+	}
+	query := url.Values{"submissionId": {submissionID}, "studentId": {studentID}}.Encode()
+
+	for attempt := 0; attempt <= waitRetries; attempt++ {
+		if attempt > 0 {
+			yellow.Printf("Reconnecting in %s...\n", waitRetryDelay)
+			time.Sleep(waitRetryDelay)
+		}
+
+		resp, err := http.Get(apiBase + "/api/stu/submission?" + query)
+		if err != nil {
+			red.Println("Error sending request:", err)
+			continue
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			resp.Body.Close()
+			red.Printf("Submission %s not found.\n", submissionID)
+			return
+		}
+		var sub api.Submission
+		err = json.NewDecoder(resp.Body).Decode(&sub)
+		resp.Body.Close()
+		if err != nil {
+			red.Println("Error parsing JSON:", err)
+			continue
+		}
+		if sub.Status != api.StatusPending {
+			printSubmission(sub)
+			return
+		}
+
+		resp, err = http.Get(apiBase + "/api/stu/submission/events?" + query)
+		if err != nil {
+			red.Println("Error sending request:", err)
+			continue
+		}
+		if resp.StatusCode == http.StatusBadRequest {
+			resp.Body.Close()
+			yellow.Printf("Submission %d is waiting for the instructor to judge it.\n", sub.ID)
+			return
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			red.Println("Error following submission:", resp.Status)
+			continue
+		}
+		_, done := handleStreamedResponse(resp.Body)
+		resp.Body.Close()
+		if done {
+			return
+		}
+		red.Println("Connection lost before the verdict arrived.")
+	}
+	red.Printf("Giving up; try 'wait %s' again later.\n", submissionID)
+}
+
+// printSubmission shows a submission that already has its verdict.
+func printSubmission(sub api.Submission) {
+	line := fmt.Sprintf("Submission %d to question %d: %s", sub.ID, sub.QuestionID, sub.Status)
+	switch sub.Status {
+	case api.StatusPassed:
+		green.Println(line)
+	case api.StatusCancelled:
+		yellow.Println(line)
+		return
+	default:
+		red.Println(line)
+	}
+	if strings.Contains(sub.ResultDetails, `"end"`) {
+		printEvent(sub.ResultDetails+"\n", sub.ID)
+		rememberVerdict(sub.ResultDetails)
+	}
+	if sub.Comment != "" {
+		fmt.Println("Instructor's comment:", sub.Comment)
+	}
+}
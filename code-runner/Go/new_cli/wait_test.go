@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"testing"
+	"time"
+
+	"new_cli/api"
+	"new_cli/fakeapi"
+)
+
+func TestWaitAfterDisconnect(t *testing.T) {
+	fake := newFake(t)
+	login(t, fake)
+	fake.SetScenario(fakeapi.Scenario{Events: fakeapi.PartialPass.Events, Delay: 200 * time.Millisecond})
+
+	// Submit and hang up after the first event, as a student pressing
+	// Ctrl-C would
+	resp := postSubmission(t, fake)
+	line, _ := bufio.NewReader(resp.Body).ReadString('\n')
+	resp.Body.Close()
+	if want := `data: {"pushed":true,"submissionId":1}` + "\n"; line != want {
+		t.Fatalf("first event = %q, want %q", line, want)
+	}
+
+	out := capture(t, func() { handleCommand("wait 1") })
+
+	// The server stores the verdict itself, a moment after publishing it
+	var sub api.Submission
+	var err error
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if sub, err = fake.Store.Submission(context.Background(), 1); err != nil || sub.Status != api.StatusPending {
+			break
+		}
+	}
+	if sub.Status != api.StatusFailed {
+		t.Fatalf("stored status = %q, want %q", sub.Status, api.StatusFailed)
+	}
+
+	out += capture(t, func() {
+		handleCommand("wait 1")
+		handleCommand("wait 99")
+		handleCommand("wait x")
+		handleCommand("wait")
+		studentID = "2"
+		handleCommand("wait 1")
+	})
+	checkGolden(t, "wait", out)
+}
+
+func TestWaitForInstructor(t *testing.T) {
+	fake := newFake(t)
+	login(t, fake)
+	sub := api.Submission{StudentID: 1, QuestionID: 2, LabSessionID: 1, Status: api.StatusPending, ResultDetails: `{"output":"hello"}`}
+	if err := fake.Store.CreateSubmission(context.Background(), &sub); err != nil {
+		t.Fatal(err)
+	}
+
+	out := capture(t, func() { handleCommand("wait 1") })
+	fake.Store.JudgeSubmission(context.Background(), 1, api.StatusPassed, "Nicely done")
+	out += capture(t, func() { handleCommand("wait 1") })
+	checkGolden(t, "wait_instructor", out)
+}
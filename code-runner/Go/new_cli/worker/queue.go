@@ -0,0 +1,430 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultStream is the Redis stream the server adds submissions to.
+const DefaultStream = "submissions:stream"
+
+// Queue is the submissions stream, read through a consumer group so that
+// every submission goes to exactly one of any number of workers. An entry
+// stays pending until the worker acknowledges it; one left unacknowledged
+// for VisibilityTimeout, because its worker died, is claimed by another
+// worker, up to MaxAttempts deliveries, after which it is moved to the
+// DeadLetter stream.
+//
+// Workers add the final event of each submission to the Verdicts stream,
+// which the servers read through a consumer group of their own, so a
+// verdict waits there until one of them has stored it.
+type Queue struct {
+	Redis             *redis.Client
+	Stream            string
+	Group             string
+	DeadLetter        string
+	Verdicts          string
+	VerdictGroup      string
+	VisibilityTimeout time.Duration
+	MaxAttempts       int64
+}
+
+// NewQueue returns a queue on stream with the default group and limits.
+func NewQueue(rdb *redis.Client, stream string) *Queue {
+	return &Queue{
+		Redis:             rdb,
+		Stream:            stream,
+		Group:             "judges",
+		DeadLetter:        stream + ":dead",
+		Verdicts:          stream + ":verdicts",
+		VerdictGroup:      "recorders",
+		VisibilityTimeout: 2 * time.Minute,
+		MaxAttempts:       3,
+	}
+}
+
+// Message is a submission taken off the queue.
+type Message struct {
+	ID         string
+	Submission Submission
+	Attempts   int64 // deliveries so far, this one included
+	payload    string
+}
+
+// Verdict is the final event of a submission, taken off the Verdicts stream.
+type Verdict struct {
+	ID           string
+	SubmissionID int
+	Event        string
+}
+
+// Position is where a submission stands in the queue.
+type Position struct {
+	Ahead int64         // submissions to be started before it
+	ETA   time.Duration // until it is started; 0 if there is nothing to go by
+}
+
+// The queue keeps two things next to the stream for Position: the
+// submissions no worker has started yet, scored by when they were pushed,
+// and when the latest submissions were finished.
+func (q *Queue) waitingKey() string  { return q.Stream + ":waiting" }
+func (q *Queue) finishedKey() string { return q.Stream + ":finished" }
+
+// A cancelled submission is marked with a key of its own, which a worker
+// checks before judging, and announced on a channel, which tells the worker
+// judging it to stop. The mark expires once no worker could still care.
+func (q *Queue) cancelledKey(submissionID int) string {
+	return fmt.Sprintf("%s:cancelled:%d", q.Stream, submissionID)
+}
+
+// CancelChannel is the channel cancelled submission IDs are published on.
+func (q *Queue) CancelChannel() string { return q.Stream + ":cancel" }
+
+const cancelledTTL = 24 * time.Hour
+
+// recentFinishes is how many finishing times Position estimates throughput
+// from, and throughputWindow how old they may be.
+const (
+	recentFinishes   = 50
+	throughputWindow = 10 * time.Minute
+)
+
+func (q *Queue) Push(ctx context.Context, sub Submission) error {
+	data, err := json.Marshal(sub)
+	if err != nil {
+		return err
+	}
+	_, err = q.Redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{Stream: q.Stream, Values: map[string]any{"submission": data}})
+		if sub.SubmissionID != 0 {
+			pipe.ZAdd(ctx, q.waitingKey(), redis.Z{Score: float64(time.Now().UnixMilli()), Member: sub.SubmissionID})
+		}
+		return nil
+	})
+	return err
+}
+
+// Position reports how many submissions are ahead of submissionID and how
+// long they should take at the rate submissions were finished lately. It
+// returns nil once a worker has started on the submission.
+func (q *Queue) Position(ctx context.Context, submissionID int) (*Position, error) {
+	ahead, err := q.Redis.ZRank(ctx, q.waitingKey(), strconv.Itoa(submissionID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stamps, err := q.Redis.LRange(ctx, q.finishedKey(), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	var newest, oldest int64
+	finished := 0
+	for _, stamp := range stamps {
+		at, err := strconv.ParseInt(stamp, 10, 64)
+		if err != nil || time.Since(time.UnixMilli(at)) > throughputWindow {
+			continue
+		}
+		if finished == 0 || at > newest {
+			newest = at
+		}
+		if finished == 0 || at < oldest {
+			oldest = at
+		}
+		finished++
+	}
+
+	pos := &Position{Ahead: ahead}
+	if finished >= 2 && newest > oldest {
+		perSubmission := time.Duration(newest-oldest) * time.Millisecond / time.Duration(finished-1)
+		pos.ETA = time.Duration(ahead+1) * perSubmission
+	}
+	return pos, nil
+}
+
+// Cancel withdraws a submission: it is taken out of the waiting line and
+// skipped when a worker gets to it, and a worker judging it stops.
+func (q *Queue) Cancel(ctx context.Context, submissionID int) error {
+	_, err := q.Redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, q.waitingKey(), submissionID)
+		pipe.Set(ctx, q.cancelledKey(submissionID), 1, cancelledTTL)
+		pipe.Publish(ctx, q.CancelChannel(), submissionID)
+		return nil
+	})
+	return err
+}
+
+// Setup creates the consumer groups if they don't exist yet. They start at
+// the beginning of their streams, so submissions added before any worker
+// ran are judged too, and verdicts added before any server ran are stored.
+func (q *Queue) Setup(ctx context.Context) error {
+	for stream, group := range map[string]string{q.Stream: q.Group, q.Verdicts: q.VerdictGroup} {
+		err := q.Redis.XGroupCreateMkStream(ctx, stream, group, "0").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return fmt.Errorf("creating consumer group %s on %s: %v", group, stream, err)
+		}
+	}
+	return nil
+}
+
+// Next returns the next submission for consumer: one abandoned by another
+// worker if there is one, otherwise a new one, waiting up to block for it.
+// It returns nil when nothing came in.
+func (q *Queue) Next(ctx context.Context, consumer string, block time.Duration) (*Message, error) {
+	claimed, _, err := q.Redis.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   q.Stream,
+		Group:    q.Group,
+		Consumer: consumer,
+		MinIdle:  q.VisibilityTimeout,
+		Start:    "0-0",
+		Count:    1,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(claimed) > 0 {
+		pending, err := q.Redis.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream: q.Stream,
+			Group:  q.Group,
+			Start:  claimed[0].ID,
+			End:    claimed[0].ID,
+			Count:  1,
+		}).Result()
+		if err != nil {
+			return nil, err
+		}
+		attempts := int64(1)
+		if len(pending) > 0 {
+			attempts = pending[0].RetryCount
+		}
+		return q.message(ctx, claimed[0], attempts)
+	}
+
+	streams, err := q.Redis.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    q.Group,
+		Consumer: consumer,
+		Streams:  []string{q.Stream, ">"},
+		Count:    1,
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(streams) == 0 || len(streams[0].Messages) == 0 {
+		return nil, nil
+	}
+	msg, err := q.message(ctx, streams[0].Messages[0], 1)
+	if msg != nil && msg.Submission.SubmissionID != 0 {
+		q.Redis.ZRem(ctx, q.waitingKey(), msg.Submission.SubmissionID)
+	}
+	return msg, err
+}
+
+// message decodes an entry. One that can't be decoded will never be judged,
+// so it goes straight to the dead letter stream; one that was cancelled is
+// dropped.
+func (q *Queue) message(ctx context.Context, entry redis.XMessage, attempts int64) (*Message, error) {
+	msg := &Message{ID: entry.ID, Attempts: attempts}
+	msg.payload, _ = entry.Values["submission"].(string)
+	if err := json.Unmarshal([]byte(msg.payload), &msg.Submission); err != nil {
+		log.Printf("Error parsing submission %s: %v", entry.ID, err)
+		return nil, q.Bury(ctx, msg, "invalid payload: "+err.Error())
+	}
+	cancelled, err := q.Cancelled(ctx, msg.Submission.SubmissionID)
+	if err != nil {
+		return nil, err
+	}
+	if cancelled {
+		log.Printf("Skipping cancelled submission %d", msg.Submission.SubmissionID)
+		return nil, q.Drop(ctx, msg)
+	}
+	return msg, nil
+}
+
+// Cancelled reports whether a submission was cancelled.
+func (q *Queue) Cancelled(ctx context.Context, submissionID int) (bool, error) {
+	if submissionID == 0 {
+		return false, nil
+	}
+	n, err := q.Redis.Exists(ctx, q.cancelledKey(submissionID)).Result()
+	return n > 0, err
+}
+
+// Ack marks a submission as done and drops it from the stream.
+func (q *Queue) Ack(ctx context.Context, msg *Message) error {
+	_, err := q.Redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAck(ctx, q.Stream, q.Group, msg.ID)
+		pipe.XDel(ctx, q.Stream, msg.ID)
+		pipe.LPush(ctx, q.finishedKey(), time.Now().UnixMilli())
+		pipe.LTrim(ctx, q.finishedKey(), 0, recentFinishes-1)
+		return nil
+	})
+	return err
+}
+
+// Drop removes a submission without recording it as judged, so unlike Ack
+// it doesn't count towards the throughput Position estimates from.
+func (q *Queue) Drop(ctx context.Context, msg *Message) error {
+	_, err := q.Redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAck(ctx, q.Stream, q.Group, msg.ID)
+		pipe.XDel(ctx, q.Stream, msg.ID)
+		return nil
+	})
+	return err
+}
+
+// Bury moves a submission that can't be judged to the dead letter stream,
+// with the reason, for someone to look at.
+func (q *Queue) Bury(ctx context.Context, msg *Message, reason string) error {
+	err := q.Redis.XAdd(ctx, &redis.XAddArgs{Stream: q.DeadLetter, Values: map[string]any{
+		"submission": msg.payload,
+		"id":         msg.ID,
+		"attempts":   msg.Attempts,
+		"reason":     reason,
+	}}).Err()
+	if err != nil {
+		return err
+	}
+	return q.Drop(ctx, msg)
+}
+
+// AddVerdict records the final event of a submission for a server to store.
+func (q *Queue) AddVerdict(ctx context.Context, submissionID int, event []byte) error {
+	return q.Redis.XAdd(ctx, &redis.XAddArgs{Stream: q.Verdicts, Values: map[string]any{
+		"submissionId": submissionID,
+		"event":        event,
+	}}).Err()
+}
+
+// NextVerdict returns the next verdict for consumer to store: one another
+// server left unacknowledged for the visibility timeout if there is one,
+// otherwise a new one, waiting up to block for it. It returns nil when
+// nothing came in.
+func (q *Queue) NextVerdict(ctx context.Context, consumer string, block time.Duration) (*Verdict, error) {
+	claimed, _, err := q.Redis.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   q.Verdicts,
+		Group:    q.VerdictGroup,
+		Consumer: consumer,
+		MinIdle:  q.VisibilityTimeout,
+		Start:    "0-0",
+		Count:    1,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(claimed) > 0 {
+		return q.verdict(ctx, claimed[0])
+	}
+
+	streams, err := q.Redis.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    q.VerdictGroup,
+		Consumer: consumer,
+		Streams:  []string{q.Verdicts, ">"},
+		Count:    1,
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(streams) == 0 || len(streams[0].Messages) == 0 {
+		return nil, nil
+	}
+	return q.verdict(ctx, streams[0].Messages[0])
+}
+
+// verdict decodes an entry of the Verdicts stream. One without a
+// submission has nowhere to be stored, so it is dropped.
+func (q *Queue) verdict(ctx context.Context, entry redis.XMessage) (*Verdict, error) {
+	v := &Verdict{ID: entry.ID}
+	v.Event, _ = entry.Values["event"].(string)
+	id, _ := entry.Values["submissionId"].(string)
+	v.SubmissionID, _ = strconv.Atoi(id)
+	if v.SubmissionID == 0 {
+		log.Printf("Dropping verdict %s of no submission", entry.ID)
+		return nil, q.AckVerdict(ctx, v)
+	}
+	return v, nil
+}
+
+// AckVerdict marks a verdict as stored and drops it from the stream.
+func (q *Queue) AckVerdict(ctx context.Context, v *Verdict) error {
+	_, err := q.Redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAck(ctx, q.Verdicts, q.VerdictGroup, v.ID)
+		pipe.XDel(ctx, q.Verdicts, v.ID)
+		return nil
+	})
+	return err
+}
+
+// Known returns the IDs of the submissions the judge knows about: those
+// waiting or being judged, and those whose verdict hasn't been stored yet.
+func (q *Queue) Known(ctx context.Context) (map[int]bool, error) {
+	known := map[int]bool{}
+	jobs, err := q.Redis.XRange(ctx, q.Stream, "-", "+").Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range jobs {
+		var sub Submission
+		payload, _ := entry.Values["submission"].(string)
+		if json.Unmarshal([]byte(payload), &sub) == nil && sub.SubmissionID != 0 {
+			known[sub.SubmissionID] = true
+		}
+	}
+	verdicts, err := q.Redis.XRange(ctx, q.Verdicts, "-", "+").Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range verdicts {
+		id, _ := entry.Values["submissionId"].(string)
+		if n, err := strconv.Atoi(id); err == nil && n != 0 {
+			known[n] = true
+		}
+	}
+	return known, nil
+}
+
+// KeepAlive claims msg for consumer again every third of the visibility
+// timeout, so judging that takes longer than the timeout isn't mistaken for
+// a dead worker. Calling the returned function stops it.
+func (q *Queue) KeepAlive(ctx context.Context, consumer string, msg *Message) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		ticker := time.NewTicker(q.VisibilityTimeout / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// JUSTID resets the idle time without counting a delivery
+				err := q.Redis.XClaimJustID(ctx, &redis.XClaimArgs{
+					Stream:   q.Stream,
+					Group:    q.Group,
+					Consumer: consumer,
+					Messages: []string{msg.ID},
+				}).Err()
+				if err != nil && ctx.Err() == nil {
+					log.Printf("Error extending submission %s: %v", msg.ID, err)
+				}
+			}
+		}
+	}()
+	return cancel
+}
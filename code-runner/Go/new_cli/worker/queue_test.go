@@ -0,0 +1,269 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestQueue(t *testing.T) (*Queue, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	q := NewQueue(rdb, DefaultStream)
+	if err := q.Setup(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return q, mr
+}
+
+// next takes the next submission for consumer, failing the test if there
+// is none.
+func next(t *testing.T, q *Queue, consumer string) *Message {
+	t.Helper()
+	msg, err := q.Next(context.Background(), consumer, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg == nil {
+		t.Fatalf("%s got no submission", consumer)
+	}
+	return msg
+}
+
+func nothingNext(t *testing.T, q *Queue, consumer string) {
+	t.Helper()
+	msg, err := q.Next(context.Background(), consumer, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg != nil {
+		t.Fatalf("%s got submission %d (attempt %d), want none", consumer, msg.Submission.SubmissionID, msg.Attempts)
+	}
+}
+
+func pending(t *testing.T, q *Queue) int64 {
+	t.Helper()
+	p, err := q.Redis.XPending(context.Background(), q.Stream, q.Group).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.Count
+}
+
+func TestQueueAck(t *testing.T) {
+	ctx := context.Background()
+	q, _ := newTestQueue(t)
+	for _, id := range []int{7, 8} {
+		if err := q.Push(ctx, Submission{SubmissionID: id, StudentID: "1", QuestionID: "2"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if pos, err := q.Position(ctx, 8); err != nil || pos == nil || pos.Ahead != 1 {
+		t.Fatalf("Position(8) = %+v, %v; want 1 ahead", pos, err)
+	}
+
+	msg := next(t, q, "a")
+	if msg.Submission.SubmissionID != 7 || msg.Attempts != 1 {
+		t.Fatalf("got submission %d (attempt %d), want 7 (attempt 1)", msg.Submission.SubmissionID, msg.Attempts)
+	}
+	// Started, so no longer waiting, and the next one moves up
+	if pos, err := q.Position(ctx, 7); err != nil || pos != nil {
+		t.Errorf("Position(7) = %+v, %v; want nil once started", pos, err)
+	}
+	if pos, err := q.Position(ctx, 8); err != nil || pos == nil || pos.Ahead != 0 {
+		t.Errorf("Position(8) = %+v, %v; want 0 ahead", pos, err)
+	}
+
+	if err := q.Ack(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	if n := pending(t, q); n != 0 {
+		t.Errorf("%d submissions pending after Ack, want 0", n)
+	}
+	if n := q.Redis.XLen(ctx, q.Stream).Val(); n != 1 {
+		t.Errorf("stream holds %d submissions, want only the unread one", n)
+	}
+	if n := q.Redis.LLen(ctx, q.finishedKey()).Val(); n != 1 {
+		t.Errorf("%d finishes recorded, want 1", n)
+	}
+}
+
+// A submission whose worker died is claimed by another once it has been
+// idle for the visibility timeout, and counts as a further attempt.
+func TestQueueReclaimsAbandoned(t *testing.T) {
+	ctx := context.Background()
+	q, mr := newTestQueue(t)
+	now := time.Now()
+	mr.SetTime(now)
+	if err := q.Push(ctx, Submission{SubmissionID: 7}); err != nil {
+		t.Fatal(err)
+	}
+
+	first := next(t, q, "a") // and "a" crashes
+	nothingNext(t, q, "b")
+
+	mr.SetTime(now.Add(q.VisibilityTimeout - time.Second))
+	nothingNext(t, q, "b")
+
+	mr.SetTime(now.Add(q.VisibilityTimeout + time.Second))
+	second := next(t, q, "b")
+	if second.ID != first.ID || second.Attempts != 2 {
+		t.Fatalf("b got %s (attempt %d), want %s (attempt 2)", second.ID, second.Attempts, first.ID)
+	}
+	if err := q.Ack(ctx, second); err != nil {
+		t.Fatal(err)
+	}
+	mr.SetTime(now.Add(3 * q.VisibilityTimeout))
+	nothingNext(t, q, "c")
+}
+
+// A submission that keeps killing its worker is given up on after
+// MaxAttempts: the student is told, and it moves to the dead letter stream.
+func TestQueueMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	q, mr := newTestQueue(t)
+	now := time.Now()
+	mr.SetTime(now)
+	sub := Submission{SubmissionID: 7, StudentID: "1", QuestionID: "2"}
+	if err := q.Push(ctx, sub); err != nil {
+		t.Fatal(err)
+	}
+
+	var msg *Message
+	for attempt := int64(1); attempt <= q.MaxAttempts+1; attempt++ {
+		mr.SetTime(now.Add(time.Duration(attempt) * (q.VisibilityTimeout + time.Second)))
+		msg = next(t, q, "a")
+		if msg.Attempts != attempt {
+			t.Fatalf("delivery %d counted as attempt %d", attempt, msg.Attempts)
+		}
+	}
+
+	events := q.Redis.Subscribe(ctx, sub.Channel())
+	defer events.Close()
+	if _, err := events.Receive(ctx); err != nil {
+		t.Fatal(err)
+	}
+	w := &Worker{Redis: q.Redis, Queue: q, Consumer: "a"}
+	w.handle(ctx, msg)
+
+	select {
+	case event := <-events.Channel():
+		var got struct {
+			End    bool   `json:"end"`
+			Status string `json:"status"`
+		}
+		if err := json.Unmarshal([]byte(event.Payload), &got); err != nil || !got.End || got.Status != "failed" {
+			t.Errorf("student was told %s, want a final failed event", event.Payload)
+		}
+	case <-time.After(time.Second):
+		t.Error("student was never told")
+	}
+
+	if verdicts := q.Redis.XRange(ctx, q.Verdicts, "-", "+").Val(); len(verdicts) != 1 || verdicts[0].Values["submissionId"] != "7" {
+		t.Errorf("verdicts = %v, want the failure of submission 7", verdicts)
+	}
+	if n := pending(t, q); n != 0 {
+		t.Errorf("%d submissions pending after giving up, want 0", n)
+	}
+	if n := q.Redis.XLen(ctx, q.Stream).Val(); n != 0 {
+		t.Errorf("stream still holds %d submissions", n)
+	}
+	dead, err := q.Redis.XRange(ctx, q.DeadLetter, "-", "+").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dead) != 1 {
+		t.Fatalf("dead letter stream holds %d entries, want 1", len(dead))
+	}
+	got := dead[0].Values
+	if got["id"] != msg.ID || got["reason"] != "too many attempts" || got["attempts"] != "4" || got["submission"] != msg.payload {
+		t.Errorf("dead letter entry = %v", got)
+	}
+}
+
+// A payload that can't be decoded is never handed out.
+func TestQueueBuriesInvalidPayload(t *testing.T) {
+	ctx := context.Background()
+	q, _ := newTestQueue(t)
+	if err := q.Redis.XAdd(ctx, &redis.XAddArgs{Stream: q.Stream, Values: map[string]any{"submission": "{"}}).Err(); err != nil {
+		t.Fatal(err)
+	}
+	nothingNext(t, q, "a")
+	if n := pending(t, q); n != 0 {
+		t.Errorf("%d submissions pending, want 0", n)
+	}
+	dead := q.Redis.XRange(ctx, q.DeadLetter, "-", "+").Val()
+	if len(dead) != 1 || dead[0].Values["submission"] != "{" {
+		t.Errorf("dead letter stream = %v, want the invalid payload", dead)
+	}
+}
+
+// Judging that takes longer than the visibility timeout isn't mistaken for
+// a dead worker while KeepAlive runs.
+func TestQueueKeepAlive(t *testing.T) {
+	ctx := context.Background()
+	q, _ := newTestQueue(t)
+	q.VisibilityTimeout = 300 * time.Millisecond
+	if err := q.Push(ctx, Submission{SubmissionID: 7}); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := next(t, q, "a")
+	stop := q.KeepAlive(ctx, "a", msg)
+	time.Sleep(2 * q.VisibilityTimeout)
+	nothingNext(t, q, "b")
+
+	stop()
+	time.Sleep(2 * q.VisibilityTimeout)
+	if got := next(t, q, "b"); got.ID != msg.ID {
+		t.Errorf("b got %s, want %s", got.ID, msg.ID)
+	}
+}
+
+// A verdict is handed to one server at a time, and to another if the first
+// doesn't acknowledge it within the visibility timeout.
+func TestQueueVerdicts(t *testing.T) {
+	ctx := context.Background()
+	q, mr := newTestQueue(t)
+	now := time.Now()
+	mr.SetTime(now)
+	if err := q.Push(ctx, Submission{SubmissionID: 7}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.AddVerdict(ctx, 8, []byte(`{"end":true,"status":"passed"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if known, err := q.Known(ctx); err != nil || len(known) != 2 || !known[7] || !known[8] {
+		t.Errorf("Known = %v, %v; want 7 queued and 8 unrecorded", known, err)
+	}
+
+	first, err := q.NextVerdict(ctx, "a", 10*time.Millisecond)
+	if err != nil || first == nil || first.SubmissionID != 8 || first.Event != `{"end":true,"status":"passed"}` {
+		t.Fatalf("NextVerdict = %+v, %v; want the verdict of 8", first, err)
+	}
+	if v, err := q.NextVerdict(ctx, "b", 10*time.Millisecond); err != nil || v != nil {
+		t.Fatalf("b got %+v, %v; want nothing while a stores it", v, err)
+	}
+
+	// and "a" crashes
+	mr.SetTime(now.Add(q.VisibilityTimeout + time.Second))
+	second, err := q.NextVerdict(ctx, "b", 10*time.Millisecond)
+	if err != nil || second == nil || second.ID != first.ID {
+		t.Fatalf("b got %+v, %v; want %s", second, err, first.ID)
+	}
+	if err := q.AckVerdict(ctx, second); err != nil {
+		t.Fatal(err)
+	}
+	if n := q.Redis.XLen(ctx, q.Verdicts).Val(); n != 0 {
+		t.Errorf("%d verdicts left after AckVerdict, want 0", n)
+	}
+	if known, _ := q.Known(ctx); known[8] {
+		t.Error("8 still known once its verdict was stored")
+	}
+}
@@ -0,0 +1,360 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"new_cli/runner"
+)
+
+// Submission is the queue payload pushed by the server in uploadSolution.
+type Submission struct {
+	// SubmissionID is the pending row the server created for this
+	// submission; 0 if the server stores the verdict on its own.
+	SubmissionID int    `json:"submissionId,omitempty"`
+	StudentID    string `json:"studentId"`
+	QuestionID   string `json:"questionId"`
+	// Source is the solution being judged. It travels with the submission,
+	// as an upload path is shared by every submission of the same student
+	// and question; payloads without it name SolutionFilePath instead.
+	Source           string            `json:"source,omitempty"`
+	SolutionFilePath string            `json:"solutionFilePath,omitempty"`
+	TestCases        []runner.TestCase `json:"testCases"`
+	Checker          string            `json:"checker,omitempty"`    // checker source, testlib protocol
+	Interactor       string            `json:"interactor,omitempty"` // interactor source, testlib protocol
+}
+
+// Channel is the pub/sub channel progress events are published on. It is
+// unique to the submission, so two submissions to the same question never
+// hear each other; payloads without an ID fall back to the student and
+// question.
+func (s Submission) Channel() string {
+	if s.SubmissionID != 0 {
+		return fmt.Sprintf("submission:%d", s.SubmissionID)
+	}
+	return fmt.Sprintf("%s-%s", s.StudentID, s.QuestionID)
+}
+
+type Worker struct {
+	Redis    *redis.Client // for publishing progress
+	Queue    *Queue
+	Consumer string // this worker's name in the consumer group
+	Compiler runner.Compiler
+	Limits   runner.Limits
+
+	// Cache, if set, is used for checkers and interactors, which are the
+	// same for every submission to a question.
+	Cache *runner.Cache
+
+	mu      sync.Mutex
+	current int                // submission being judged
+	abort   context.CancelFunc // stops judging it
+}
+
+// Run judges submissions off the queue until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) error {
+	if err := w.Queue.Setup(ctx); err != nil {
+		return err
+	}
+	go w.watchCancels(ctx)
+	for {
+		msg, err := w.Queue.Next(ctx, w.Consumer, 5*time.Second)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			log.Println("Error reading queue:", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		if msg != nil {
+			w.handle(ctx, msg)
+		}
+	}
+}
+
+func (w *Worker) handle(ctx context.Context, msg *Message) {
+	sub := msg.Submission
+	if msg.Attempts > w.Queue.MaxAttempts {
+		log.Printf("Giving up on submission %s after %d attempts", msg.ID, msg.Attempts-1)
+		if err := w.fail(ctx, sub, "The judge failed on this submission repeatedly. Please tell your instructor."); err != nil {
+			log.Printf("Error recording verdict of submission %d: %v", sub.SubmissionID, err)
+			return
+		}
+		if err := w.Queue.Bury(ctx, msg, "too many attempts"); err != nil {
+			log.Println("Error moving submission to the dead letter stream:", err)
+		}
+		return
+	}
+	if msg.Attempts > 1 {
+		log.Printf("Retrying submission %s (attempt %d of %d)", msg.ID, msg.Attempts, w.Queue.MaxAttempts)
+	}
+
+	judgeCtx, abort := context.WithCancel(ctx)
+	defer abort()
+	w.mu.Lock()
+	w.current, w.abort = sub.SubmissionID, abort
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		w.current, w.abort = 0, nil
+		w.mu.Unlock()
+	}()
+
+	// Cancelled between being read and being registered above
+	if cancelled, _ := w.Queue.Cancelled(ctx, sub.SubmissionID); cancelled {
+		abort()
+	}
+
+	stop := w.Queue.KeepAlive(ctx, w.Consumer, msg)
+	err := w.process(judgeCtx, sub)
+	stop()
+
+	// Stopped mid-judging: leave it pending for another worker to claim
+	if ctx.Err() != nil {
+		return
+	}
+	if judgeCtx.Err() != nil {
+		log.Printf("Cancelled submission %d", sub.SubmissionID)
+		if err := w.Queue.Drop(ctx, msg); err != nil {
+			log.Println("Error dropping submission:", err)
+		}
+		return
+	}
+	// Judged again once the visibility timeout passes, rather than left
+	// without a verdict
+	if err != nil {
+		log.Printf("Error recording verdict of submission %d: %v", sub.SubmissionID, err)
+		return
+	}
+	if err := w.Queue.Ack(ctx, msg); err != nil {
+		log.Println("Error acknowledging submission:", err)
+	}
+}
+
+// watchCancels stops judging the current submission when it is cancelled.
+func (w *Worker) watchCancels(ctx context.Context) {
+	pubsub := w.Redis.Subscribe(ctx, w.Queue.CancelChannel())
+	defer pubsub.Close()
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-messages:
+			id, err := strconv.Atoi(msg.Payload)
+			if err != nil {
+				continue
+			}
+			w.mu.Lock()
+			if w.current == id && w.abort != nil {
+				w.abort()
+			}
+			w.mu.Unlock()
+		}
+	}
+}
+
+// publish sends an event about sub. Nothing is sent once judging was
+// cancelled: the server has told the student already.
+func (w *Worker) publish(ctx context.Context, sub Submission, event any) {
+	if ctx.Err() != nil {
+		return
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Println("Error encoding event:", err)
+		return
+	}
+	if err := w.Redis.Publish(ctx, sub.Channel(), data).Err(); err != nil {
+		log.Println("Error publishing event:", err)
+	}
+}
+
+// finish sends the final event about sub, and adds it to the verdicts for a
+// server to store, whether or not any is listening right now.
+func (w *Worker) finish(ctx context.Context, sub Submission, event any) error {
+	if ctx.Err() != nil {
+		return nil
+	}
+	if sub.SubmissionID != 0 {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if err := w.Queue.AddVerdict(ctx, sub.SubmissionID, data); err != nil {
+			return err
+		}
+	}
+	w.publish(ctx, sub, event)
+	return nil
+}
+
+func (w *Worker) fail(ctx context.Context, sub Submission, output string) error {
+	return w.finish(ctx, sub, map[string]any{
+		"submissionId": sub.SubmissionID,
+		"studentId":    sub.StudentID,
+		"questionId":   sub.QuestionID,
+		"output":       output,
+		"end":          true,
+		"status":       "failed",
+	})
+}
+
+// process judges sub. It returns an error only if the verdict couldn't be
+// recorded.
+func (w *Worker) process(ctx context.Context, sub Submission) error {
+	log.Printf("Running submission: %s %s", sub.StudentID, sub.QuestionID)
+	w.publish(ctx, sub, map[string]any{"start": true})
+
+	// Build and run in a private directory; the upload dir is shared by every
+	// submission of the same student and question.
+	ws, err := runner.NewWorkspace("judge")
+	if err != nil {
+		return w.fail(ctx, sub, err.Error())
+	}
+	defer ws.Close()
+
+	src, err := w.solution(ws, sub)
+	if err != nil {
+		return w.fail(ctx, sub, err.Error())
+	}
+	binary := filepath.Join(ws.Dir, "output")
+	if err := w.Compiler.Compile(ctx, src, binary); err != nil {
+		event := map[string]any{"submissionId": sub.SubmissionID, "status": "failed", "output": err.Error(), "end": true}
+		var compileErr *runner.CompileError
+		if errors.As(err, &compileErr) && len(compileErr.Diagnostics) > 0 {
+			// Students know their file by name, not by where it was uploaded
+			for i := range compileErr.Diagnostics {
+				compileErr.Diagnostics[i].File = filepath.Base(compileErr.Diagnostics[i].File)
+			}
+			event["diagnostics"] = compileErr.Diagnostics
+		}
+		return w.finish(ctx, sub, event)
+	}
+	w.publish(ctx, sub, map[string]any{"status": "success", "output": "Compiled successfully"})
+
+	progress := func(i int, result runner.TestResult) {
+		w.publish(ctx, sub, map[string]any{
+			"submissionId": sub.SubmissionID,
+			"test":         i + 1,
+			"total":        len(sub.TestCases),
+			"verdict":      result.Verdict,
+			"time":         result.Time,
+		})
+	}
+
+	var report *runner.Report
+	if sub.Interactor != "" {
+		interactor, err := w.compileHelper(ctx, ws, "interactor", sub.Interactor)
+		if err != nil {
+			return w.fail(ctx, sub, err.Error())
+		}
+		report = runner.JudgeInteractive(ctx, binary, interactor, ws.Dir, sub.TestCases, w.Limits, progress)
+	} else {
+		var checker runner.Checker = runner.ExactChecker{}
+		if sub.Checker != "" {
+			path, err := w.compileHelper(ctx, ws, "checker", sub.Checker)
+			if err != nil {
+				return w.fail(ctx, sub, err.Error())
+			}
+			checker = runner.ProgramChecker{Path: path}
+		}
+		report = runner.Judge(ctx, binary, ws.Dir, sub.TestCases, checker, w.Limits, progress)
+		w.diagnose(ctx, ws, src, sub, report)
+	}
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	status := "passed"
+	if len(report.Failed) > 0 {
+		status = "failed"
+	}
+	output := struct {
+		*runner.Report
+		SubmissionID int    `json:"submissionId,omitempty"`
+		StudentID    string `json:"studentId"`
+		QuestionID   string `json:"questionId"`
+		End          bool   `json:"end"`
+		Status       string `json:"status"`
+	}{report, sub.SubmissionID, sub.StudentID, sub.QuestionID, true, status}
+	return w.finish(ctx, sub, output)
+}
+
+// solution writes the source of sub into the workspace and returns its path.
+// A submission without one is read from its upload path, which the next
+// submission to the same question may already have replaced.
+func (w *Worker) solution(ws *runner.Workspace, sub Submission) (string, error) {
+	if sub.Source == "" {
+		return sub.SolutionFilePath, nil
+	}
+	src := filepath.Join(ws.Dir, "solution.cpp")
+	if err := os.WriteFile(src, []byte(sub.Source), 0o644); err != nil {
+		return "", fmt.Errorf("error writing solution: %v", err)
+	}
+	return src, nil
+}
+
+// diagnose reruns the first test case the solution crashed on with a
+// sanitizer build of src, so the student learns where it crashed.
+func (w *Worker) diagnose(ctx context.Context, ws *runner.Workspace, src string, sub Submission, report *runner.Report) {
+	for i, result := range report.Failed {
+		if result.Verdict != runner.RuntimeError {
+			continue
+		}
+		binary := filepath.Join(ws.Dir, "debug")
+		if err := w.Compiler.WithSanitizers().Compile(ctx, src, binary); err != nil {
+			log.Println("Error building with sanitizers:", err)
+			return
+		}
+		tc := runner.TestCase{Input: result.Input}
+		for _, c := range sub.TestCases {
+			if c.Input == result.Input {
+				tc = c
+				break
+			}
+		}
+		crash := runner.Diagnose(ctx, binary, ws.Dir, tc, w.Limits)
+		if crash == nil {
+			return
+		}
+		for j := range crash.Frames {
+			crash.Frames[j].File = filepath.Base(crash.Frames[j].File)
+		}
+		report.Failed[i].Crash = crash
+		return
+	}
+}
+
+// compileHelper builds an instructor-supplied program (checker or
+// interactor) in the judging workspace and returns the executable path.
+func (w *Worker) compileHelper(ctx context.Context, ws *runner.Workspace, name, source string) (string, error) {
+	src := filepath.Join(ws.Dir, name+".cpp")
+	if err := os.WriteFile(src, []byte(source), 0o644); err != nil {
+		return "", fmt.Errorf("error writing %s: %v", name, err)
+	}
+	if w.Cache != nil {
+		binary, _, err := w.Cache.Build(ctx, w.Compiler, src)
+		if err != nil {
+			return "", fmt.Errorf("%s %v", name, err)
+		}
+		return binary, nil
+	}
+
+	binary := filepath.Join(ws.Dir, name)
+	if err := w.Compiler.Compile(ctx, src, binary); err != nil {
+		return "", fmt.Errorf("%s %v", name, err)
+	}
+	return binary, nil
+}
@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"os/exec"
+	"testing"
+	"time"
+
+	"new_cli/runner"
+)
+
+// Two quick submissions to the same question are each judged on their own
+// source, even when both were queued before either was judged.
+func TestWorkerJudgesEachSource(t *testing.T) {
+	if _, err := exec.LookPath("g++"); err != nil {
+		t.Skip("g++ not installed")
+	}
+	ctx := context.Background()
+	q, _ := newTestQueue(t)
+	tests := []runner.TestCase{{Input: "", Output: "1"}}
+	sources := map[int]string{
+		7: "#include <cstdio>\nint main() { puts(\"1\"); }\n",
+		8: "#include <cstdio>\nint main() { puts(\"2\"); }\n",
+	}
+	for _, id := range []int{7, 8} {
+		if err := q.Push(ctx, Submission{SubmissionID: id, StudentID: "1", QuestionID: "2", Source: sources[id], TestCases: tests}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := &Worker{Redis: q.Redis, Queue: q, Consumer: "a", Compiler: runner.DefaultCompiler, Limits: runner.DefaultLimits}
+	want := map[int]string{7: "passed", 8: "failed"}
+	for _, msg := range []*Message{next(t, q, "a"), next(t, q, "a")} {
+		events := q.Redis.Subscribe(ctx, msg.Submission.Channel())
+		if _, err := events.Receive(ctx); err != nil {
+			t.Fatal(err)
+		}
+		w.handle(ctx, msg)
+
+		id := msg.Submission.SubmissionID
+		for done := false; !done; {
+			select {
+			case event := <-events.Channel():
+				var got struct {
+					End    bool   `json:"end"`
+					Status string `json:"status"`
+				}
+				if err := json.Unmarshal([]byte(event.Payload), &got); err != nil || !got.End {
+					continue
+				}
+				if got.Status != want[id] {
+					t.Errorf("submission %d %s, want %s: %s", id, got.Status, want[id], event.Payload)
+				}
+				done = true
+			case <-time.After(10 * time.Second):
+				t.Fatalf("no verdict for submission %d", id)
+			}
+		}
+		events.Close()
+	}
+}